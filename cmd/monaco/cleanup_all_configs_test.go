@@ -19,6 +19,7 @@
 package main
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"testing"
@@ -50,13 +51,13 @@ func TestDoCleanup(t *testing.T) {
 
 		for _, api := range apis {
 
-			values, err := client.List(api)
+			values, err := client.List(context.Background(), api)
 			assert.NilError(t, err)
 
 			for _, value := range values {
 				if r.MatchString(value.Name) || r.MatchString(value.Id) || strings.HasSuffix(value.Name, "_") {
 					util.Log.Info("Deleting %s (%s)\n", value.Name, api.GetId())
-					client.DeleteByName(api, value.Name)
+					client.DeleteByName(context.Background(), api, value.Name)
 				}
 			}
 		}
@@ -19,6 +19,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"path/filepath"
@@ -164,14 +165,14 @@ func cleanupEnvironmentConfigs(t *testing.T, fs afero.Fs, envFile, suffix string
 
 		for _, api := range apis {
 
-			values, err := client.List(api)
+			values, err := client.List(context.Background(), api)
 			assert.NilError(t, err)
 
 			for _, value := range values {
 				// For the calculated-metrics-log API, the suffix is part of the ID, not name
 				if strings.HasSuffix(value.Name, suffix) || strings.HasSuffix(value.Id, suffix) {
 					util.Log.Info("Deleting %s (%s)", value.Name, api.GetId())
-					client.DeleteByName(api, value.Name)
+					client.DeleteByName(context.Background(), api, value.Name)
 				}
 			}
 		}
@@ -0,0 +1,65 @@
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	dryRunOffline = "offline"
+	dryRunOnline  = "online"
+)
+
+// dryRunFlag is the value of the --dry-run flag. It can be used like a boolean flag (--dry-run) to validate
+// the configs without contacting the environments, or with a mode (--dry-run=online) to validate them against
+// the environments using read requests only.
+type dryRunFlag struct {
+	mode string
+}
+
+func (f *dryRunFlag) Set(value string) error {
+	switch strings.ToLower(value) {
+	case "true", dryRunOffline:
+		f.mode = dryRunOffline
+	case "false":
+		f.mode = ""
+	case dryRunOnline:
+		f.mode = dryRunOnline
+	default:
+		return fmt.Errorf("unknown dry run mode %s, supported modes are %s and %s", value, dryRunOffline, dryRunOnline)
+	}
+	return nil
+}
+
+func (f *dryRunFlag) String() string {
+	return f.mode
+}
+
+// IsBoolFlag allows to use the flag without value
+func (f *dryRunFlag) IsBoolFlag() bool {
+	return true
+}
+
+func (f *dryRunFlag) isSet() bool {
+	return f.mode != ""
+}
+
+func (f *dryRunFlag) isOnline() bool {
+	return f.mode == dryRunOnline
+}
@@ -19,6 +19,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strconv"
@@ -71,7 +72,7 @@ func AssertConfig(t *testing.T, client rest.DynatraceClient, environment environ
 	api := config.GetApi()
 	name := config.GetProperties()[config.GetId()]["name"]
 
-	_, existingId, _ := client.ExistsByName(api, name)
+	_, existingId, _ := client.ExistsByName(context.Background(), api, name)
 
 	if config.IsSkipDeployment(environment) {
 		assert.Equal(t, existingId, "", "Object should NOT be available, but was. environment.Environment: '"+environment.GetId()+"', failed for '"+name+"' ("+configType+")")
@@ -82,7 +83,7 @@ func AssertConfig(t *testing.T, client rest.DynatraceClient, environment environ
 
 	// 120 polling cycles -> Wait at most 120 * 2 seconds = 4 Minutes:
 	err := rest.Wait(description, 120, func() bool {
-		_, existingId, _ := client.ExistsByName(api, name)
+		_, existingId, _ := client.ExistsByName(context.Background(), api, name)
 		return (shouldBeAvailable && len(existingId) > 0) || (!shouldBeAvailable && len(existingId) == 0)
 	})
 	assert.NilError(t, err)
@@ -138,14 +139,14 @@ func cleanupIntegrationTest(t *testing.T, fs afero.Fs, envFile, suffix string) {
 
 		for _, api := range apis {
 
-			values, err := client.List(api)
+			values, err := client.List(context.Background(), api)
 			assert.NilError(t, err)
 
 			for _, value := range values {
 				// For the calculated-metrics-log API, the suffix is part of the ID, not name
 				if strings.HasSuffix(value.Name, suffix) || strings.HasSuffix(value.Id, suffix) {
 					util.Log.Info("Deleting %s (%s)", value.Name, api.GetId())
-					client.DeleteByName(api, value.Name)
+					client.DeleteByName(context.Background(), api, value.Name)
 				}
 			}
 		}
@@ -17,11 +17,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/deploy"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/diff"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/download"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/plan"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/version"
 	"github.com/spf13/afero"
@@ -46,7 +53,15 @@ func RunImpl(args []string, fs afero.Fs) (statusCode int) {
 		app = buildCli(fs)
 	}
 
-	err := app.Run(args)
+	// the first interrupt stops the deployment gracefully, a second one terminates monaco immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := app.RunContext(ctx, args)
 
 	if err != nil {
 		util.Log.Error("%s\n", err)
@@ -119,6 +134,11 @@ Examples:
 			Required:  true,
 			TakesFile: true,
 		},
+		&cli.PathFlag{
+			Name:      "apis",
+			Usage:     "Yaml file defining additional APIs (default: apis.yaml in the working directory, if it exists)",
+			TakesFile: true,
+		},
 		&cli.StringFlag{
 			Name:        "specific-environment",
 			Usage:       "Specific environment (from list) to deploy to",
@@ -141,6 +161,19 @@ Examples:
 			Usage:   "Proceed deployment even if config upload fails",
 			Aliases: []string{"c"},
 		},
+		&cli.DurationFlag{
+			Name:  "lock-timeout",
+			Usage: "How long to wait for the lock of an environment, if another deployment to it is running",
+			Value: 5 * time.Minute,
+		},
+		&cli.PathFlag{
+			Name:  "lock-dir",
+			Usage: "Folder of the environment lock files, should be shared by all machines deploying to the environments (default: .monaco/locks in the working directory)",
+		},
+		&cli.BoolFlag{
+			Name:  "force-unlock",
+			Usage: "Remove the locks of the environments left behind by a killed deployment, instead of deploying",
+		},
 	}
 
 	app.Action = func(ctx *cli.Context) error {
@@ -157,14 +190,24 @@ Examples:
 			workingDir = "."
 		}
 
+		if err := api.LoadUserApis(fs, workingDir, ctx.Path("apis")); err != nil {
+			return err
+		}
+
 		return deploy.Deploy(
+			ctx.Context,
 			workingDir,
 			fs,
 			ctx.Path("environments"),
 			ctx.String("specific-environment"),
 			ctx.String("project"),
-			ctx.Bool("dry-run"),
-			ctx.Bool("continue-on-error"),
+			deploy.Options{
+				DryRun:          ctx.Bool("dry-run"),
+				ContinueOnError: ctx.Bool("continue-on-error"),
+				LockDir:         ctx.Path("lock-dir"),
+				LockTimeout:     ctx.Duration("lock-timeout"),
+				ForceUnlock:     ctx.Bool("force-unlock"),
+			},
 		)
 	}
 
@@ -208,11 +251,14 @@ Examples:
 `
 	deployCommand := getDeployCommand(fs)
 	downloadCommand := getDownloadCommand(fs)
-	app.Commands = []*cli.Command{&deployCommand, &downloadCommand}
+	diffCommand := getDiffCommand(fs)
+	applyCommand := getApplyCommand(fs)
+	app.Commands = []*cli.Command{&deployCommand, &downloadCommand, &diffCommand, &applyCommand}
 
 	return app
 }
 func getDeployCommand(fs afero.Fs) cli.Command {
+	dryRun := &dryRunFlag{}
 	command := cli.Command{
 		Name:      "deploy",
 		Usage:     "deploys the given environment",
@@ -241,6 +287,11 @@ func getDeployCommand(fs afero.Fs) cli.Command {
 				Required:  true,
 				TakesFile: true,
 			},
+			&cli.PathFlag{
+				Name:      "apis",
+				Usage:     "Yaml file defining additional APIs (default: apis.yaml in the working directory, if it exists)",
+				TakesFile: true,
+			},
 			&cli.StringFlag{
 				Name:    "specific-environment",
 				Usage:   "Specific environment (from list) to deploy to",
@@ -251,16 +302,113 @@ func getDeployCommand(fs afero.Fs) cli.Command {
 				Usage:   "Project configuration to deploy (also deploys any dependent configurations)",
 				Aliases: []string{"p"},
 			},
+			&cli.StringSliceFlag{
+				Name:  "config",
+				Usage: "Config to deploy as project/api/id, wildcards like infra/dashboard/* are supported (also deploys all configs it depends on). Can be repeated",
+			},
+			&cli.StringSliceFlag{
+				Name:  "api",
+				Usage: "Only deploy configs of the given APIs, e.g. dashboard,alerting-profile",
+			},
+			&cli.StringSliceFlag{
+				Name:  "exclude-api",
+				Usage: "Don't deploy configs of the given APIs. Fails if a deployed config depends on an excluded one",
+			},
 			&cli.BoolFlag{
+				Name:  "resolve-from-environment",
+				Usage: "Look up referenced configs which are not deployed (e.g. skipped ones) in the environment by their name. With --project, dependency projects are not deployed",
+			},
+			&cli.GenericFlag{
 				Name:    "dry-run",
 				Aliases: []string{"d"},
-				Usage:   "Switches to just validation instead of actual deployment",
+				Usage:   "Switches to just validation instead of actual deployment. Use --dry-run=online to validate against the environment using read requests only",
+				Value:   dryRun,
 			},
 			&cli.BoolFlag{
 				Name:    "continue-on-error",
 				Usage:   "Proceed deployment even if config upload fails",
 				Aliases: []string{"c"},
 			},
+			&cli.IntFlag{
+				Name:  "parallel",
+				Usage: "Maximum number of environments to deploy to at the same time",
+				Value: 1,
+			},
+			&cli.IntFlag{
+				Name:  "parallel-configs",
+				Usage: "Maximum number of independent configs to deploy to an environment at the same time",
+				Value: 1,
+			},
+			&cli.BoolFlag{
+				Name:  "state",
+				Usage: "Track deployed objects in .monaco/state/<environment>.json to update them by id and detect renamed or removed configs",
+			},
+			&cli.PathFlag{
+				Name:      "report-file",
+				Usage:     "Write a machine-readable report of the deployment to the given file",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:  "report-format",
+				Usage: "Format of the report written to --report-file: json or junit",
+				Value: "json",
+			},
+			&cli.BoolFlag{
+				Name:  "resume",
+				Usage: "Continue a failed deployment, skipping all configs which have already been deployed successfully",
+			},
+			&cli.BoolFlag{
+				Name:  "rollback-on-failure",
+				Usage: "Revert all changes made to an environment, if its deployment fails",
+			},
+			&cli.BoolFlag{
+				Name:  "prune",
+				Usage: "Delete objects whose configs have been removed from the deployed projects. Implies --state",
+			},
+			&cli.BoolFlag{
+				Name:    "yes",
+				Usage:   "Don't ask for confirmation before pruning",
+				Aliases: []string{"y"},
+			},
+			&cli.DurationFlag{
+				Name:  "lock-timeout",
+				Usage: "How long to wait for the lock of an environment, if another deployment to it is running",
+				Value: 5 * time.Minute,
+			},
+			&cli.PathFlag{
+				Name:  "lock-dir",
+				Usage: "Folder of the environment lock files, should be shared by all machines deploying to the environments (default: .monaco/locks in the working directory)",
+			},
+			&cli.BoolFlag{
+				Name:  "force-unlock",
+				Usage: "Remove the locks of the environments left behind by a killed deployment, instead of deploying",
+			},
+			&cli.StringSliceFlag{
+				Name:  "waves",
+				Usage: "Deploy the environment groups one after another, e.g. dev,staging,prod. A wave is only deployed if the previous one succeeded",
+			},
+			&cli.StringFlag{
+				Name:  "wave-gate",
+				Usage: "Command which has to succeed before each wave but the first is deployed",
+			},
+			&cli.DurationFlag{
+				Name:  "wave-wait",
+				Usage: "Time to wait before each wave but the first is deployed",
+			},
+			&cli.StringFlag{
+				Name:  "rate-limit-strategy",
+				Usage: "How to handle the rate limit of the environments: simple waits once it is exceeded, token-bucket throttles requests before (default: MONACO_RATE_LIMIT_STRATEGY or simple)",
+			},
+			&cli.IntFlag{
+				Name:        "rate-limit",
+				Usage:       "Maximum number of requests per minute sent to an environment with the token-bucket strategy, lower limits reported by the environment are respected",
+				DefaultText: "the limit reported by the environment",
+			},
+			&cli.PathFlag{
+				Name:      "plan-out",
+				Usage:     "Write the deployment as plan to the given file instead of deploying it. Use the apply command to execute the plan",
+				TakesFile: true,
+			},
 		},
 		Action: func(ctx *cli.Context) error {
 			if ctx.NArg() > 1 {
@@ -276,14 +424,60 @@ func getDeployCommand(fs afero.Fs) cli.Command {
 				workingDir = "."
 			}
 
+			if err := api.LoadUserApis(fs, workingDir, ctx.Path("apis")); err != nil {
+				return err
+			}
+
+			if ctx.IsSet("plan-out") {
+				if err := checkPlanOutFlags(ctx); err != nil {
+					return err
+				}
+				return plan.CreatePlan(
+					ctx.Context,
+					workingDir,
+					fs,
+					ctx.Path("environments"),
+					ctx.String("specific-environment"),
+					ctx.String("project"),
+					ctx.Path("plan-out"),
+					ctx.String("rate-limit-strategy"),
+					ctx.Int("rate-limit"),
+				)
+			}
+
 			return deploy.Deploy(
+				ctx.Context,
 				workingDir,
 				fs,
 				ctx.Path("environments"),
 				ctx.String("specific-environment"),
 				ctx.String("project"),
-				ctx.Bool("dry-run"),
-				ctx.Bool("continue-on-error"),
+				deploy.Options{
+					DryRun:                 dryRun.isSet(),
+					DryRunOnline:           dryRun.isOnline(),
+					ContinueOnError:        ctx.Bool("continue-on-error"),
+					Parallel:               ctx.Int("parallel"),
+					ParallelConfigs:        ctx.Int("parallel-configs"),
+					UseState:               ctx.Bool("state"),
+					Prune:                  ctx.Bool("prune"),
+					AssumeYes:              ctx.Bool("yes"),
+					RollbackOnFailure:      ctx.Bool("rollback-on-failure"),
+					ReportFile:             ctx.Path("report-file"),
+					ReportFormat:           ctx.String("report-format"),
+					Resume:                 ctx.Bool("resume"),
+					Configs:                ctx.StringSlice("config"),
+					Apis:                   ctx.StringSlice("api"),
+					ExcludedApis:           ctx.StringSlice("exclude-api"),
+					ResolveFromEnvironment: ctx.Bool("resolve-from-environment"),
+					LockDir:                ctx.Path("lock-dir"),
+					LockTimeout:            ctx.Duration("lock-timeout"),
+					ForceUnlock:            ctx.Bool("force-unlock"),
+					Waves:                  ctx.StringSlice("waves"),
+					WaveGate:               ctx.String("wave-gate"),
+					WaveWait:               ctx.Duration("wave-wait"),
+					RateLimitStrategy:      ctx.String("rate-limit-strategy"),
+					RateLimit:              ctx.Int("rate-limit"),
+				},
 			)
 		},
 	}
@@ -317,6 +511,11 @@ func getDownloadCommand(fs afero.Fs) cli.Command {
 				Required:  true,
 				TakesFile: true,
 			},
+			&cli.PathFlag{
+				Name:      "apis",
+				Usage:     "Yaml file defining additional APIs (default: apis.yaml in the working directory, if it exists)",
+				TakesFile: true,
+			},
 			&cli.StringFlag{
 				Name:    "specific-environment",
 				Usage:   "Specific environment (from list) to deploy to",
@@ -327,6 +526,15 @@ func getDownloadCommand(fs afero.Fs) cli.Command {
 				Usage:   "Comma separated list of API's to download ",
 				Aliases: []string{"p"},
 			},
+			&cli.StringFlag{
+				Name:  "rate-limit-strategy",
+				Usage: "How to handle the rate limit of the environments: simple waits once it is exceeded, token-bucket throttles requests before (default: MONACO_RATE_LIMIT_STRATEGY or simple)",
+			},
+			&cli.IntFlag{
+				Name:        "rate-limit",
+				Usage:       "Maximum number of requests per minute sent to an environment with the token-bucket strategy, lower limits reported by the environment are respected",
+				DefaultText: "the limit reported by the environment",
+			},
 		},
 		Action: func(ctx *cli.Context) error {
 			var workingDir string
@@ -337,14 +545,206 @@ func getDownloadCommand(fs afero.Fs) cli.Command {
 				workingDir = "."
 			}
 
+			if err := api.LoadUserApis(fs, workingDir, ctx.Path("apis")); err != nil {
+				return err
+			}
+
 			return download.GetConfigsFilterByEnvironment(
+				ctx.Context,
 				workingDir,
 				fs,
 				ctx.Path("environments"),
 				ctx.String("specific-environment"),
 				ctx.String("downloadSpecificAPI"),
+				ctx.String("rate-limit-strategy"),
+				ctx.Int("rate-limit"),
+			)
+		},
+	}
+	return command
+}
+
+func getDiffCommand(fs afero.Fs) cli.Command {
+	command := cli.Command{
+		Name:      "diff",
+		Usage:     "shows what a deployment would change in the given environment",
+		UsageText: "diff [command options] [working directory]",
+		ArgsUsage: "[working directory]",
+		Before: func(c *cli.Context) error {
+			err := util.SetupLogging(c.Bool("verbose"))
+
+			if err != nil {
+				return err
+			}
+
+			util.Log.Info("Dynatrace Monitoring as Code v" + version.MonitoringAsCode)
+
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+			},
+			&cli.PathFlag{
+				Name:      "environments",
+				Usage:     "Yaml file containing environment to compare with",
+				Aliases:   []string{"e"},
+				Required:  true,
+				TakesFile: true,
+			},
+			&cli.PathFlag{
+				Name:      "apis",
+				Usage:     "Yaml file defining additional APIs (default: apis.yaml in the working directory, if it exists)",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:    "specific-environment",
+				Usage:   "Specific environment (from list) to compare with",
+				Aliases: []string{"s"},
+			},
+			&cli.StringFlag{
+				Name:    "project",
+				Usage:   "Project configuration to compare (also compares any dependent configurations)",
+				Aliases: []string{"p"},
+			},
+			&cli.PathFlag{
+				Name:      "output-file",
+				Usage:     "File to write the json diff to. If omitted, the diff is printed to stdout",
+				Aliases:   []string{"o"},
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:  "rate-limit-strategy",
+				Usage: "How to handle the rate limit of the environments: simple waits once it is exceeded, token-bucket throttles requests before (default: MONACO_RATE_LIMIT_STRATEGY or simple)",
+			},
+			&cli.IntFlag{
+				Name:        "rate-limit",
+				Usage:       "Maximum number of requests per minute sent to an environment with the token-bucket strategy, lower limits reported by the environment are respected",
+				DefaultText: "the limit reported by the environment",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() > 1 {
+				util.Log.Error("Too many arguments! Either specify a relative path to the working directory, or omit it for using the current working directory.")
+				cli.ShowAppHelpAndExit(ctx, 1)
+			}
+
+			var workingDir string
+
+			if ctx.Args().Present() {
+				workingDir = ctx.Args().First()
+			} else {
+				workingDir = "."
+			}
+
+			if err := api.LoadUserApis(fs, workingDir, ctx.Path("apis")); err != nil {
+				return err
+			}
+
+			return diff.Diff(
+				ctx.Context,
+				workingDir,
+				fs,
+				ctx.Path("environments"),
+				ctx.String("specific-environment"),
+				ctx.String("project"),
+				ctx.Path("output-file"),
+				ctx.String("rate-limit-strategy"),
+				ctx.Int("rate-limit"),
+			)
+		},
+	}
+	return command
+}
+
+func getApplyCommand(fs afero.Fs) cli.Command {
+	command := cli.Command{
+		Name:      "apply",
+		Usage:     "applies a plan created by deploy --plan-out",
+		UsageText: "apply [command options] <plan file>",
+		ArgsUsage: "<plan file>",
+		Before: func(c *cli.Context) error {
+			err := util.SetupLogging(c.Bool("verbose"))
+
+			if err != nil {
+				return err
+			}
+
+			util.Log.Info("Dynatrace Monitoring as Code v" + version.MonitoringAsCode)
+
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:    "verbose",
+				Aliases: []string{"v"},
+			},
+			&cli.PathFlag{
+				Name:      "environments",
+				Usage:     "Yaml file containing the environments of the plan",
+				Aliases:   []string{"e"},
+				Required:  true,
+				TakesFile: true,
+			},
+			&cli.PathFlag{
+				Name:      "apis",
+				Usage:     "Yaml file defining additional APIs (default: apis.yaml in the working directory, if it exists)",
+				TakesFile: true,
+			},
+			&cli.StringFlag{
+				Name:  "rate-limit-strategy",
+				Usage: "How to handle the rate limit of the environments: simple waits once it is exceeded, token-bucket throttles requests before (default: MONACO_RATE_LIMIT_STRATEGY or simple)",
+			},
+			&cli.IntFlag{
+				Name:        "rate-limit",
+				Usage:       "Maximum number of requests per minute sent to an environment with the token-bucket strategy, lower limits reported by the environment are respected",
+				DefaultText: "the limit reported by the environment",
+			},
+			&cli.DurationFlag{
+				Name:  "lock-timeout",
+				Usage: "How long to wait for the lock of an environment, if a deployment to it is running",
+				Value: 5 * time.Minute,
+			},
+			&cli.PathFlag{
+				Name:  "lock-dir",
+				Usage: "Folder of the environment lock files, should be the one used by the deployments (default: .monaco/locks in the current directory)",
+			},
+		},
+		Action: func(ctx *cli.Context) error {
+			if ctx.NArg() != 1 {
+				util.Log.Error("Exactly one plan file has to be specified.")
+				cli.ShowAppHelpAndExit(ctx, 1)
+			}
+
+			if err := api.LoadUserApis(fs, ".", ctx.Path("apis")); err != nil {
+				return err
+			}
+
+			return plan.Apply(
+				ctx.Context,
+				fs,
+				ctx.Path("environments"),
+				ctx.Args().First(),
+				plan.ApplyOptions{
+					RateLimitStrategy: ctx.String("rate-limit-strategy"),
+					RateLimit:         ctx.Int("rate-limit"),
+					LockDir:           ctx.Path("lock-dir"),
+					LockTimeout:       ctx.Duration("lock-timeout"),
+				},
 			)
 		},
 	}
 	return command
 }
+
+// checkPlanOutFlags rejects flags selecting what to deploy, as a plan always covers all configs of the projects
+// and would contain more than the matching deployment
+func checkPlanOutFlags(ctx *cli.Context) error {
+	for _, flag := range []string{"config", "api", "exclude-api", "dry-run"} {
+		if ctx.IsSet(flag) {
+			return fmt.Errorf("--plan-out can't be combined with --%s", flag)
+		}
+	}
+	return nil
+}
@@ -77,15 +77,14 @@ func TestValidationSkipDeploymentWithOverridingFlagValue(t *testing.T) {
 	assert.Equal(t, statusCode, 0)
 }
 
-// uncomment once the cross project skipDeployment check is implemented
-// func TestValidationSkipDeploymentInterProjectWithMissingDependency(t *testing.T) {
-// 	statusCode := RunImpl([]string{
-// 		"monaco",
-// 		"-dry-run",
-// 		"--environments", skipDeploymentEnvironmentsFile,
-// 		"-project", "projectD",
-// 		skipDeploymentFolder,
-// 	}, util.CreateTestFileSystem())
-
-// 	assert.Assert(t, statusCode != 0)
-// }
+func TestValidationSkipDeploymentInterProjectWithMissingDependency(t *testing.T) {
+	statusCode := RunImpl([]string{
+		"monaco",
+		"--dry-run",
+		"--environments", skipDeploymentEnvironmentsFile,
+		"--project", "projectD",
+		skipDeploymentFolder,
+	}, util.CreateTestFileSystem())
+
+	assert.Assert(t, statusCode != 0)
+}
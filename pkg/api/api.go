@@ -40,6 +40,7 @@ var apiMap = map[string]apiInput{
 	"dashboard": {
 		apiPath:                      "/api/config/v1/dashboards",
 		propertyNameOfGetAllResponse: "dashboards",
+		behavior:                     dashboardBehavior{},
 	},
 	"notification": {
 		apiPath: "/api/config/v1/notifications",
@@ -47,6 +48,7 @@ var apiMap = map[string]apiInput{
 	"extension": {
 		apiPath:                      "/api/config/v1/extensions",
 		propertyNameOfGetAllResponse: "extensions",
+		behavior:                     extensionBehavior{},
 	},
 	"custom-service-java": {
 		apiPath: "/api/config/v1/service/customServices/java",
@@ -70,12 +72,14 @@ var apiMap = map[string]apiInput{
 	// Early adopter API !
 	// Environment API not Config API
 	"synthetic-location": {
-		apiPath: "/api/v1/synthetic/locations",
+		apiPath:  "/api/v1/synthetic/locations",
+		behavior: syntheticLocationBehavior{},
 	},
 	// Early adopter API !
 	// Environment API not Config API
 	"synthetic-monitor": {
-		apiPath: "/api/v1/synthetic/monitors",
+		apiPath:  "/api/v1/synthetic/monitors",
+		behavior: syntheticMonitorBehavior{},
 	},
 	"application": {
 		apiPath: "/api/config/v1/applications/web",
@@ -87,10 +91,12 @@ var apiMap = map[string]apiInput{
 		apiPath: "/api/config/v1/applications/mobile",
 	},
 	"app-detection-rule": {
-		apiPath: "/api/config/v1/applicationDetectionRules",
+		apiPath:  "/api/config/v1/applicationDetectionRules",
+		behavior: appDetectionRuleBehavior{},
 	},
 	"aws-credentials": {
-		apiPath: "/api/config/v1/aws/credentials",
+		apiPath:  "/api/config/v1/aws/credentials",
+		behavior: awsCredentialsBehavior{},
 	},
 	// Early adopter API !
 	"kubernetes-credentials": {
@@ -109,7 +115,8 @@ var apiMap = map[string]apiInput{
 	},
 	// Early adopter API !
 	"calculated-metrics-log": {
-		apiPath: "/api/config/v1/calculatedMetrics/log",
+		apiPath:  "/api/config/v1/calculatedMetrics/log",
+		behavior: calculatedMetricsLogBehavior{},
 	},
 
 	"conditional-naming-processgroup": {
@@ -151,24 +158,29 @@ type Api interface {
 	GetApiPath() string
 	GetPropertyNameOfGetAllResponse() string
 	IsStandardApi() bool
+	GetBehavior() Behavior
 }
 
 type apiInput struct {
 	apiPath                      string
 	propertyNameOfGetAllResponse string
+	// behavior is only set for APIs deviating from the StandardBehavior
+	behavior Behavior
 }
 
 type apiImpl struct {
 	id                           string
 	apiPath                      string
 	propertyNameOfGetAllResponse string
+	behavior                     Behavior
 }
 
 func NewApis() map[string]Api {
 
 	apis := make(map[string]Api)
 
-	for id, details := range apiMap {
+	for _, id := range allApiIds() {
+		details, _ := lookupApi(id)
 		apis[id] = newApi(id, details)
 	}
 
@@ -189,16 +201,24 @@ func NewStandardApi(id string, apiPath string) Api {
 
 func NewApi(id string, apiPath string, propertyNameOfGetAllResponse string) Api {
 
-	// TODO log warning if the user tries to create an API with a id not present in map above
-	// This means that a user runs monaco with an untested api
+	warnIfUntested(id)
 
 	return &apiImpl{
 		id:                           id,
 		apiPath:                      apiPath,
 		propertyNameOfGetAllResponse: propertyNameOfGetAllResponse,
+		behavior:                     behaviorOf(id),
 	}
 }
 
+// behaviorOf returns the Behavior registered for the API with the given id, or the StandardBehavior
+func behaviorOf(id string) Behavior {
+	if input, found := lookupApi(id); found && input.behavior != nil {
+		return input.behavior
+	}
+	return StandardBehavior{}
+}
+
 func (a *apiImpl) GetUrl(environment environment.Environment) string {
 	return environment.GetEnvironmentUrl() + a.apiPath
 }
@@ -223,15 +243,19 @@ func (a *apiImpl) IsStandardApi() bool {
 	return a.propertyNameOfGetAllResponse == standardApiPropertyNameOfGetAllResponse
 }
 
+func (a *apiImpl) GetBehavior() Behavior {
+	return a.behavior
+}
+
 func IsApi(dir string) bool {
-	_, ok := apiMap[dir]
+	_, ok := lookupApi(dir)
 	return ok
 }
 
 // tests if part of project folder path contains an API
 // folders with API in path are not valid projects
 func ContainsApiName(path string) bool {
-	for api := range apiMap {
+	for _, api := range allApiIds() {
 		if strings.Contains(path, api) {
 			return true
 		}
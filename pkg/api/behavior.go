@@ -0,0 +1,325 @@
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+)
+
+// Behavior describes how the requests of an API are built and how its responses are parsed. Most APIs use the
+// StandardBehavior, APIs deviating from it register their own implementation in the apiMap.
+type Behavior interface {
+
+	// ParseList returns the existing objects contained in a response of the list endpoint of the API
+	ParseList(theApi Api, body []byte) ([]Value, error)
+
+	// CreateRequest returns the method and url of the request creating a new object with the given name
+	CreateRequest(url string, objectName string) (method string, createUrl string)
+
+	// UpdatePayload returns the payload of the request updating the existing object with the given id
+	UpdatePayload(payload []byte, existingObjectId string) []byte
+
+	// ParseCreateResponse returns the object created by a create request, extracting its id from the response
+	ParseCreateResponse(url string, objectName string, headers map[string][]string, body []byte) (DynatraceEntity, error)
+
+	// IsUpload is true, if objects aren't created and updated, but uploaded as archive and replaced by name
+	IsUpload() bool
+}
+
+// StandardBehavior lists the objects of an API by the property GetPropertyNameOfGetAllResponse of the list
+// response, creates them by POST and reads the id of a created object from the Location header or the response.
+type StandardBehavior struct{}
+
+func (StandardBehavior) ParseList(theApi Api, body []byte) ([]Value, error) {
+
+	if theApi.IsStandardApi() {
+		var jsonResponse ValuesResponse
+		err := json.Unmarshal(body, &jsonResponse)
+		if util.CheckError(err, "Cannot unmarshal API response for existing objects") {
+			return nil, err
+		}
+		return jsonResponse.Values, nil
+	}
+
+	var objmap map[string]interface{}
+	if err := json.Unmarshal(body, &objmap); err != nil {
+		return nil, err
+	}
+
+	if array, available := objmap[theApi.GetPropertyNameOfGetAllResponse()].([]interface{}); available {
+		return translateGenericValues(array, theApi.GetId())
+	}
+	return nil, nil
+}
+
+func (StandardBehavior) CreateRequest(url string, _ string) (string, string) {
+	return http.MethodPost, url
+}
+
+func (StandardBehavior) UpdatePayload(payload []byte, _ string) []byte {
+	return payload
+}
+
+func (StandardBehavior) ParseCreateResponse(url string, objectName string, headers map[string][]string, body []byte) (DynatraceEntity, error) {
+
+	if location, available := headers["Location"]; available {
+
+		// The POST of the SLO API does not return the ID of the config in its response. Instead, it contains a
+		// Location header, which contains the URL to the created resource. This URL needs to be cleaned, to get the
+		// ID of the config.
+
+		if len(location) == 0 {
+			return DynatraceEntity{}, fmt.Errorf("location response header was empty (name: %s)", objectName)
+		}
+
+		// Some APIs prepend the environment URL. If available, trim it from the location
+		id := strings.TrimPrefix(location[0], url)
+		id = strings.TrimPrefix(id, "/")
+
+		return DynatraceEntity{
+			Id:          id,
+			Name:        objectName,
+			Description: "Created object",
+		}, nil
+	}
+
+	var dtEntity DynatraceEntity
+	err := json.Unmarshal(body, &dtEntity)
+	if util.CheckError(err, "Cannot unmarshal API response") {
+		return DynatraceEntity{}, err
+	}
+	return dtEntity, nil
+}
+
+func (StandardBehavior) IsUpload() bool {
+	return false
+}
+
+// dashboardBehavior adds the id to the payload of updates, as the dashboard API requires it to be contained
+type dashboardBehavior struct {
+	StandardBehavior
+}
+
+func (dashboardBehavior) UpdatePayload(payload []byte, existingObjectId string) []byte {
+	return []byte(strings.Replace(string(payload), "{", "{\n\"id\":\""+existingObjectId+"\",\n", 1))
+}
+
+// extensionBehavior uploads extensions as zip archive. The upload replaces an existing extension of the same name.
+type extensionBehavior struct {
+	StandardBehavior
+}
+
+func (extensionBehavior) IsUpload() bool {
+	return true
+}
+
+// syntheticBehavior handles the synthetic APIs, which return the entity id of created objects
+type syntheticBehavior struct {
+	StandardBehavior
+}
+
+// syntheticLocationBehavior lists the synthetic locations by their entity ids
+type syntheticLocationBehavior struct {
+	syntheticBehavior
+}
+
+func (syntheticLocationBehavior) ParseList(_ Api, body []byte) ([]Value, error) {
+
+	var jsonResp SyntheticLocationResponse
+	err := json.Unmarshal(body, &jsonResp)
+	if util.CheckError(err, "Cannot unmarshal API response for existing synthetic location") {
+		return nil, err
+	}
+	return translateSyntheticValues(jsonResp.Locations), nil
+}
+
+// syntheticMonitorBehavior lists the synthetic monitors by their entity ids
+type syntheticMonitorBehavior struct {
+	syntheticBehavior
+}
+
+func (syntheticMonitorBehavior) ParseList(_ Api, body []byte) ([]Value, error) {
+
+	var jsonResp SyntheticMonitorsResponse
+	err := json.Unmarshal(body, &jsonResp)
+	if util.CheckError(err, "Cannot unmarshal API response for existing synthetic monitor") {
+		return nil, err
+	}
+	return translateSyntheticValues(jsonResp.Monitors), nil
+}
+
+func (syntheticBehavior) ParseCreateResponse(_ string, objectName string, _ map[string][]string, body []byte) (DynatraceEntity, error) {
+
+	var entity SyntheticEntity
+	err := json.Unmarshal(body, &entity)
+	if util.CheckError(err, "Cannot unmarshal Synthetic API response") {
+		return DynatraceEntity{}, err
+	}
+	return DynatraceEntity{
+		Name: objectName,
+		Id:   entity.EntityId,
+	}, nil
+}
+
+// appDetectionRuleBehavior prepends new rules, so that they take precedence over the existing ones
+type appDetectionRuleBehavior struct {
+	StandardBehavior
+}
+
+func (appDetectionRuleBehavior) CreateRequest(url string, _ string) (string, string) {
+	return http.MethodPost, url + "?position=PREPEND"
+}
+
+// awsCredentialsBehavior handles the aws credentials API, which lists its objects as untyped array
+type awsCredentialsBehavior struct {
+	StandardBehavior
+}
+
+func (awsCredentialsBehavior) ParseList(_ Api, body []byte) ([]Value, error) {
+
+	var values []Value
+	err := json.Unmarshal(body, &values)
+	if util.CheckError(err, "Cannot unmarshal API response for existing aws-credentials") {
+		return nil, err
+	}
+	return values, nil
+}
+
+// calculatedMetricsLogBehavior handles the calculated-metrics-log API, which doesn't have a POST endpoint. To create
+// a new log metric, PUT is used with the metric key, for which we can just take the name of the object.
+type calculatedMetricsLogBehavior struct {
+	StandardBehavior
+}
+
+func (calculatedMetricsLogBehavior) CreateRequest(url string, objectName string) (string, string) {
+	return http.MethodPut, strings.TrimSuffix(url, "/") + "/" + objectName
+}
+
+func (calculatedMetricsLogBehavior) ParseCreateResponse(_ string, objectName string, _ map[string][]string, _ []byte) (DynatraceEntity, error) {
+	return DynatraceEntity{
+		Id:          objectName,
+		Name:        objectName,
+		Description: "Created object",
+	}, nil
+}
+
+// userApiBehavior handles APIs defined in an apis.yaml, whose objects contain their id and name in other properties
+type userApiBehavior struct {
+	StandardBehavior
+	idField   string
+	nameField string
+}
+
+func (b userApiBehavior) ParseList(theApi Api, body []byte) ([]Value, error) {
+
+	var objmap map[string]interface{}
+	if err := json.Unmarshal(body, &objmap); err != nil {
+		return nil, err
+	}
+
+	array, _ := objmap[theApi.GetPropertyNameOfGetAllResponse()].([]interface{})
+	return translateValues(array, theApi.GetId(), b.idField, b.nameField)
+}
+
+func (b userApiBehavior) ParseCreateResponse(url string, objectName string, headers map[string][]string, body []byte) (DynatraceEntity, error) {
+
+	if _, available := headers["Location"]; available {
+		return b.StandardBehavior.ParseCreateResponse(url, objectName, headers, body)
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal(body, &object); err != nil {
+		return DynatraceEntity{}, err
+	}
+	if object[b.idField] == nil {
+		return DynatraceEntity{}, fmt.Errorf("response doesn't contain the id property %s", b.idField)
+	}
+	return DynatraceEntity{
+		Id:          stringOf(object[b.idField]),
+		Name:        objectName,
+		Description: "Created object",
+	}, nil
+}
+
+func translateGenericValues(inputValues []interface{}, configType string) ([]Value, error) {
+	return translateValues(inputValues, configType, "id", "name")
+}
+
+// translateValues converts the objects of a list response into values, reading their id and name from
+// the given properties
+func translateValues(inputValues []interface{}, configType string, idField string, nameField string) ([]Value, error) {
+
+	numValues := len(inputValues)
+	values := make([]Value, numValues, numValues)
+
+	for i := 0; i < numValues; i++ {
+		input := inputValues[i].(map[string]interface{})
+
+		if input[idField] == nil {
+			return values, fmt.Errorf("config of type %s was invalid: No %s", configType, idField)
+		}
+
+		// repair invalid configs - but log them
+		if input[nameField] == nil {
+			jsonStr, err := json.Marshal(input)
+			if err != nil {
+				util.Log.Warn("Config of type %s was invalid. Ignoring it!", configType)
+				continue
+			}
+
+			util.Log.Warn("Config of type %s was invalid. Auto-corrected to use ID as name!\nInvalid config: %s", configType, string(jsonStr))
+
+			values[i] = Value{
+				Id:   stringOf(input[idField]),
+				Name: stringOf(input[idField]), // use the id as name
+			}
+			continue
+		}
+
+		values[i] = Value{
+			Id:   stringOf(input[idField]),
+			Name: stringOf(input[nameField]),
+		}
+	}
+	return values, nil
+}
+
+// stringOf returns the given json value as string. Ids of some APIs are numbers.
+func stringOf(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+func translateSyntheticValues(syntheticValues []SyntheticValue) []Value {
+	numValues := len(syntheticValues)
+	values := make([]Value, numValues, numValues)
+	for i := 0; i < numValues; i++ {
+		loc := syntheticValues[i]
+		values[i] = Value{
+			Id:   loc.EntityId,
+			Name: loc.Name,
+		}
+	}
+	return values
+}
@@ -0,0 +1,163 @@
+// +build unit
+
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestTranslateGenericValuesOnStandardResponse(t *testing.T) {
+
+	entry := make(map[string]interface{})
+	entry["id"] = "foo"
+	entry["name"] = "bar"
+
+	response := make([]interface{}, 1)
+	response[0] = entry
+
+	values, err := translateGenericValues(response, "extensions")
+
+	assert.NilError(t, err)
+	assert.Check(t, len(values) == 1)
+
+	assert.Equal(t, values[0].Id, "foo")
+	assert.Equal(t, values[0].Name, "bar")
+}
+
+func TestTranslateGenericValuesOnIdMissing(t *testing.T) {
+
+	entry := make(map[string]interface{})
+	entry["name"] = "bar"
+
+	response := make([]interface{}, 1)
+	response[0] = entry
+
+	_, err := translateGenericValues(response, "extensions")
+
+	assert.ErrorContains(t, err, "config of type extensions was invalid: No id")
+}
+
+func TestTranslateGenericValuesOnNameMissing(t *testing.T) {
+
+	entry := make(map[string]interface{})
+	entry["id"] = "foo"
+
+	response := make([]interface{}, 1)
+	response[0] = entry
+
+	values, err := translateGenericValues(response, "extensions")
+
+	assert.NilError(t, err)
+	assert.Check(t, len(values) == 1)
+
+	assert.Equal(t, values[0].Id, "foo")
+	assert.Equal(t, values[0].Name, "foo")
+}
+
+func TestApisUseRegisteredBehavior(t *testing.T) {
+
+	apis := NewApis()
+
+	assert.Equal(t, apis["dashboard"].GetBehavior(), Behavior(dashboardBehavior{}))
+	assert.Equal(t, apis["extension"].GetBehavior().IsUpload(), true)
+	assert.Equal(t, apis["management-zone"].GetBehavior(), Behavior(StandardBehavior{}))
+
+	// the behavior depends on the id, not on how the API is created
+	assert.Equal(t, testDashboardApi.GetBehavior(), Behavior(dashboardBehavior{}))
+}
+
+func TestStandardBehaviorParsesList(t *testing.T) {
+
+	values, err := StandardBehavior{}.ParseList(testManagementZoneApi, []byte(`{"values": [{"id": "zone-id", "name": "Zone"}]}`))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []Value{{Id: "zone-id", Name: "Zone"}})
+
+	dashboardApi := NewApi("dashboard", "/api/config/v1/dashboards", "dashboards")
+	values, err = StandardBehavior{}.ParseList(dashboardApi, []byte(`{"dashboards": [{"id": "dashboard-id", "name": "Dashboard"}]}`))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []Value{{Id: "dashboard-id", Name: "Dashboard"}})
+}
+
+func TestStandardBehaviorReadsIdOfCreatedObjectFromLocationHeader(t *testing.T) {
+
+	headers := map[string][]string{"Location": {"https://url/to/dev/environment/api/v2/slo/slo-id"}}
+
+	entity, err := StandardBehavior{}.ParseCreateResponse("https://url/to/dev/environment/api/v2/slo", "SLO", headers, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, entity.Id, "slo-id")
+	assert.Equal(t, entity.Name, "SLO")
+}
+
+func TestStandardBehaviorReadsIdOfCreatedObjectFromResponse(t *testing.T) {
+
+	entity, err := StandardBehavior{}.ParseCreateResponse("https://url/to/dev/environment/api/config/v1/managementZones", "Zone", nil, []byte(`{"id": "zone-id", "name": "Zone"}`))
+	assert.NilError(t, err)
+	assert.Equal(t, entity.Id, "zone-id")
+}
+
+func TestDashboardBehaviorAddsIdToUpdatePayload(t *testing.T) {
+
+	payload := dashboardBehavior{}.UpdatePayload([]byte(`{"dashboardMetadata": {}}`), "dashboard-id")
+	assert.Equal(t, string(payload), "{\n\"id\":\"dashboard-id\",\n\"dashboardMetadata\": {}}")
+}
+
+func TestSyntheticBehaviorParsesEntityIds(t *testing.T) {
+
+	behavior := syntheticMonitorBehavior{}
+	monitorApi := NewStandardApi("synthetic-monitor", "/api/v1/synthetic/monitors")
+
+	values, err := behavior.ParseList(monitorApi, []byte(`{"monitors": [{"name": "Monitor", "entityId": "SYNTHETIC_TEST-1"}]}`))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []Value{{Id: "SYNTHETIC_TEST-1", Name: "Monitor"}})
+
+	entity, err := behavior.ParseCreateResponse("", "Monitor", nil, []byte(`{"entityId": "SYNTHETIC_TEST-2"}`))
+	assert.NilError(t, err)
+	assert.Equal(t, entity.Id, "SYNTHETIC_TEST-2")
+	assert.Equal(t, entity.Name, "Monitor")
+}
+
+func TestSyntheticBehaviorIgnoresOtherProperties(t *testing.T) {
+
+	locationApi := NewStandardApi("synthetic-location", "/api/v1/synthetic/locations")
+	values, err := syntheticLocationBehavior{}.ParseList(locationApi, []byte(`{"locations": [{"name": "Location", "entityId": "SYNTHETIC_LOCATION-1"}], "totalCount": 1}`))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []Value{{Id: "SYNTHETIC_LOCATION-1", Name: "Location"}})
+
+	monitorApi := NewStandardApi("synthetic-monitor", "/api/v1/synthetic/monitors")
+	values, err = syntheticMonitorBehavior{}.ParseList(monitorApi, []byte(`{"monitors": [], "nextPageKey": "page-2"}`))
+	assert.NilError(t, err)
+	assert.Equal(t, len(values), 0)
+}
+
+func TestAwsCredentialsBehaviorParsesUntypedList(t *testing.T) {
+
+	values, err := awsCredentialsBehavior{}.ParseList(nil, []byte(`[{"id": "aws-id", "name": "AWS"}]`))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []Value{{Id: "aws-id", Name: "AWS"}})
+}
+
+func TestCalculatedMetricsLogBehaviorCreatesByPut(t *testing.T) {
+
+	method, url := calculatedMetricsLogBehavior{}.CreateRequest("https://url/to/dev/environment/api/config/v1/calculatedMetrics/log", "log.metric")
+	assert.Equal(t, method, http.MethodPut)
+	assert.Equal(t, url, "https://url/to/dev/environment/api/config/v1/calculatedMetrics/log/log.metric")
+}
@@ -52,4 +52,10 @@ type DynatraceEntity struct {
 	Id          string `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	// Unchanged is true, if an upsert skipped the update because the existing object already matched the payload
+	Unchanged bool `json:"-"`
+	// Created is true, if an upsert created a new object
+	Created bool `json:"-"`
+	// StatusCode is the HTTP status of the response which created or updated the object
+	StatusCode int `json:"-"`
 }
@@ -0,0 +1,164 @@
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/spf13/afero"
+)
+
+// UserApisFile is the file in the working directory which defines additional APIs
+const UserApisFile = "apis.yaml"
+
+// userApiMap contains the APIs loaded by the last call of LoadUserApis. They are used like the APIs of the apiMap,
+// but as they are not tested with monaco, a warning is logged when they are used.
+var userApiMap = map[string]apiInput{}
+
+var (
+	untestedApisMutex  sync.Mutex
+	warnedUntestedApis = map[string]bool{}
+)
+
+// LoadUserApis loads additional APIs from the given file, or from the apis.yaml of the working directory if no
+// file is given. Each API is defined by a section named after its id:
+//
+//	my-api:
+//	  - apiPath: "/api/config/v1/myApi"
+//	  - propertyNameOfGetAllResponse: "items"
+//	  - idField: "key"
+//	  - nameField: "displayName"
+//
+// Only apiPath is mandatory. propertyNameOfGetAllResponse defaults to "values", idField and nameField name the
+// properties of the objects containing their id and name and default to "id" and "name".
+// The loaded APIs replace those of a previous call. A working directory without apis.yaml doesn't define any APIs.
+func LoadUserApis(fs afero.Fs, workingDir string, file string) error {
+
+	userApiMap = map[string]apiInput{}
+
+	if file == "" {
+		file = filepath.Join(workingDir, UserApisFile)
+		if _, err := fs.Stat(file); os.IsNotExist(err) {
+			return nil
+		}
+	}
+
+	data, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return err
+	}
+
+	err, sections := util.UnmarshalYaml(string(data), file)
+	if err != nil {
+		return err
+	}
+
+	apis, err := parseUserApis(sections)
+	if err != nil {
+		return fmt.Errorf("invalid API definition in %s: %w", file, err)
+	}
+
+	userApiMap = apis
+	util.Log.Info("Loaded %d API(s) from %s", len(apis), file)
+	return nil
+}
+
+func parseUserApis(sections map[string]map[string]string) (map[string]apiInput, error) {
+
+	apis := make(map[string]apiInput, len(sections))
+
+	for id, properties := range sections {
+
+		if _, builtIn := apiMap[id]; builtIn {
+			return nil, fmt.Errorf("API %s is already built in", id)
+		}
+		if strings.ContainsAny(id, `/\`) {
+			return nil, fmt.Errorf("API id %s must not contain path separators", id)
+		}
+
+		input := apiInput{}
+		behavior := userApiBehavior{idField: "id", nameField: "name"}
+
+		for name, value := range properties {
+			switch name {
+			case "apiPath":
+				input.apiPath = value
+			case "propertyNameOfGetAllResponse":
+				input.propertyNameOfGetAllResponse = value
+			case "idField":
+				behavior.idField = value
+			case "nameField":
+				behavior.nameField = value
+			default:
+				return nil, fmt.Errorf("unknown property %s of API %s, supported properties are %s", name, id,
+					"apiPath, propertyNameOfGetAllResponse, idField and nameField")
+			}
+		}
+
+		if !strings.HasPrefix(input.apiPath, "/") {
+			return nil, fmt.Errorf("apiPath of API %s must start with /, but was '%s'", id, input.apiPath)
+		}
+		if behavior.idField != "id" || behavior.nameField != "name" {
+			input.behavior = behavior
+		}
+		apis[id] = input
+	}
+	return apis, nil
+}
+
+// lookupApi returns the definition of the built-in or user-defined API with the given id
+func lookupApi(id string) (input apiInput, found bool) {
+	if input, found = apiMap[id]; found {
+		return input, true
+	}
+	input, found = userApiMap[id]
+	return input, found
+}
+
+// allApiIds returns the ids of the built-in and user-defined APIs
+func allApiIds() []string {
+	ids := make([]string, 0, len(apiMap)+len(userApiMap))
+	for id := range apiMap {
+		ids = append(ids, id)
+	}
+	for id := range userApiMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// warnIfUntested logs a warning the first time an API is used, which is not built into monaco
+func warnIfUntested(id string) {
+	if _, tested := apiMap[id]; tested {
+		return
+	}
+
+	untestedApisMutex.Lock()
+	defer untestedApisMutex.Unlock()
+
+	if !warnedUntestedApis[id] {
+		warnedUntestedApis[id] = true
+		util.Log.Warn("API %s is not built into monaco and therefore untested. Please verify its configs are deployed correctly.", id)
+	}
+}
@@ -0,0 +1,132 @@
+// +build unit
+
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"gotest.tools/assert"
+)
+
+const testUserApis = `
+network-zone:
+  - apiPath: "/api/v2/networkZones"
+  - propertyNameOfGetAllResponse: "networkZones"
+custom-metric:
+  - apiPath: "/api/config/v1/customMetrics"
+  - propertyNameOfGetAllResponse: "metrics"
+  - idField: "key"
+  - nameField: "displayName"
+`
+
+func loadTestUserApis(t *testing.T, content string) error {
+	fs := afero.NewMemMapFs()
+	assert.NilError(t, afero.WriteFile(fs, "project/apis.yaml", []byte(content), 0644))
+	return LoadUserApis(fs, "project", "")
+}
+
+func resetUserApis() {
+	userApiMap = map[string]apiInput{}
+}
+
+func TestLoadUserApisFromWorkingDirectory(t *testing.T) {
+	defer resetUserApis()
+
+	err := loadTestUserApis(t, testUserApis)
+	assert.NilError(t, err)
+
+	assert.Assert(t, IsApi("network-zone"))
+	assert.Assert(t, IsApi("custom-metric"))
+	assert.Assert(t, ContainsApiName("/project/network-zone/subfolder"))
+
+	apis := NewApis()
+	assert.Equal(t, len(apis), len(apiMap)+2)
+
+	networkZone := apis["network-zone"]
+	assert.Equal(t, networkZone.GetUrl(testDevEnvironment), "https://url/to/dev/environment/api/v2/networkZones")
+	assert.Equal(t, networkZone.GetPropertyNameOfGetAllResponse(), "networkZones")
+	assert.Equal(t, networkZone.GetBehavior(), Behavior(StandardBehavior{}))
+
+	customMetric := apis["custom-metric"]
+	assert.Equal(t, customMetric.GetBehavior(), Behavior(userApiBehavior{idField: "key", nameField: "displayName"}))
+}
+
+func TestLoadUserApisWithoutFile(t *testing.T) {
+	defer resetUserApis()
+
+	err := LoadUserApis(afero.NewMemMapFs(), "project", "")
+	assert.NilError(t, err)
+	assert.Equal(t, len(NewApis()), len(apiMap))
+}
+
+func TestLoadUserApisReplacesPreviouslyLoadedApis(t *testing.T) {
+	defer resetUserApis()
+
+	err := loadTestUserApis(t, testUserApis)
+	assert.NilError(t, err)
+
+	err = loadTestUserApis(t, "tag-rule:\n  - apiPath: \"/api/v2/tags\"\n")
+	assert.NilError(t, err)
+	assert.Assert(t, IsApi("tag-rule"))
+	assert.Assert(t, !IsApi("network-zone"))
+
+	err = LoadUserApis(afero.NewMemMapFs(), "project", "")
+	assert.NilError(t, err)
+	assert.Assert(t, !IsApi("tag-rule"))
+}
+
+func TestLoadUserApisFailsIfGivenFileIsMissing(t *testing.T) {
+	defer resetUserApis()
+
+	err := LoadUserApis(afero.NewMemMapFs(), "project", "missing.yaml")
+	assert.ErrorContains(t, err, "missing.yaml")
+}
+
+func TestLoadUserApisFailsOnInvalidDefinitions(t *testing.T) {
+	defer resetUserApis()
+
+	err := loadTestUserApis(t, "dashboard:\n  - apiPath: \"/api/config/v1/dashboards\"\n")
+	assert.ErrorContains(t, err, "API dashboard is already built in")
+
+	err = loadTestUserApis(t, "network-zone:\n  - propertyNameOfGetAllResponse: \"networkZones\"\n")
+	assert.ErrorContains(t, err, "apiPath of API network-zone must start with /")
+
+	err = loadTestUserApis(t, "network-zone:\n  - apiPath: \"/api/v2/networkZones\"\n  - idfield: \"key\"\n")
+	assert.ErrorContains(t, err, "unknown property idfield of API network-zone")
+
+	assert.Assert(t, !IsApi("network-zone"))
+}
+
+func TestUserApiBehaviorUsesIdAndNameFields(t *testing.T) {
+	behavior := userApiBehavior{idField: "key", nameField: "displayName"}
+	metricApi := NewApi("custom-metric", "/api/config/v1/customMetrics", "metrics")
+
+	values, err := behavior.ParseList(metricApi, []byte(`{"metrics": [{"key": "metric.a", "displayName": "Metric A"}, {"key": 42, "displayName": "Metric B"}]}`))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []Value{{Id: "metric.a", Name: "Metric A"}, {Id: "42", Name: "Metric B"}})
+
+	entity, err := behavior.ParseCreateResponse("", "Metric C", nil, []byte(`{"key": "metric.c"}`))
+	assert.NilError(t, err)
+	assert.Equal(t, entity.Id, "metric.c")
+	assert.Equal(t, entity.Name, "Metric C")
+
+	_, err = behavior.ParseCreateResponse("", "Metric D", nil, []byte(`{"id": "metric.d"}`))
+	assert.ErrorContains(t, err, "response doesn't contain the id property key")
+}
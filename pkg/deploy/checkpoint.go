@@ -0,0 +1,234 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/spf13/afero"
+)
+
+// checkpoint records the progress of the deployment to an environment, so that a failed deployment can be resumed.
+// Each successfully deployed config is appended to the checkpoint file. It is safe for concurrent use.
+type checkpoint struct {
+	mutex          sync.Mutex
+	fs             afero.Fs
+	file           string
+	environmentUrl string
+	finished       map[string]checkpointEntry
+	// created is true once the file has been written by this checkpoint or has been loaded, so entries can be appended
+	created bool
+}
+
+// checkpointHeader is the first line of a checkpoint file
+type checkpointHeader struct {
+	EnvironmentUrl string `json:"environmentUrl"`
+}
+
+// checkpointEntry is a line of a checkpoint file, recording a finished config. The entity is needed to resolve
+// references to the config, the payload hash to detect configs which have been changed since they have been deployed.
+type checkpointEntry struct {
+	Config      string              `json:"config"`
+	PayloadHash string              `json:"payloadHash"`
+	Entity      api.DynatraceEntity `json:"entity"`
+}
+
+// checkpointFile returns the path of the checkpoint file of an environment: <workingDir>/.monaco/checkpoints/<environment>.jsonl
+func checkpointFile(workingDir string, environmentId string) string {
+	return filepath.Join(workingDir, ".monaco", "checkpoints", environmentId+".jsonl")
+}
+
+func newCheckpoint(fs afero.Fs, file string, environmentUrl string) *checkpoint {
+	return &checkpoint{
+		fs:             fs,
+		file:           file,
+		environmentUrl: environmentUrl,
+		finished:       make(map[string]checkpointEntry),
+	}
+}
+
+// loadCheckpoint reads the checkpoint file. If it does not exist, an empty checkpoint is returned. Loading fails if
+// the checkpoint has been written for a different environment url.
+func loadCheckpoint(fs afero.Fs, file string, environmentUrl string) (*checkpoint, error) {
+
+	checkpoint := newCheckpoint(fs, file, environmentUrl)
+
+	content, err := afero.ReadFile(fs, file)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+
+	var header checkpointHeader
+	err = decoder.Decode(&header)
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint %s is not valid: %s", file, err)
+	}
+
+	if header.EnvironmentUrl != environmentUrl {
+		return nil, fmt.Errorf("checkpoint %s has been written for %s, but the environment url is %s",
+			file, header.EnvironmentUrl, environmentUrl)
+	}
+
+	for {
+		var entry checkpointEntry
+		err = decoder.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("checkpoint %s is not valid: %s", file, err)
+		}
+		checkpoint.finished[entry.Config] = entry
+	}
+
+	checkpoint.created = true
+	return checkpoint, nil
+}
+
+// entities returns the deployed entities of all finished configs
+func (c *checkpoint) entities() map[string]api.DynatraceEntity {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entities := make(map[string]api.DynatraceEntity, len(c.finished))
+	for configId, entry := range c.finished {
+		entities[configId] = entry.Entity
+	}
+	return entities
+}
+
+// isFinished is true if the config has been deployed with the payload of the given hash
+func (c *checkpoint) isFinished(configId string, payloadHash string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, found := c.finished[configId]
+	return found && entry.PayloadHash == payloadHash
+}
+
+// markFinished records the config as finished and appends it to the checkpoint file
+func (c *checkpoint) markFinished(configId string, payloadHash string, entity api.DynatraceEntity) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := checkpointEntry{
+		Config:      configId,
+		PayloadHash: payloadHash,
+		Entity:      entity,
+	}
+	c.finished[configId] = entry
+
+	if !c.created {
+		return c.write()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := c.fs.OpenFile(c.file, os.O_APPEND|os.O_WRONLY, 0664)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// forget removes the given configs from the finished ones, e.g. because their deployment has been rolled back,
+// and rewrites the checkpoint file
+func (c *checkpoint) forget(configIds ...string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, configId := range configIds {
+		delete(c.finished, configId)
+	}
+	return c.write()
+}
+
+// reset removes all finished configs and replaces the checkpoint file by an empty one, so that the checkpoint of a
+// previous deployment can't be resumed anymore
+func (c *checkpoint) reset() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.finished = make(map[string]checkpointEntry)
+	return c.write()
+}
+
+// write replaces the checkpoint file by the header and all finished configs
+func (c *checkpoint) write() error {
+
+	configIds := make([]string, 0, len(c.finished))
+	for configId := range c.finished {
+		configIds = append(configIds, configId)
+	}
+	sort.Strings(configIds)
+
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+
+	err := encoder.Encode(checkpointHeader{EnvironmentUrl: c.environmentUrl})
+	if err != nil {
+		return err
+	}
+	for _, configId := range configIds {
+		err = encoder.Encode(c.finished[configId])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.fs.MkdirAll(filepath.Dir(c.file), 0777)
+	if err != nil {
+		return err
+	}
+
+	err = afero.WriteFile(c.fs, c.file, content.Bytes(), 0664)
+	if err != nil {
+		return err
+	}
+	c.created = true
+	return nil
+}
+
+// remove deletes the checkpoint file, once the deployment finished successfully
+func (c *checkpoint) remove() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.fs.Remove(c.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,134 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/state"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/spf13/afero"
+	"gotest.tools/assert"
+)
+
+const checkpointTestUrl = "https://url/to/dev/environment"
+
+func TestCheckpointCanBeResumed(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	file := checkpointFile("projects", "dev")
+
+	progress := newCheckpoint(fs, file, checkpointTestUrl)
+	assert.NilError(t, progress.markFinished("project/management-zone/zone", "zone-hash", api.DynatraceEntity{Id: "zone-id", Name: "Zone"}))
+	assert.NilError(t, progress.markFinished("project/extension/extension", "extension-hash", api.DynatraceEntity{}))
+	assert.NilError(t, progress.markFinished("project/dashboard/overview", "overview-hash", api.DynatraceEntity{Id: "dashboard-id", Name: "Overview"}))
+	assert.NilError(t, progress.forget("project/dashboard/overview"))
+
+	resumed, err := loadCheckpoint(fs, file, checkpointTestUrl)
+	assert.NilError(t, err)
+	assert.Assert(t, resumed.isFinished("project/management-zone/zone", "zone-hash"))
+	assert.Assert(t, resumed.isFinished("project/extension/extension", "extension-hash"))
+	assert.Assert(t, !resumed.isFinished("project/dashboard/overview", "overview-hash"))
+	assert.Equal(t, resumed.entities()["project/management-zone/zone"].Id, "zone-id")
+
+	// configs changed since their deployment are not finished
+	assert.Assert(t, !resumed.isFinished("project/management-zone/zone", "changed-zone-hash"))
+
+	assert.NilError(t, resumed.remove())
+	_, err = fs.Stat(file)
+	assert.Assert(t, err != nil)
+}
+
+func TestCheckpointAppendsFinishedConfigs(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	file := checkpointFile("projects", "dev")
+
+	progress := newCheckpoint(fs, file, checkpointTestUrl)
+	assert.NilError(t, progress.markFinished("project/management-zone/zone", "zone-hash", api.DynatraceEntity{Id: "zone-id", Name: "Zone"}))
+
+	resumed, err := loadCheckpoint(fs, file, checkpointTestUrl)
+	assert.NilError(t, err)
+	assert.NilError(t, resumed.markFinished("project/dashboard/overview", "overview-hash", api.DynatraceEntity{Id: "dashboard-id", Name: "Overview"}))
+
+	content, err := afero.ReadFile(fs, file)
+	assert.NilError(t, err)
+	assert.Equal(t, string(content), `{"environmentUrl":"https://url/to/dev/environment"}
+{"config":"project/management-zone/zone","payloadHash":"zone-hash","entity":{"id":"zone-id","name":"Zone","description":""}}
+{"config":"project/dashboard/overview","payloadHash":"overview-hash","entity":{"id":"dashboard-id","name":"Overview","description":""}}
+`)
+}
+
+func TestLoadCheckpointWithoutFile(t *testing.T) {
+	progress, err := loadCheckpoint(afero.NewMemMapFs(), checkpointFile("projects", "dev"), checkpointTestUrl)
+	assert.NilError(t, err)
+	assert.Equal(t, len(progress.entities()), 0)
+}
+
+func TestLoadCheckpointFailsForOtherEnvironmentUrl(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	file := checkpointFile("projects", "dev")
+
+	progress := newCheckpoint(fs, file, "https://url/to/other/environment")
+	assert.NilError(t, progress.markFinished("project/management-zone/zone", "zone-hash", api.DynatraceEntity{Id: "zone-id", Name: "Zone"}))
+
+	_, err := loadCheckpoint(fs, file, checkpointTestUrl)
+	assert.ErrorContains(t, err, "has been written for https://url/to/other/environment")
+}
+
+func TestExecuteSkipsFinishedConfigsAndResolvesTheirReferences(t *testing.T) {
+	projects := []project.Project{loadDependencyGraphTestProject(t)}
+	configs := indexConfigs(projects)
+
+	zonePayload, err := configs[dependencyGraphTestPath+"/project/management-zone/zone"].GetConfigForEnvironment(stateTestEnvironment, map[string]api.DynatraceEntity{})
+	assert.NilError(t, err)
+
+	progress := newCheckpoint(afero.NewMemMapFs(), checkpointFile("projects", "dev"), checkpointTestUrl)
+	assert.NilError(t, progress.markFinished("project/management-zone/zone", state.HashPayload(zonePayload), api.DynatraceEntity{Id: "zone-id", Name: "Zone"}))
+	assert.NilError(t, progress.markFinished("project/dashboard/overview", "outdated-hash", api.DynatraceEntity{Id: "dashboard-id", Name: "Overview"}))
+
+	result := execute(context.Background(), stateTestEnvironment, projects, dependencyGraphTestPath, Options{DryRun: true}, nil, progress, nil, nil)
+	assert.Equal(t, len(result.errors), 0)
+
+	deployed := make(map[string]bool)
+	for _, config := range result.configs {
+		deployed[config.Config] = true
+	}
+	assert.Assert(t, !deployed["project/management-zone/zone"])
+	assert.Assert(t, deployed["project/alerting-profile/profile-a"])
+	// the overview has been changed since it has been deployed
+	assert.Assert(t, deployed["project/dashboard/overview"])
+}
+
+func TestDeployWithoutResumeReplacesPreviousCheckpoint(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	path := util.ReplacePathSeparators("./test-resources/duplicate-name-test")
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+	file := checkpointFile(path, "test1")
+
+	previous := newCheckpoint(fs, file, "https://test1.com")
+	assert.NilError(t, previous.markFinished("project2/dashboard/dashboard", "dashboard-hash", api.DynatraceEntity{Id: "dashboard-id", Name: "Dashboard"}))
+
+	// the deployment fails before any config has been deployed, as the token is missing
+	err := Deploy(context.Background(), path, fs, environmentsFile, "test1", "project2", Options{})
+	assert.ErrorContains(t, err, "Errors during deployment")
+
+	resumed, err := loadCheckpoint(fs, file, "https://test1.com")
+	assert.NilError(t, err)
+	assert.Equal(t, len(resumed.entities()), 0)
+}
@@ -15,28 +15,132 @@
 package deploy
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/config"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/delete"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/hook"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/lock"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/report"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/state"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
 	"github.com/spf13/afero"
 )
 
-func Deploy(workingDir string, fs afero.Fs, environmentsFile string,
-	specificEnvironment string, proj string, dryRun bool, continueOnError bool) error {
+// Options contains the optional settings of a deployment
+type Options struct {
+
+	// DryRun switches to just validation instead of actual deployment
+	DryRun bool
+	// DryRunOnline validates the configs against the environments during a dry run, using read requests only
+	DryRunOnline bool
+	// ContinueOnError proceeds the deployment of an environment even if a config upload fails
+	ContinueOnError bool
+	// Parallel is the maximum number of environments which are deployed at the same time
+	Parallel int
+	// ParallelConfigs is the maximum number of configs of an environment which are deployed at the same time.
+	// Only configs whose dependencies are already deployed are deployed in parallel.
+	ParallelConfigs int
+	// UseState reads and updates the state file of each environment (see package state). Configs contained in the
+	// state are updated using their stored id, which allows to rename them.
+	UseState bool
+	// Prune deletes the objects of configs which have been removed from the deployed projects. It implies UseState.
+	Prune bool
+	// AssumeYes skips the confirmation before pruning
+	AssumeYes bool
+	// RollbackOnFailure reverts all changes made to an environment, if its deployment stops because of an error.
+	// It has no effect if ContinueOnError is set. Canceled deployments are not rolled back, but can be resumed.
+	RollbackOnFailure bool
+	// ReportFile is the file to write a machine-readable report of the deployment to. No report is written if empty.
+	ReportFile string
+	// ReportFormat is the format of the report, see package report
+	ReportFormat string
+	// Resume continues a failed deployment from the checkpoint of each environment. Configs which have been deployed
+	// successfully before are not deployed again. Checkpoints are written during every deployment.
+	Resume bool
+	// Configs restricts the deployment to the configs matching one of these patterns and the configs they depend on.
+	// Patterns are matched against project/api/id and may contain wildcards, see project.SelectConfigs.
+	Configs []string
+	// Apis restricts the deployment to configs of these APIs. All APIs are deployed if empty.
+	Apis []string
+	// ExcludedApis skips all configs of these APIs. Deploying a config which depends on a skipped one fails.
+	ExcludedApis []string
+	// ResolveFromEnvironment looks up configs which are referenced, but not deployed, in the environment by their name.
+	// Projects which the projects given by -p depend on are not deployed in this case.
+	ResolveFromEnvironment bool
+	// Locker grants exclusive access to each environment during its deployment, including the processing of
+	// delete.yaml and pruning. Dry runs don't lock environments.
+	// If nil, lock files are written to LockDir.
+	Locker lock.Locker
+	// LockDir is the folder of the lock files, which should be on a file system shared by all deploying machines.
+	// Defaults to lock.DefaultDir of the working directory.
+	LockDir string
+	// LockTimeout is how long to wait for the lock of an environment held by another deployment
+	LockTimeout time.Duration
+	// ForceUnlock removes the locks of the environments instead of deploying them
+	ForceUnlock bool
+	// Waves are environment groups which are deployed one after another. The next wave is only deployed if all
+	// environments of the previous one have been deployed without errors and the gate passed. All environments
+	// are deployed at once if empty. During a dry run, all waves are validated and gates are skipped.
+	Waves []string
+	// WaveGate is a command run before each wave but the first. The wave is only deployed if it succeeds.
+	WaveGate string
+	// WaveWait is how long to wait before each wave but the first, e.g. to let monitoring pick up problems
+	WaveWait time.Duration
+	// RateLimitStrategy selects how the rate limit of the environments is handled, see rest.WithRateLimitStrategy.
+	// Defaults to the strategy of the environment variable MONACO_RATE_LIMIT_STRATEGY.
+	RateLimitStrategy string
+	// RateLimit is the maximum number of requests per minute sent to an environment with the token-bucket strategy,
+	// see rest.WithRateLimit
+	RateLimit int
+}
+
+// Deploy deploys the projects to the environments. Once the context is done (e.g. because the user interrupted
+// monaco), no further configs are scheduled, running requests are canceled and the summary of the configs deployed
+// so far is printed.
+func Deploy(ctx context.Context, workingDir string, fs afero.Fs, environmentsFile string,
+	specificEnvironment string, proj string, options Options) error {
+	dryRun := options.DryRun
+	continueOnError := options.ContinueOnError
+
+	if options.ReportFile != "" && !report.IsSupportedFormat(options.ReportFormat) {
+		return fmt.Errorf("unknown report format %s, supported formats are %s and %s", options.ReportFormat, report.FormatJson, report.FormatJunit)
+	}
+
 	environments, errors := environment.LoadEnvironmentList(specificEnvironment, environmentsFile, fs)
 
 	workingDir = filepath.Clean(workingDir)
 
+	locker := options.Locker
+	if locker == nil {
+		lockDir := options.LockDir
+		if lockDir == "" {
+			lockDir = lock.DefaultDir(workingDir)
+		}
+		locker = lock.NewFileLocker(fs, lockDir)
+	}
+
+	if options.ForceUnlock {
+		if len(errors) > 0 {
+			util.PrintErrors(errors)
+			return fmt.Errorf("There were some errors while getting environment files")
+		}
+		return forceUnlock(locker, environments)
+	}
+
 	var deploymentErrors = make(map[string][]error)
 
 	for i, err := range errors {
@@ -44,6 +148,11 @@ func Deploy(workingDir string, fs afero.Fs, environmentsFile string,
 		deploymentErrors[configIssue] = append(deploymentErrors[configIssue], err)
 	}
 
+	waves, err := planWaves(environments, options.Waves)
+	if err != nil {
+		return err
+	}
+
 	apis := api.NewApis()
 
 	projects, err := project.LoadProjectsToDeploy(fs, proj, apis, workingDir)
@@ -51,20 +160,301 @@ func Deploy(workingDir string, fs afero.Fs, environmentsFile string,
 		util.FailOnError(err, "Loading of projects failed")
 	}
 
+	// removed configs are detected on all loaded projects, as configs not selected for deployment still exist
+	loadedProjects := projects
+
+	var resolver *referenceResolver
+	if options.ResolveFromEnvironment {
+		resolver = newReferenceResolver(loadedProjects, workingDir)
+
+		if proj != "" {
+			projects = project.WithoutDependencyProjects(projects, proj, workingDir)
+		}
+	}
+
+	if len(options.Configs) > 0 {
+		selected, err := project.SelectConfigs(projects, options.Configs, workingDir)
+		if err != nil {
+			return err
+		}
+		projects = project.FilterConfigs(projects, func(config config.Config) bool {
+			return selected[config.GetFullQualifiedId()]
+		})
+	}
+
+	if len(options.Apis) > 0 || len(options.ExcludedApis) > 0 {
+		selected, err := project.SelectApis(projects, options.Apis, options.ExcludedApis)
+		if err != nil {
+			return err
+		}
+		if resolver == nil {
+			err = project.CheckExcludedDependencies(projects, selected)
+			if err != nil {
+				return err
+			}
+		}
+		projects = project.FilterConfigs(projects, func(config config.Config) bool {
+			return selected[config.GetFullQualifiedId()]
+		})
+	}
+
 	util.Log.Info("Executing projects in this order: ")
 
 	for i, project := range projects {
 		util.Log.Info("\t%d: %s (%d configs)", i+1, project.GetId(), len(project.GetConfigs()))
 	}
 
-	for _, environment := range environments {
-		errors := execute(environment, projects, dryRun, workingDir, continueOnError)
-		if errors != nil && len(errors) > 0 {
-			deploymentErrors[environment.GetId()] = errors
+	var unchangedConfigs = make(map[string][]string)
+	var removedConfigs = make(map[string][]string)
+	var states = make(map[string]*state.State)
+	var rollbacks = make(map[string]rollbackResult)
+	var canceled = make(map[string]int)
+	var hookReports = make(map[string][]report.HookReport)
+	var retries = make(map[string]int)
+	var deploymentReport = report.Report{}
+
+	useState := options.UseState || options.Prune
+
+	// references to skipped configs can only be resolved from the environment. Nothing is deployed if they can't,
+	// a dry run validates the environments nevertheless.
+	var skippedConfigErrors map[string][]error
+	if resolver == nil {
+		skippedConfigErrors = checkSkippedConfigs(environments, projects)
+	}
+	for environmentId, errors := range skippedConfigErrors {
+		deploymentErrors[environmentId] = errors
+	}
+
+	// the locks are held until delete.yaml has been processed and the removed configs have been pruned
+	var locks = make(map[string]lock.Lock)
+	defer releaseLocks(locks)
+
+	var mutex sync.Mutex
+	var waitGroup sync.WaitGroup
+	slots := make(chan struct{}, maxParallelEnvironments(options.Parallel))
+
+	var stopped error
+	for i, w := range waves {
+		if len(skippedConfigErrors) > 0 && !dryRun {
+			break
+		}
+
+		// during a dry run, all waves are validated
+		if i > 0 && !dryRun {
+			w.notStarted = checkWave(ctx, options, waves[i-1], w)
+			if w.notStarted != nil {
+				if stopped == nil {
+					stopped = fmt.Errorf("Deployment stopped before wave %s: %w", w.group, w.notStarted)
+				}
+				continue
+			}
+		}
+		if w.group != "" {
+			util.Log.Info("Deploying wave %d (%s): %s", i+1, w.group, strings.Join(w.environmentIds(), ", "))
+		}
+		for _, env := range w.environments {
+			slots <- struct{}{}
+			waitGroup.Add(1)
+
+			go func(environment environment.Environment) {
+				defer waitGroup.Done()
+				defer func() { <-slots }()
+
+				// failEnvironment records an error which prevents the deployment of the whole environment
+				failEnvironment := func(err error) {
+					mutex.Lock()
+					defer mutex.Unlock()
+					deploymentErrors[environment.GetId()] = []error{err}
+					deploymentReport.Environments = append(deploymentReport.Environments, report.EnvironmentReport{
+						Environment: environment.GetId(),
+						Errors:      []report.ErrorReport{report.NewErrorReport(err)},
+					})
+				}
+
+				if !dryRun {
+					environmentLock, err := locker.Acquire(ctx, environment.GetId(), options.LockTimeout)
+					if err != nil {
+						failEnvironment(err)
+						return
+					}
+					mutex.Lock()
+					locks[environment.GetId()] = environmentLock
+					mutex.Unlock()
+				}
+
+				var progress *checkpoint
+				var err error
+				if !dryRun {
+					progress, err = prepareCheckpoint(fs, workingDir, environment, options.Resume)
+					if err != nil {
+						failEnvironment(err)
+						return
+					}
+				}
+
+				var deploymentState *state.State
+				stateFile := state.FilePath(workingDir, environment.GetId())
+
+				if useState && !dryRun {
+					deploymentState, err = state.Load(fs, stateFile)
+					if err != nil {
+						failEnvironment(err)
+						return
+					}
+				}
+
+				var hooks *hookRunner
+				if !dryRun {
+					hooks = newHookRunner(fs, workingDir, environment)
+				}
+
+				result := execute(ctx, environment, projects, workingDir, options, deploymentState, progress, resolver, hooks)
+
+				if deploymentState != nil && result.completed {
+					result.removedConfigs = findRemovedConfigs(deploymentState, loadedProjects, workingDir, proj == "")
+				}
+
+				if progress != nil && len(result.errors) == 0 {
+					err := progress.remove()
+					if err != nil {
+						util.Log.Warn("Failed to remove checkpoint of %s: %s", environment.GetId(), err)
+					}
+				}
+
+				if deploymentState != nil {
+					err := deploymentState.Save(fs, stateFile)
+					if err != nil {
+						result = result.withEnvironmentError(err)
+					}
+				}
+
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				if result.errors != nil && len(result.errors) > 0 {
+					deploymentErrors[environment.GetId()] = append(deploymentErrors[environment.GetId()], result.errors...)
+				}
+				if len(result.unchangedConfigs) > 0 {
+					unchangedConfigs[environment.GetId()] = result.unchangedConfigs
+				}
+				if len(result.removedConfigs) > 0 {
+					removedConfigs[environment.GetId()] = result.removedConfigs
+				}
+				if deploymentState != nil {
+					states[environment.GetId()] = deploymentState
+				}
+				if result.rollback != nil {
+					rollbacks[environment.GetId()] = *result.rollback
+				}
+				if result.canceled {
+					canceled[environment.GetId()] = len(result.configs)
+				}
+				if len(result.hooks) > 0 {
+					hookReports[environment.GetId()] = result.hooks
+				}
+				if result.retries > 0 {
+					retries[environment.GetId()] = result.retries
+				}
+				deploymentReport.Environments = append(deploymentReport.Environments, report.EnvironmentReport{
+					Environment: environment.GetId(),
+					Configs:     result.configs,
+					Hooks:       result.hooks,
+					Retries:     result.retries,
+					Errors:      result.environmentErrors,
+				})
+			}(env)
+		}
+
+		waitGroup.Wait()
+
+		for id := range w.environments {
+			if len(deploymentErrors[id]) > 0 {
+				w.failed = append(w.failed, id)
+			}
+		}
+	}
+
+	for environmentId, errors := range skippedConfigErrors {
+		for _, err := range errors {
+			addEnvironmentError(&deploymentReport, environmentId, err)
+		}
+	}
+
+	// do not execute delete if there are problems with deployment or later waves have not been deployed
+	if len(deploymentErrors) == 0 && stopped == nil {
+		for environmentId, err := range deleteConfigs(ctx, apis, environments, workingDir, options, fs) {
+			deploymentErrors[environmentId] = []error{err}
+			addEnvironmentError(&deploymentReport, environmentId, err)
+		}
+	}
+
+	if options.ReportFile != "" {
+		err := writeReport(fs, deploymentReport, deploymentErrors, options)
+		if err != nil {
+			return err
 		}
 	}
 
 	util.Log.Info("Deployment summary:")
+	if len(options.Waves) > 0 {
+		printWaveSummary(waves)
+	}
+	for environment, configs := range unchangedConfigs {
+		util.Log.Info("%d config(s) of %s were unchanged and have not been updated", len(configs), environment)
+		for _, config := range configs {
+			util.Log.Debug("\t%s", config)
+		}
+	}
+	for environment, configs := range removedConfigs {
+		if options.Prune {
+			util.Log.Info("%d config(s) of %s have been removed and will be pruned", len(configs), environment)
+			continue
+		}
+
+		util.Log.Warn("%d config(s) of %s have been removed, but their objects still exist. "+
+			"Add them to delete.yaml or deploy with --prune to delete them:", len(configs), environment)
+		for _, config := range configs {
+			entry, _ := states[environment].Get(config)
+			util.Log.Warn("\t- \"%s/%s\"", entry.Api, entry.Name)
+		}
+	}
+	for environment, rollback := range rollbacks {
+		util.Log.Info("Rolled back deployment to %s: %d object(s) restored, %d object(s) deleted",
+			environment, len(rollback.restored), len(rollback.deleted))
+		for _, config := range rollback.restored {
+			util.Log.Debug("\trestored %s", config)
+		}
+		for _, config := range rollback.deleted {
+			util.Log.Debug("\tdeleted %s", config)
+		}
+		if len(rollback.errors) > 0 {
+			util.Log.Error("Rollback of %s failed with %d error(s):\n", environment, len(rollback.errors))
+			util.PrintErrors(rollback.errors)
+		}
+	}
+	for environment, hooks := range hookReports {
+		for _, hookReport := range hooks {
+			scope := environment
+			if hookReport.Project != "" {
+				scope = hookReport.Project + " in " + environment
+			}
+			if hookReport.Error != nil {
+				util.Log.Error("%s hook of %s failed after %dms: %s", hookReport.Hook, scope, hookReport.DurationMs, hookReport.Error.Message)
+			} else {
+				util.Log.Info("%s hook of %s succeeded after %dms", hookReport.Hook, scope, hookReport.DurationMs)
+			}
+		}
+	}
+	for environment, count := range retries {
+		util.Log.Info("%d request(s) to %s have been retried because of transient errors", count, environment)
+	}
+	for environment, processed := range canceled {
+		if dryRun {
+			util.Log.Warn("Validation of %s has been canceled after %d processed config(s)", environment, processed)
+		} else {
+			util.Log.Warn("Deployment to %s has been canceled after %d processed config(s), continue with --resume", environment, processed)
+		}
+	}
 	for environment, errors := range deploymentErrors {
 		if dryRun {
 			util.Log.Error("Validation of %s failed. Found %d error(s)\n", environment, len(errors))
@@ -78,7 +468,6 @@ func Deploy(workingDir string, fs afero.Fs, environmentsFile string,
 		}
 	}
 
-	// do not execute delete if there are problems with deployment
 	if len(deploymentErrors) > 0 {
 		if dryRun {
 			return fmt.Errorf("Errors during validation! Check log!")
@@ -87,97 +476,455 @@ func Deploy(workingDir string, fs afero.Fs, environmentsFile string,
 		}
 	}
 
+	// later waves have not been deployed, e.g. because the gate did not pass
+	if stopped != nil {
+		return stopped
+	}
+
 	if dryRun {
 		util.Log.Info("Validation finished without errors")
 	} else {
 		util.Log.Info("Deployment finished without errors")
 	}
 
-	deleteConfigs(apis, environments, workingDir, dryRun, fs)
+	if options.Prune && !dryRun && len(removedConfigs) > 0 {
+		return pruneEnvironments(ctx, fs, workingDir, environments, apis, states, removedConfigs, options, os.Stdin)
+	}
 
 	return nil
 }
 
-func execute(environment environment.Environment, projects []project.Project, dryRun bool, path string, continueOnError bool) (errors []error) {
-	util.Log.Info("Processing environment " + environment.GetId() + "...")
+// forceUnlock removes the locks of the given environments, e.g. after a deployment has been killed
+func forceUnlock(locker lock.Locker, environments map[string]environment.Environment) error {
 
-	var client rest.DynatraceClient
-	if !dryRun {
-		apiToken, err := environment.GetToken()
+	var unlockErrors []error
+	for _, environment := range environments {
+		err := locker.ForceUnlock(environment.GetId())
 		if err != nil {
-			return append(errors, err)
+			unlockErrors = append(unlockErrors, fmt.Errorf("failed to unlock %s: %w", environment.GetId(), err))
+		}
+	}
+
+	if len(unlockErrors) > 0 {
+		util.PrintErrors(unlockErrors)
+		return fmt.Errorf("Errors during unlocking! Check log!")
+	}
+	return nil
+}
+
+// releaseLocks releases the locks of the environments, failing to release a lock is only logged
+func releaseLocks(locks map[string]lock.Lock) {
+	for environmentId, environmentLock := range locks {
+		if err := environmentLock.Release(); err != nil {
+			util.Log.Warn("Failed to release lock of %s: %s", environmentId, err)
+		}
+	}
+}
+
+// newClient creates the client of the environment, which uses the rate limit strategy of the options
+func newClient(environment environment.Environment, options Options) (rest.DynatraceClient, error) {
+	apiToken, err := environment.GetToken()
+	if err != nil {
+		return nil, err
+	}
+	return rest.NewDynatraceClient(environment.GetEnvironmentUrl(), apiToken,
+		rest.WithRateLimitStrategy(options.RateLimitStrategy), rest.WithRateLimit(options.RateLimit),
+		rest.WithLogger(util.NewPrefixedLogger(environment.GetId())))
+}
+
+// maxParallelEnvironments returns how many environments may be deployed at the same time.
+// Values smaller than one result in a sequential deployment.
+func maxParallelEnvironments(parallel int) int {
+	if parallel < 1 {
+		return 1
+	}
+	return parallel
+}
+
+// deploymentResult collects the outcome of the deployment to a single environment
+type deploymentResult struct {
+	unchangedConfigs []string
+	// removedConfigs are the ids of the configs in the state, which don't exist anymore
+	removedConfigs []string
+	// rollback is the result of reverting the deployment, nil if it has not been rolled back
+	rollback *rollbackResult
+	// configs are the reports of all configs processed, including the failed ones
+	configs []report.ConfigReport
+	// environmentErrors are the reports of all errors not related to a single config
+	environmentErrors []report.ErrorReport
+	// completed is true if all configs have been processed, i.e. the deployment has not been aborted
+	completed bool
+	// canceled is true if the deployment has been stopped because the context is done
+	canceled bool
+	// hooks are the reports of all hooks run for the environment and its projects
+	hooks []report.HookReport
+	// retries is the number of requests retried because of transient errors
+	retries int
+	errors  []error
+}
+
+func (r deploymentResult) withError(err error) deploymentResult {
+	r.errors = append(r.errors, err)
+	return r
+}
+
+func (r deploymentResult) withEnvironmentError(err error) deploymentResult {
+	r.environmentErrors = append(r.environmentErrors, report.NewErrorReport(err))
+	return r.withError(err)
+}
+
+func (r deploymentResult) withCancellation(ctx context.Context, environment environment.Environment) deploymentResult {
+	r.canceled = true
+	return r.withEnvironmentError(fmt.Errorf("deployment to %s has been canceled: %w", environment.GetId(), ctx.Err()))
+}
+
+func (r deploymentResult) withConfigError(referenceId string, config config.Config, err error, start time.Time) deploymentResult {
+	r.configs = append(r.configs, newConfigReport(referenceId, config, api.DynatraceEntity{}, err, start, false))
+	return r.withError(err)
+}
+
+// newConfigReport creates the report of a processed config. The operation is derived from the entity and error.
+func newConfigReport(referenceId string, config config.Config, entity api.DynatraceEntity, err error, start time.Time, dryRun bool) report.ConfigReport {
+
+	configReport := report.ConfigReport{
+		Config:     referenceId,
+		Api:        config.GetApi().GetId(),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	if err != nil {
+		errorReport := report.NewErrorReport(err)
+		configReport.Operation = report.OperationFailed
+		configReport.Error = &errorReport
+		configReport.HttpStatus = report.HttpStatusOf(err)
+		return configReport
+	}
+
+	if dryRun {
+		configReport.Operation = report.OperationValidated
+		return configReport
+	}
+
+	switch {
+	case entity.Unchanged:
+		configReport.Operation = report.OperationUnchanged
+	case entity.Created:
+		configReport.Operation = report.OperationCreated
+	default:
+		configReport.Operation = report.OperationUpdated
+	}
+	configReport.Id = entity.Id
+	configReport.HttpStatus = entity.StatusCode
+	return configReport
+}
+
+// writeReport writes the report of the deployment. Errors which happened before the deployment of an
+// environment started (e.g. invalid environment files) are reported as separate environments.
+func writeReport(fs afero.Fs, deploymentReport report.Report, deploymentErrors map[string][]error, options Options) error {
+
+	for issue, errors := range deploymentErrors {
+		if !strings.HasPrefix(issue, "environmentfile-issue-") {
+			continue
 		}
 
-		client, err = rest.NewDynatraceClient(environment.GetEnvironmentUrl(), apiToken)
+		environmentReport := report.EnvironmentReport{Environment: issue}
+		for _, err := range errors {
+			environmentReport.Errors = append(environmentReport.Errors, report.NewErrorReport(err))
+		}
+		deploymentReport.Environments = append(deploymentReport.Environments, environmentReport)
+	}
+
+	err := report.Write(fs, deploymentReport, options.ReportFormat, options.ReportFile)
+	if err != nil {
+		return fmt.Errorf("failed to write report to %s: %s", options.ReportFile, err)
+	}
+
+	util.Log.Info("Deployment report written to %s", options.ReportFile)
+	return nil
+}
+
+// addEnvironmentError adds an error which is not related to a single config to the report of the environment
+func addEnvironmentError(deploymentReport *report.Report, environmentId string, err error) {
+	for i := range deploymentReport.Environments {
+		if deploymentReport.Environments[i].Environment == environmentId {
+			deploymentReport.Environments[i].Errors = append(deploymentReport.Environments[i].Errors, report.NewErrorReport(err))
+			return
+		}
+	}
+	deploymentReport.Environments = append(deploymentReport.Environments, report.EnvironmentReport{
+		Environment: environmentId,
+		Errors:      []report.ErrorReport{report.NewErrorReport(err)},
+	})
+}
+
+// prepareCheckpoint loads the checkpoint of the environment if the deployment is resumed. Otherwise, an empty
+// checkpoint is written, replacing the checkpoint of previous deployments even if this one fails before any config
+// has been deployed.
+func prepareCheckpoint(fs afero.Fs, workingDir string, environment environment.Environment, resume bool) (*checkpoint, error) {
+
+	file := checkpointFile(workingDir, environment.GetId())
+
+	if !resume {
+		progress := newCheckpoint(fs, file, environment.GetEnvironmentUrl())
+		return progress, progress.reset()
+	}
+	return loadCheckpoint(fs, file, environment.GetEnvironmentUrl())
+}
+
+func execute(ctx context.Context, environment environment.Environment, projects []project.Project, path string, options Options,
+	deploymentState *state.State, progress *checkpoint, resolver *referenceResolver, hooks *hookRunner) (result deploymentResult) {
+	dryRun := options.DryRun
+	continueOnError := options.ContinueOnError
+
+	log := util.NewPrefixedLogger(environment.GetId())
+
+	if ctx.Err() != nil {
+		return result.withCancellation(ctx, environment)
+	}
+
+	if hooks != nil {
+		var succeeded bool
+		if result, succeeded = hooks.runEnvironmentHook(ctx, log, hook.PreDeploy, projects, result); !succeeded {
+			return result
+		}
+		defer func() {
+			result, _ = hooks.runEnvironmentHook(ctx, log, hook.PostDeploy, projects, result)
+		}()
+	}
+	log.Info("Processing environment " + environment.GetId() + "...")
+
+	var client rest.DynatraceClient
+	if !dryRun || options.DryRunOnline {
+		var err error
+		client, err = newClient(environment, options)
 		if err != nil {
-			return append(errors, err)
+			return result.withEnvironmentError(err)
+		}
+		defer func() {
+			result.retries = rest.Retries(client)
+		}()
+	}
+
+	if dryRun && client != nil {
+		client = rest.NewReadOnlyDynatraceClient(client)
+
+		for _, err := range checkReadAccess(ctx, client, projects) {
+			log.Error("\t%s", err)
+			result = result.withEnvironmentError(err)
 		}
 	}
 
-	dict := make(map[string]api.DynatraceEntity)
+	var journal *rollbackJournal
+	if options.RollbackOnFailure && !dryRun && !continueOnError {
+		journal = &rollbackJournal{}
+	}
+
+	dict := newEntityDictionary()
 	var nameDict = make(map[string]string)
-	var name, configID string
+
+	if progress != nil {
+		entities := progress.entities()
+		if len(entities) > 0 {
+			log.Info("Resuming deployment, %d config(s) have already been deployed", len(entities))
+		}
+		for referenceId, entity := range entities {
+			if entity.Name != "" {
+				dict.put(referenceId, entity)
+			}
+		}
+	}
+
+	// guards nameDict and result, which are shared by all configs deployed in parallel
+	var mutex sync.Mutex
+
+	configs := indexConfigs(projects)
 
 	for _, project := range projects {
 
-		util.Log.Info("\tProcessing project " + project.GetId() + "...")
-		util.Log.Debug("\t\tDeploying configs in this order: ")
+		log.Info("\tProcessing project " + project.GetId() + "...")
+		log.Debug("\t\tDeploying configs in this order: ")
 		for i, config := range project.GetConfigs() {
-			util.Log.Debug("\t\t\t%d: %s", i+1, config.GetFilePath())
+			log.Debug("\t\t\t%d: %s", i+1, config.GetFilePath())
 		}
 
-		for _, config := range project.GetConfigs() {
+		// a failing pre-deploy hook aborts the deployment like a failing config, i.e. it is rolled back if enabled
+		firstResult := len(result.configs)
+		preDeploySucceeded := true
+		if hooks != nil {
+			result, preDeploySucceeded = hooks.runProjectHook(ctx, log, hook.PreDeploy, project, result, firstResult, false)
+		}
+
+		aborted := !preDeploySucceeded || scheduleConfigs(project, options.ParallelConfigs, func(config config.Config) bool {
 
 			var entity api.DynatraceEntity
 			var err error
 
+			start := time.Now()
+			configID := config.GetFullQualifiedId()
+			referenceId := strings.TrimPrefix(configID, path+"/")
+
+			if ctx.Err() != nil {
+				return false
+			}
+
 			if config.IsSkipDeployment(environment) {
-				util.Log.Info("\t\t\tskipping deployment of %s: %s", config.GetId(), config.GetFilePath())
-				continue
+				log.Info("\t\t\tskipping deployment of %s: %s", config.GetId(), config.GetFilePath())
+
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				result.configs = append(result.configs, report.ConfigReport{
+					Config:    referenceId,
+					Api:       config.GetApi().GetId(),
+					Operation: report.OperationSkipped,
+				})
+				return true
+			}
+
+			// all dependencies of the config are deployed at this point, so the snapshot contains their entities
+			configDict := dict.snapshot()
+
+			if resolver != nil {
+				err = resolver.resolve(ctx, client, environment, config, configDict)
+				if err != nil {
+					mutex.Lock()
+					defer mutex.Unlock()
+					result = result.withConfigError(referenceId, config, err, start)
+					if continueOnError || dryRun {
+						log.Error("\t\t\tFailed %s", err)
+						return true
+					}
+					return false
+				}
+			}
+
+			// a config is only skipped on resume, if it is still rendered to the deployed payload
+			var payloadHash string
+			if progress != nil {
+				payload, err := config.GetConfigForEnvironment(environment, configDict)
+				if err != nil {
+					mutex.Lock()
+					defer mutex.Unlock()
+					result = result.withConfigError(referenceId, config, err, start)
+					return false
+				}
+				payloadHash = state.HashPayload(payload)
+
+				if progress.isFinished(referenceId, payloadHash) {
+					log.Debug("\t\t\t%s has already been deployed", configID)
+					return true
+				}
 			}
 
-			name, err = config.GetObjectNameForEnvironment(environment, dict)
+			name, err := config.GetObjectNameForEnvironment(environment, configDict)
 			if err != nil {
-				return append(errors, err)
+				mutex.Lock()
+				defer mutex.Unlock()
+				result = result.withConfigError(referenceId, config, err, start)
+				return false
 			}
-			name = config.GetApi().GetId() + "/" + name
-			configID = config.GetFullQualifiedId()
-			if nameDict[name] != "" {
-				return append(errors, fmt.Errorf("duplicate UID '%s' found in %s and %s", name, configID, nameDict[name]))
+			uid := config.GetApi().GetId() + "/" + name
+
+			mutex.Lock()
+			if nameDict[uid] != "" {
+				result = result.withConfigError(referenceId, config, fmt.Errorf("duplicate UID '%s' found in %s and %s", uid, configID, nameDict[uid]), start)
+				mutex.Unlock()
+				return false
+			}
+			nameDict[uid] = configID
+			mutex.Unlock()
+
+			var snapshot objectSnapshot
+			if journal != nil {
+				snapshot, err = takeSnapshot(ctx, client, deploymentState, referenceId, config.GetApi(), name)
+				if err != nil {
+					mutex.Lock()
+					defer mutex.Unlock()
+					result = result.withConfigError(referenceId, config, fmt.Errorf("failed to read %s before deployment: %w", configID, err), start)
+					return false
+				}
 			}
-			nameDict[name] = configID
 
 			if dryRun {
-				entity, err = validateConfig(project, config, dict, environment)
+				entity, err = validateConfig(log, config, configDict, environment)
+				if err == nil && client != nil {
+					entity, err = validateAgainstEnvironment(ctx, log, client, config, name, entity)
+				}
 			} else {
-				entity, err = uploadConfig(client, config, dict, environment)
+				entity, err = uploadConfig(ctx, log, client, deploymentState, path, configs, config, configDict, environment)
+			}
+
+			if journal != nil && err == nil && !entity.Unchanged && entity.Id != "" {
+				journal.record(referenceId, config.GetApi(), entity, snapshot)
 			}
 
+			if progress != nil && err == nil {
+				if checkpointErr := progress.markFinished(referenceId, payloadHash, entity); checkpointErr != nil {
+					log.Warn("\t\t\tFailed to write checkpoint: %s", checkpointErr)
+				}
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			result.configs = append(result.configs, newConfigReport(referenceId, config, entity, err, start, dryRun))
+
 			if err != nil {
 				// by default stop deployment on error
 				if continueOnError || dryRun {
-					errors = append(errors, err)
+					result.errors = append(result.errors, err)
 					// Log error here in addition to deployment summary
 					// Useful to debug using verbose
-					util.Log.Error("\t\t\tFailed %s", err)
+					log.Error("\t\t\tFailed %s", err)
 				} else {
-					return append(errors, err)
+					result = result.withError(err)
+					return false
 				}
 			}
 
-			referenceId := strings.TrimPrefix(config.GetFullQualifiedId(), path+"/")
+			if entity.Unchanged {
+				log.Info("\t\t\t%s is unchanged, skipped update", configID)
+				result.unchangedConfigs = append(result.unchangedConfigs, configID)
+			}
 
 			if entity.Name != "" {
-				dict[referenceId] = entity
+				dict.put(referenceId, entity)
+			}
+			return true
+		})
+
+		if hooks != nil && preDeploySucceeded {
+			result, _ = hooks.runProjectHook(ctx, log, hook.PostDeploy, project, result, firstResult, aborted)
+		}
+
+		if aborted && ctx.Err() != nil {
+			// the checkpoint is kept, so that the deployment can be resumed instead of being rolled back
+			log.Warn("Deployment has been canceled, no further configs are deployed")
+			return result.withCancellation(ctx, environment)
+		}
+
+		if aborted {
+			if journal != nil {
+				rollback := journal.rollback(ctx, log, client, deploymentState)
+				result.rollback = &rollback
+
+				if progress != nil {
+					err := progress.forget(append(rollback.restored, rollback.deleted...)...)
+					if err != nil {
+						log.Warn("Failed to write checkpoint: %s", err)
+					}
+				}
 			}
+			return result
 		}
 	}
 
-	return errors
+	result.completed = true
+	return result
 }
 
-func validateConfig(project project.Project, config config.Config, dict map[string]api.DynatraceEntity, environment environment.Environment) (entity api.DynatraceEntity, err error) {
-	util.Log.Debug("\t\tValidating config " + config.GetFilePath())
+func validateConfig(log util.PrefixedLogger, config config.Config, dict map[string]api.DynatraceEntity, environment environment.Environment) (entity api.DynatraceEntity, err error) {
+	log.Debug("\t\tValidating config " + config.GetFilePath())
 
 	_, err = config.GetConfigForEnvironment(environment, dict)
 
@@ -187,100 +934,196 @@ func validateConfig(project project.Project, config config.Config, dict map[stri
 
 	randomId := "random-" + strconv.Itoa(rand.Int())
 
-	// If configuration deployment skipped but has dependency, throw an error
-	if config.IsSkipDeployment(environment) {
-		util.Log.Info("\t\t\tskipping deployment of %s: %s", config.GetId(), config.GetFilePath())
-		erronousDependencies := make([]string, 0)
+	return api.DynatraceEntity{
+		Id:          randomId,
+		Name:        randomId,
+		Description: randomId,
+	}, err
+}
 
-		for _, requiredId := range config.GetRequiredByConfigIdList() {
-			//TODO this won't work for inter project dependencies
-			requiredConfig, err := project.GetConfig(requiredId)
+// indexConfigs returns the configs of all given projects by their full qualified id
+func indexConfigs(projects []project.Project) map[string]config.Config {
+	configs := make(map[string]config.Config)
+	for _, project := range projects {
+		for _, config := range project.GetConfigs() {
+			configs[config.GetFullQualifiedId()] = config
+		}
+	}
+	return configs
+}
 
-			if err != nil {
-				util.Log.Warn("Encountered known bug (cross project skipDeployment check is not working at the moment): %s", err)
-				// return api.DynatraceEntity{
-				// 	Id:          randomId,
-				// 	Name:        randomId,
-				// 	Description: randomId,
-				// }, fmt.Errorf("config with id %s hasn't been found in project %s", requiredId, project.GetId())
-				continue
-			}
+// checkSkippedConfigs checks for all environments, that the configs skipped for them are not required by any config
+// deployed to them. The errors are returned by environment.
+func checkSkippedConfigs(environments map[string]environment.Environment, projects []project.Project) map[string][]error {
 
-			requiredIsSkipped := requiredConfig.IsSkipDeployment(environment)
+	configs := indexConfigs(projects)
+	errors := make(map[string][]error)
 
-			if !requiredIsSkipped {
-				erronousDependencies = append(erronousDependencies, requiredId)
+	for _, environment := range environments {
+		for _, project := range projects {
+			for _, config := range project.GetConfigs() {
+				if !config.IsSkipDeployment(environment) {
+					continue
+				}
+				if err := checkSkippedConfig(config, environment, configs); err != nil {
+					errors[environment.GetId()] = append(errors[environment.GetId()], err)
+				}
 			}
 		}
+	}
+	return errors
+}
 
-		if len(erronousDependencies) > 0 {
-			return api.DynatraceEntity{
-				Id:          randomId,
-				Name:        randomId,
-				Description: randomId,
-			}, fmt.Errorf("this config is required by %s and can't be skipped for deployment", erronousDependencies)
-		}
+// checkSkippedConfig fails if the config is skipped for the environment, but required by a config of any project
+// which is deployed to it
+func checkSkippedConfig(config config.Config, environment environment.Environment, configs map[string]config.Config) error {
 
+	erroneousDependencies := make([]string, 0)
+
+	for id, other := range configs {
+		if !other.IsSkipDeployment(environment) && other.HasDependencyOn(config) {
+			erroneousDependencies = append(erroneousDependencies, id)
+		}
 	}
 
-	return api.DynatraceEntity{
-		Id:          randomId,
-		Name:        randomId,
-		Description: randomId,
-	}, err
+	if len(erroneousDependencies) > 0 {
+		sort.Strings(erroneousDependencies)
+		return fmt.Errorf("config %s is required by %s and can't be skipped for deployment", config.GetFullQualifiedId(), erroneousDependencies)
+	}
+	return nil
 }
 
-func uploadConfig(client rest.DynatraceClient, config config.Config, dict map[string]api.DynatraceEntity, environment environment.Environment) (entity api.DynatraceEntity, err error) {
+// uploadConfig deploys the config and records the deployed object in the state. The configs of all deployed projects
+// are needed to record its dependencies, as it can reference configs of other projects.
+func uploadConfig(ctx context.Context, log util.PrefixedLogger, client rest.DynatraceClient, deploymentState *state.State, path string, configs map[string]config.Config, config config.Config, dict map[string]api.DynatraceEntity, environment environment.Environment) (entity api.DynatraceEntity, err error) {
 	name, err := config.GetObjectNameForEnvironment(environment, dict)
 	if err != nil {
 		return entity, err
 	}
 
-	util.Log.Debug("\t\tApplying config `%s` using %s", name, config.GetFilePath())
+	log.Debug("\t\tApplying config `%s` using %s", name, config.GetFilePath())
 
 	uploadMap, err := config.GetConfigForEnvironment(environment, dict)
 	if err != nil {
 		return entity, err
 	}
 
-	entity, err = client.UpsertByName(config.GetApi(), name, uploadMap)
+	if deploymentState == nil {
+		entity, err = client.UpsertByName(ctx, config.GetApi(), name, uploadMap)
+		if err != nil {
+			err = fmt.Errorf("%w, responsible config: %s", err, config.GetFilePath())
+		}
+		return entity, err
+	}
+
+	referenceId := strings.TrimPrefix(config.GetFullQualifiedId(), path+"/")
+	stateEntry, found := deploymentState.Get(referenceId)
+
+	if found && stateEntry.Api == config.GetApi().GetId() {
+		if stateEntry.Name != name {
+			log.Info("\t\t\tRenaming %s from '%s' to '%s'", referenceId, stateEntry.Name, name)
+		}
+		entity, err = client.UpsertById(ctx, config.GetApi(), stateEntry.Id, name, uploadMap)
+	} else {
+		entity, err = client.UpsertByName(ctx, config.GetApi(), name, uploadMap)
+	}
 
 	if err != nil {
-		err = fmt.Errorf("%s, responsible config: %s", err.Error(), config.GetFilePath())
+		return entity, fmt.Errorf("%w, responsible config: %s", err, config.GetFilePath())
+	}
+
+	// some APIs (e.g. extensions) don't return an id, so there is nothing to remember
+	if entity.Id != "" {
+		deploymentState.Put(referenceId, state.Entry{
+			Project:      strings.TrimPrefix(config.GetProject(), path+"/"),
+			Api:          config.GetApi().GetId(),
+			Id:           entity.Id,
+			Name:         name,
+			PayloadHash:  state.HashPayload(uploadMap),
+			Dependencies: stateDependencies(config, configs, path),
+		})
+	}
+	return entity, nil
+}
+
+// stateDependencies returns the ids of the configs the given config depends on, relative to the projects root folder.
+// These include configs of other projects.
+func stateDependencies(config config.Config, configs map[string]config.Config, path string) []string {
+
+	var dependencies []string
+	for id, other := range configs {
+		if id != config.GetFullQualifiedId() && config.HasDependencyOn(other) {
+			dependencies = append(dependencies, strings.TrimPrefix(id, path+"/"))
+		}
 	}
-	return entity, err
+	sort.Strings(dependencies)
+	return dependencies
 }
 
-// deleteConfigs deletes specified configs, if a delete.yaml file was found
-func deleteConfigs(apis map[string]api.Api, environments map[string]environment.Environment, path string, dryRun bool, fs afero.Fs) error {
+// findRemovedConfigs returns the ids of the configs in the state, which have been removed from the deployed projects.
+// If allProjects is set, the given projects are all projects of the working directory, so configs of projects which
+// don't exist anymore have been removed as well. Otherwise, configs of projects which are not deployed are not
+// considered to be removed.
+func findRemovedConfigs(deploymentState *state.State, projects []project.Project, path string, allProjects bool) []string {
+
+	deployedProjects := make(map[string]bool)
+	existingConfigs := make(map[string]bool)
+
+	for _, project := range projects {
+		deployedProjects[strings.TrimPrefix(project.GetId(), path+"/")] = true
+
+		for _, config := range project.GetConfigs() {
+			existingConfigs[strings.TrimPrefix(config.GetFullQualifiedId(), path+"/")] = true
+		}
+	}
+
+	removed := make([]string, 0)
+	for _, configId := range deploymentState.ConfigIds() {
+		entry, _ := deploymentState.Get(configId)
+
+		if (allProjects || deployedProjects[entry.Project]) && !existingConfigs[configId] {
+			removed = append(removed, configId)
+		}
+	}
+	return removed
+}
+
+// deleteConfigs deletes specified configs, if a delete.yaml file was found. The deletion in an environment stops at
+// the first error, which is returned by environment id.
+func deleteConfigs(ctx context.Context, apis map[string]api.Api, environments map[string]environment.Environment, path string, options Options, fs afero.Fs) map[string]error {
 	configs, err := delete.LoadConfigsToDelete(fs, apis, path)
 	util.FailOnError(err, "deletion failed")
 
-	if len(configs) > 0 && !dryRun {
+	deletionErrors := make(map[string]error)
+
+	if len(configs) > 0 && !options.DryRun {
 
 		for name, environment := range environments {
 			util.Log.Info("Deleting %d configs for environment %s...", len(configs), name)
 
-			apiToken, err := environment.GetToken()
+			err := deleteConfigsOfEnvironment(ctx, environment, options, configs)
 			if err != nil {
-				return err
+				deletionErrors[name] = err
 			}
+		}
+	}
 
-			client, err := rest.NewDynatraceClient(environment.GetEnvironmentUrl(), apiToken)
-			if err != nil {
-				return err
-			}
+	return deletionErrors
+}
 
-			for _, config := range configs {
-				util.Log.Debug("\tDeleting config " + config.GetId() + " (" + config.GetApi().GetId() + ")")
+func deleteConfigsOfEnvironment(ctx context.Context, environment environment.Environment, options Options, configs []config.Config) error {
+	client, err := newClient(environment, options)
+	if err != nil {
+		return err
+	}
 
-				err = client.DeleteByName(config.GetApi(), config.GetId())
-				if err != nil {
-					return err
-				}
-			}
+	for _, config := range configs {
+		util.Log.Debug("\tDeleting config " + config.GetId() + " (" + config.GetApi().GetId() + ")")
+
+		err = client.DeleteByName(ctx, config.GetApi(), config.GetId())
+		if err != nil {
+			return fmt.Errorf("deletion of %s (%s) failed: %w", config.GetId(), config.GetApi().GetId(), err)
 		}
 	}
-
 	return nil
 }
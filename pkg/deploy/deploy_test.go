@@ -19,12 +19,19 @@
 package deploy
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/lock"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/report"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/spf13/afero"
 
 	"gotest.tools/assert"
 )
@@ -67,7 +74,7 @@ func TestExecuteFailOnDuplicateNamesWithinSameConfig(t *testing.T) {
 	projects, err := project.LoadProjectsToDeploy(fs, "project1", apis, "./test-resources/duplicate-name-test")
 	assert.NilError(t, err)
 
-	errors := execute(environment, projects, true, "", false)
+	errors := execute(context.Background(), environment, projects, "", Options{DryRun: true}, nil, nil, nil, nil).errors
 	assert.Equal(t, errors != nil, true)
 	assert.ErrorContains(t, errors[0], "duplicate UID 'calculated-metrics-log/metric' found in")
 }
@@ -82,7 +89,7 @@ func TestExecutePassOnDifferentApis(t *testing.T) {
 	projects, err := project.LoadProjectsToDeploy(fs, "project2", apis, path)
 	assert.NilError(t, err)
 
-	errors := execute(environment, projects, true, "", false)
+	errors := execute(context.Background(), environment, projects, "", Options{DryRun: true}, nil, nil, nil, nil).errors
 	for _, err := range errors {
 		assert.NilError(t, err)
 	}
@@ -98,7 +105,7 @@ func TestExecuteFailOnDuplicateNamesInDifferentProjects(t *testing.T) {
 	projects, err := project.LoadProjectsToDeploy(fs, "project1, project2", apis, path)
 	assert.NilError(t, err)
 
-	errors := execute(environment, projects, true, "", false)
+	errors := execute(context.Background(), environment, projects, "", Options{DryRun: true}, nil, nil, nil, nil).errors
 	assert.ErrorContains(t, errors[0], "duplicate UID 'calculated-metrics-log/metric' found in")
 }
 
@@ -113,16 +120,56 @@ func TestExecutePassOnDuplicateNamesInDifferentEnvironments(t *testing.T) {
 	projects, err := project.LoadProjectsToDeploy(fs, "project5", apis, path)
 	assert.NilError(t, err)
 
-	errors := execute(environmentDev, projects, true, "", false)
+	errors := execute(context.Background(), environmentDev, projects, "", Options{DryRun: true}, nil, nil, nil, nil).errors
 	for _, err := range errors {
 		assert.NilError(t, err)
 	}
-	errors = execute(environmentProd, projects, true, "", false)
+	errors = execute(context.Background(), environmentProd, projects, "", Options{DryRun: true}, nil, nil, nil, nil).errors
 	for _, err := range errors {
 		assert.NilError(t, err)
 	}
 }
 
+func TestDeployValidatesEnvironmentsInParallel(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	path := util.ReplacePathSeparators("./test-resources/duplicate-name-test")
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+
+	err := Deploy(context.Background(), path, fs, environmentsFile, "", "project2", Options{DryRun: true, Parallel: 3})
+	assert.NilError(t, err)
+
+	err = Deploy(context.Background(), path, fs, environmentsFile, "", "project1", Options{DryRun: true, Parallel: 3})
+	assert.ErrorContains(t, err, "Errors during validation")
+}
+
+func TestDeployWritesReportOnFailure(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	path := util.ReplacePathSeparators("./test-resources/duplicate-name-test")
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+
+	err := Deploy(context.Background(), path, fs, environmentsFile, "test1", "project1", Options{DryRun: true, ReportFile: "report.json", ReportFormat: report.FormatJson})
+	assert.ErrorContains(t, err, "Errors during validation")
+
+	data, err := afero.ReadFile(fs, "report.json")
+	assert.NilError(t, err)
+
+	var deploymentReport report.Report
+	assert.NilError(t, json.Unmarshal(data, &deploymentReport))
+	assert.Equal(t, len(deploymentReport.Environments), 1)
+
+	operations := make(map[string]int)
+	for _, config := range deploymentReport.Environments[0].Configs {
+		operations[config.Operation]++
+	}
+	assert.Equal(t, operations[report.OperationFailed], 1)
+	assert.Assert(t, operations[report.OperationValidated] > 0)
+}
+
+func TestDeployFailsOnUnknownReportFormat(t *testing.T) {
+	err := Deploy(context.Background(), "", util.CreateTestFileSystem(), "", "", "", Options{ReportFile: "report.xml", ReportFormat: "xml"})
+	assert.ErrorContains(t, err, "unknown report format xml")
+}
+
 // TODO (CDF-6511) Currently here UnmarshallYaml logs fatal, only ever returns nil errors!
 // func TestInvalidEnvironmentFileResultsInError(t *testing.T) {
 // 	_, err := environment.LoadEnvironmentList("", "test-resources/invalid-environmentsfile.yaml")
@@ -130,3 +177,192 @@ func TestExecutePassOnDuplicateNamesInDifferentEnvironments(t *testing.T) {
 // }
 
 // TODO (CDF-6511) add tests when execute failures of single environments don't crash program anymore
+
+func TestDeployOnlySelectedConfigsAndTheirDependencies(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+
+	err := Deploy(context.Background(), dependencyGraphTestPath, fs, environmentsFile, "test1", "", Options{
+		DryRun:       true,
+		Configs:      []string{"project/alerting-profile/profile-a"},
+		ReportFile:   "report.json",
+		ReportFormat: report.FormatJson,
+	})
+	assert.NilError(t, err)
+
+	data, err := afero.ReadFile(fs, "report.json")
+	assert.NilError(t, err)
+
+	var deploymentReport report.Report
+	assert.NilError(t, json.Unmarshal(data, &deploymentReport))
+	assert.Equal(t, len(deploymentReport.Environments), 1)
+
+	var deployed []string
+	for _, config := range deploymentReport.Environments[0].Configs {
+		deployed = append(deployed, config.Config)
+	}
+	assert.DeepEqual(t, deployed, []string{
+		util.ReplacePathSeparators("project/management-zone/zone"),
+		util.ReplacePathSeparators("project/alerting-profile/profile-a"),
+	})
+}
+
+func TestDeployFailsIfNoConfigIsSelected(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+
+	err := Deploy(context.Background(), dependencyGraphTestPath, fs, environmentsFile, "test1", "", Options{
+		DryRun:  true,
+		Configs: []string{"project/dashboard/unknown*"},
+	})
+	assert.ErrorContains(t, err, "no config matches project/dashboard/unknown*")
+}
+
+func TestDeployFailsIfSkippedConfigIsRequiredByOtherProject(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	path := util.ReplacePathSeparators("./test-resources/skip-deployment-test")
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+
+	err := Deploy(context.Background(), path, fs, environmentsFile, "test1", "", Options{DryRun: true})
+	assert.NilError(t, err)
+
+	err = Deploy(context.Background(), path, fs, environmentsFile, "test3", "", Options{DryRun: true})
+	assert.NilError(t, err)
+
+	environments, _ := environment.LoadEnvironmentList("", environmentsFile, fs)
+	projects, err := project.LoadProjectsToDeploy(fs, "", api.NewApis(), path)
+	assert.NilError(t, err)
+
+	errors := checkSkippedConfigs(environments, projects)
+	assert.Equal(t, len(errors), 1)
+	assert.Equal(t, len(errors["test2"]), 1)
+	assert.ErrorContains(t, errors["test2"][0], util.ReplacePathSeparators("shared/management-zone/zone is required by [test-resources/skip-deployment-test/team/alerting-profile/profile]"))
+
+	err = Deploy(context.Background(), path, fs, environmentsFile, "test2", "", Options{DryRun: true})
+	assert.ErrorContains(t, err, "Errors during validation")
+}
+
+func TestDeployChecksSkippedConfigsBeforeDeploying(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	path := util.ReplacePathSeparators("./test-resources/skip-deployment-test")
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+
+	err := Deploy(context.Background(), path, fs, environmentsFile, "test2", "", Options{ContinueOnError: true, ReportFile: "report.json", ReportFormat: report.FormatJson})
+	assert.ErrorContains(t, err, "Errors during deployment")
+
+	// the deployment has not been started, so the missing token has not been noticed
+	data, err := afero.ReadFile(fs, "report.json")
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(data), "can't be skipped for deployment"))
+	assert.Assert(t, !strings.Contains(string(data), "TEST_TOKEN"))
+}
+
+func TestExecuteDeploysNothingIfContextIsDone(t *testing.T) {
+	projects := []project.Project{loadDependencyGraphTestProject(t)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result := execute(ctx, stateTestEnvironment, projects, dependencyGraphTestPath, Options{DryRun: true}, nil, nil, nil, nil)
+	assert.Assert(t, result.canceled)
+	assert.Assert(t, !result.completed)
+	assert.Equal(t, len(result.configs), 0)
+	assert.Equal(t, len(result.errors), 1)
+	assert.ErrorContains(t, result.errors[0], "deployment to dev has been canceled")
+}
+
+func TestDeployFailsIfContextIsDone(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	path := util.ReplacePathSeparators("./test-resources/duplicate-name-test")
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Deploy(ctx, path, fs, environmentsFile, "test1", "project2", Options{DryRun: true})
+	assert.ErrorContains(t, err, "Errors during validation")
+}
+
+func TestDeployFailsIfEnvironmentIsLocked(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	path := util.ReplacePathSeparators("./test-resources/duplicate-name-test")
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+
+	locker := lock.NewFileLocker(fs, lock.DefaultDir(path))
+	_, err := locker.Acquire(context.Background(), "test1", 0)
+	assert.NilError(t, err)
+
+	err = Deploy(context.Background(), path, fs, environmentsFile, "test1", "project2", Options{ReportFile: "report.json", ReportFormat: report.FormatJson})
+	assert.ErrorContains(t, err, "Errors during deployment")
+
+	data, err := afero.ReadFile(fs, "report.json")
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(data), "environment test1 is locked by"))
+
+	// dry runs don't need the lock
+	err = Deploy(context.Background(), path, fs, environmentsFile, "test1", "project2", Options{DryRun: true})
+	assert.NilError(t, err)
+}
+
+func TestDeployRemovesLocksOnForceUnlock(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	path := util.ReplacePathSeparators("./test-resources/duplicate-name-test")
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+
+	locker := lock.NewFileLocker(fs, lock.DefaultDir(path))
+	_, err := locker.Acquire(context.Background(), "test1", 0)
+	assert.NilError(t, err)
+
+	err = Deploy(context.Background(), path, fs, environmentsFile, "", "project2", Options{ForceUnlock: true})
+	assert.NilError(t, err)
+
+	_, err = locker.Acquire(context.Background(), "test1", 0)
+	assert.NilError(t, err)
+}
+
+func TestReleaseLocksReleasesAllLocks(t *testing.T) {
+	locker := lock.NewFileLocker(afero.NewMemMapFs(), "locks")
+
+	locks := make(map[string]lock.Lock)
+	for _, environmentId := range []string{"test1", "test2"} {
+		environmentLock, err := locker.Acquire(context.Background(), environmentId, 0)
+		assert.NilError(t, err)
+		locks[environmentId] = environmentLock
+	}
+
+	releaseLocks(locks)
+
+	for environmentId := range locks {
+		_, err := locker.Acquire(context.Background(), environmentId, 0)
+		assert.NilError(t, err)
+	}
+}
+
+func TestDeleteConfigsReturnsErrorsByEnvironment(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	assert.NilError(t, afero.WriteFile(fs, "project/delete.yaml", []byte("delete:\n  - \"management-zone/Zone\"\n"), 0644))
+
+	environments := map[string]environment.Environment{
+		"test": environment.NewEnvironment("test", "Test", "", "https://url/to/test/environment", "DELETE_TEST_MISSING_TOKEN"),
+	}
+
+	deletionErrors := deleteConfigs(context.Background(), api.NewApis(), environments, "project", Options{}, fs)
+	assert.Equal(t, len(deletionErrors), 1)
+	assert.ErrorContains(t, deletionErrors["test"], "DELETE_TEST_MISSING_TOKEN not found")
+
+	// configs aren't deleted during a dry run
+	deletionErrors = deleteConfigs(context.Background(), api.NewApis(), environments, "project", Options{DryRun: true}, fs)
+	assert.Equal(t, len(deletionErrors), 0)
+}
+
+func TestAddEnvironmentErrorToReport(t *testing.T) {
+	deploymentReport := report.Report{Environments: []report.EnvironmentReport{{Environment: "test1"}}}
+
+	addEnvironmentError(&deploymentReport, "test1", errors.New("deletion failed"))
+	addEnvironmentError(&deploymentReport, "test2", errors.New("no token"))
+
+	assert.DeepEqual(t, deploymentReport.Environments, []report.EnvironmentReport{
+		{Environment: "test1", Errors: []report.ErrorReport{{Message: "deletion failed"}}},
+		{Environment: "test2", Errors: []report.ErrorReport{{Message: "no token"}}},
+	})
+}
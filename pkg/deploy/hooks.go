@@ -0,0 +1,191 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/hook"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/report"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/spf13/afero"
+)
+
+const (
+	hookStatusSucceeded = "succeeded"
+	hookStatusFailed    = "failed"
+	hookStatusCanceled  = "canceled"
+)
+
+// hookInput is passed as json on stdin to every hook. Status and results are only set for post-deploy hooks.
+type hookInput struct {
+	Hook           string                `json:"hook"`
+	Environment    string                `json:"environment"`
+	EnvironmentUrl string                `json:"environmentUrl"`
+	Project        string                `json:"project,omitempty"`
+	Configs        []string              `json:"configs"`
+	Status         string                `json:"status,omitempty"`
+	Results        []report.ConfigReport `json:"results,omitempty"`
+}
+
+// hookRunner runs the hooks of an environment and of the projects deployed to it. Failing hooks are recorded as
+// environment errors, all outcomes are recorded in the hook reports of the deployment result.
+type hookRunner struct {
+	fs          afero.Fs
+	path        string
+	environment environment.Environment
+}
+
+func newHookRunner(fs afero.Fs, path string, environment environment.Environment) *hookRunner {
+	return &hookRunner{
+		fs:          fs,
+		path:        path,
+		environment: environment,
+	}
+}
+
+// runEnvironmentHook runs the given hook of the environment, if it has one. It returns false if the hook failed.
+func (r *hookRunner) runEnvironmentHook(ctx context.Context, log util.PrefixedLogger, name string, projects []project.Project,
+	result deploymentResult) (deploymentResult, bool) {
+
+	command := r.environment.GetHooks().Get(name)
+	if command == "" {
+		return result, true
+	}
+
+	input := r.newInput(name, projects)
+	if name == hook.PostDeploy {
+		input.Status = environmentStatus(ctx, result)
+		input.Results = result.configs
+	}
+
+	return r.run(ctx, log, name, "", command, "", input, result)
+}
+
+// runProjectHook runs the given hook of the project, if it has one. The results of the project are the config
+// reports starting at index firstResult. It returns false if the hook failed.
+func (r *hookRunner) runProjectHook(ctx context.Context, log util.PrefixedLogger, name string, proj project.Project,
+	result deploymentResult, firstResult int, aborted bool) (deploymentResult, bool) {
+
+	hooks, err := hook.LoadProjectHooks(r.fs, proj.GetId(), r.environment.GetId())
+	if err != nil {
+		return result.withEnvironmentError(err), false
+	}
+
+	command := hooks.Get(name)
+	if command == "" {
+		return result, true
+	}
+
+	input := r.newInput(name, []project.Project{proj})
+	input.Project = r.relative(proj.GetId())
+	if name == hook.PostDeploy {
+		input.Results = result.configs[firstResult:]
+		input.Status = projectStatus(ctx, input.Results, aborted)
+	}
+
+	return r.run(ctx, log, name, input.Project, command, proj.GetId(), input, result)
+}
+
+func (r *hookRunner) run(ctx context.Context, log util.PrefixedLogger, name string, projectId string, command string,
+	dir string, input hookInput, result deploymentResult) (deploymentResult, bool) {
+
+	scope := "environment " + r.environment.GetId()
+	if projectId != "" {
+		scope = "project " + projectId
+	}
+	log.Info("Running %s hook of %s: %s", name, scope, command)
+
+	// post-deploy hooks have to run even if the deployment has been canceled, e.g. to resume paused alerting
+	if name == hook.PostDeploy {
+		ctx = context.Background()
+	}
+
+	start := time.Now()
+	output, err := hook.Run(ctx, command, dir, input)
+	for _, line := range output {
+		log.Info("\t%s", line)
+	}
+
+	hookReport := report.HookReport{
+		Hook:       name,
+		Project:    projectId,
+		Command:    command,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%s hook of %s failed: %w", name, scope, err)
+		errorReport := report.NewErrorReport(err)
+		hookReport.Error = &errorReport
+
+		result.hooks = append(result.hooks, hookReport)
+		return result.withEnvironmentError(err), false
+	}
+
+	result.hooks = append(result.hooks, hookReport)
+	return result, true
+}
+
+func (r *hookRunner) newInput(name string, projects []project.Project) hookInput {
+
+	input := hookInput{
+		Hook:           name,
+		Environment:    r.environment.GetId(),
+		EnvironmentUrl: r.environment.GetEnvironmentUrl(),
+		Configs:        make([]string, 0),
+	}
+	for _, project := range projects {
+		for _, config := range project.GetConfigs() {
+			input.Configs = append(input.Configs, r.relative(config.GetFullQualifiedId()))
+		}
+	}
+	return input
+}
+
+func (r *hookRunner) relative(id string) string {
+	return strings.TrimPrefix(id, r.path+"/")
+}
+
+func environmentStatus(ctx context.Context, result deploymentResult) string {
+	switch {
+	case result.canceled || ctx.Err() != nil:
+		return hookStatusCanceled
+	case result.completed && len(result.errors) == 0:
+		return hookStatusSucceeded
+	default:
+		return hookStatusFailed
+	}
+}
+
+func projectStatus(ctx context.Context, results []report.ConfigReport, aborted bool) string {
+	if ctx.Err() != nil {
+		return hookStatusCanceled
+	}
+	if aborted {
+		return hookStatusFailed
+	}
+	for _, configReport := range results {
+		if configReport.Operation == report.OperationFailed {
+			return hookStatusFailed
+		}
+	}
+	return hookStatusSucceeded
+}
@@ -0,0 +1,109 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/hook"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/spf13/afero"
+	"gotest.tools/assert"
+)
+
+func newHookTestEnvironment(hooks hook.Hooks) environment.Environment {
+	return environment.NewEnvironmentWithHooks("dev", "Dev", "", "https://url/to/dev/environment", "DEV", hooks)
+}
+
+func TestFailingEnvironmentPreDeployHookAbortsEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a posix shell")
+	}
+
+	projects := []project.Project{loadDependencyGraphTestProject(t)}
+	environment := newHookTestEnvironment(hook.Hooks{PreDeploy: "exit 1", PostDeploy: "true"})
+	hooks := newHookRunner(afero.NewMemMapFs(), dependencyGraphTestPath, environment)
+
+	result := execute(context.Background(), environment, projects, dependencyGraphTestPath, Options{DryRun: true}, nil, nil, nil, hooks)
+	assert.Assert(t, !result.completed)
+	assert.Equal(t, len(result.configs), 0)
+	assert.Equal(t, len(result.errors), 1)
+	assert.ErrorContains(t, result.errors[0], "pre-deploy hook of environment dev failed")
+
+	// the post-deploy hook is not run, as the deployment has not been started
+	assert.Equal(t, len(result.hooks), 1)
+	assert.Equal(t, result.hooks[0].Hook, hook.PreDeploy)
+	assert.Assert(t, result.hooks[0].Error != nil)
+}
+
+func TestEnvironmentPostDeployHookReceivesResults(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a posix shell")
+	}
+
+	inputFile := filepath.Join(t.TempDir(), "input.json")
+
+	projects := []project.Project{loadDependencyGraphTestProject(t)}
+	environment := newHookTestEnvironment(hook.Hooks{PostDeploy: "cat > " + inputFile})
+	hooks := newHookRunner(afero.NewMemMapFs(), dependencyGraphTestPath, environment)
+
+	result := execute(context.Background(), environment, projects, dependencyGraphTestPath, Options{DryRun: true}, nil, nil, nil, hooks)
+	assert.Assert(t, result.completed)
+	assert.Equal(t, len(result.errors), 0)
+	assert.Equal(t, len(result.hooks), 1)
+	assert.Assert(t, result.hooks[0].Error == nil)
+
+	content, err := afero.ReadFile(afero.NewOsFs(), inputFile)
+	assert.NilError(t, err)
+
+	var input hookInput
+	assert.NilError(t, json.Unmarshal(content, &input))
+	assert.Equal(t, input.Hook, hook.PostDeploy)
+	assert.Equal(t, input.Environment, "dev")
+	assert.Equal(t, input.Status, hookStatusSucceeded)
+	assert.Equal(t, len(input.Configs), len(projects[0].GetConfigs()))
+	assert.Equal(t, len(input.Results), len(result.configs))
+}
+
+func TestFailingProjectPreDeployHookAbortsEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a posix shell")
+	}
+
+	dependencyGraph := loadDependencyGraphTestProject(t)
+	projects := []project.Project{dependencyGraph}
+
+	fs := afero.NewMemMapFs()
+	hooksFile := filepath.Join(dependencyGraph.GetId(), hook.ProjectHooksFile)
+	_ = afero.WriteFile(fs, hooksFile, []byte("hooks.dev:\n  - pre-deploy: \"exit 1\"\n"), 0664)
+
+	environment := newHookTestEnvironment(hook.Hooks{})
+	hooks := newHookRunner(fs, dependencyGraphTestPath, environment)
+
+	result := execute(context.Background(), environment, projects, dependencyGraphTestPath, Options{DryRun: true}, nil, nil, nil, hooks)
+	assert.Assert(t, !result.completed)
+	assert.Equal(t, len(result.configs), 0)
+	assert.Equal(t, len(result.errors), 1)
+	assert.ErrorContains(t, result.errors[0], "pre-deploy hook of project")
+	assert.Equal(t, len(result.hooks), 1)
+	assert.Assert(t, result.hooks[0].Project != "")
+}
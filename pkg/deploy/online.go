@@ -0,0 +1,88 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/config"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+)
+
+// checkReadAccess lists the objects of every API used by the given projects, to make sure the token of the
+// environment is allowed to read them
+func checkReadAccess(ctx context.Context, client rest.DynatraceClient, projects []project.Project) (errors []error) {
+
+	apis := make(map[string]api.Api)
+	for _, project := range projects {
+		for _, config := range project.GetConfigs() {
+			apis[config.GetApi().GetId()] = config.GetApi()
+		}
+	}
+
+	apiIds := make([]string, 0, len(apis))
+	for apiId := range apis {
+		apiIds = append(apiIds, apiId)
+	}
+	sort.Strings(apiIds)
+
+	for _, apiId := range apiIds {
+		_, err := client.List(ctx, apis[apiId])
+		if err != nil {
+			errors = append(errors, fmt.Errorf("the token can't read %s: %w", apiId, err))
+		}
+	}
+	return errors
+}
+
+// validateAgainstEnvironment looks up the object the config would be deployed to. If it exists, its id is
+// returned, so that configs referencing it are validated with the real id. Otherwise the given validated
+// entity is returned unchanged. It is an error if several objects with the name of the config exist, as it is
+// ambiguous which one would be updated.
+func validateAgainstEnvironment(ctx context.Context, log util.PrefixedLogger, client rest.DynatraceClient, config config.Config, name string,
+	validated api.DynatraceEntity) (api.DynatraceEntity, error) {
+
+	values, err := client.List(ctx, config.GetApi())
+	if err != nil {
+		return validated, err
+	}
+
+	var ids []string
+	for _, value := range values {
+		if value.Name == name {
+			ids = append(ids, value.Id)
+		}
+	}
+
+	switch len(ids) {
+	case 0:
+		log.Debug("\t\t\t%s would be created", config.GetFullQualifiedId())
+		return validated, nil
+	case 1:
+		log.Debug("\t\t\t%s would update existing object %s", config.GetFullQualifiedId(), ids[0])
+		return api.DynatraceEntity{
+			Id:   ids[0],
+			Name: name,
+		}, nil
+	default:
+		return validated, fmt.Errorf("%d objects of %s are named %s (%v), it is ambiguous which one %s would update",
+			len(ids), config.GetApi().GetId(), name, ids, config.GetFullQualifiedId())
+	}
+}
@@ -0,0 +1,86 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+func TestCheckReadAccessListsEveryApiOnce(t *testing.T) {
+	dependencyGraph := loadDependencyGraphTestProject(t)
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().List(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, a api.Api) ([]api.Value, error) {
+		if a.GetId() == "dashboard" {
+			return nil, errors.New("403 Forbidden")
+		}
+		return []api.Value{}, nil
+	}).Times(3)
+
+	errs := checkReadAccess(context.Background(), client, []project.Project{dependencyGraph})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "the token can't read dashboard: 403 Forbidden")
+}
+
+func TestValidateAgainstEnvironmentResolvesExistingObject(t *testing.T) {
+	zone := findConfig(t, loadDependencyGraphTestProject(t).GetConfigs(), "zone")
+	validated := api.DynatraceEntity{Id: "random-1", Name: "random-1"}
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().List(gomock.Any(), zone.GetApi()).Return([]api.Value{
+		{Id: "other-id", Name: "Other"},
+		{Id: "zone-id", Name: "Zone"},
+	}, nil)
+
+	entity, err := validateAgainstEnvironment(context.Background(), util.NewPrefixedLogger("dev"), client, zone, "Zone", validated)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, entity, api.DynatraceEntity{Id: "zone-id", Name: "Zone"})
+}
+
+func TestValidateAgainstEnvironmentKeepsValidatedEntityOfNewObject(t *testing.T) {
+	zone := findConfig(t, loadDependencyGraphTestProject(t).GetConfigs(), "zone")
+	validated := api.DynatraceEntity{Id: "random-1", Name: "random-1"}
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().List(gomock.Any(), zone.GetApi()).Return([]api.Value{{Id: "other-id", Name: "Other"}}, nil)
+
+	entity, err := validateAgainstEnvironment(context.Background(), util.NewPrefixedLogger("dev"), client, zone, "Zone", validated)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, entity, validated)
+}
+
+func TestValidateAgainstEnvironmentFailsOnNameCollision(t *testing.T) {
+	zone := findConfig(t, loadDependencyGraphTestProject(t).GetConfigs(), "zone")
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().List(gomock.Any(), zone.GetApi()).Return([]api.Value{
+		{Id: "zone-1", Name: "Zone"},
+		{Id: "zone-2", Name: "Zone"},
+	}, nil)
+
+	_, err := validateAgainstEnvironment(context.Background(), util.NewPrefixedLogger("dev"), client, zone, "Zone", api.DynatraceEntity{})
+	assert.ErrorContains(t, err, "2 objects of management-zone are named Zone ([zone-1 zone-2])")
+}
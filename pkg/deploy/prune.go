@@ -0,0 +1,181 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/state"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/spf13/afero"
+)
+
+// pruneEnvironments deletes the objects of all removed configs from their environments. Unless AssumeYes is set in
+// the options, the user has to confirm the deletion first.
+func pruneEnvironments(ctx context.Context, fs afero.Fs, workingDir string, environments map[string]environment.Environment,
+	apis map[string]api.Api, states map[string]*state.State, removedConfigs map[string][]string,
+	options Options, in io.Reader) error {
+
+	environmentIds := make([]string, 0, len(removedConfigs))
+	for environmentId := range removedConfigs {
+		environmentIds = append(environmentIds, environmentId)
+	}
+	sort.Strings(environmentIds)
+
+	util.Log.Info("The following objects will be pruned:")
+	for _, environmentId := range environmentIds {
+		util.Log.Info("\t%s:", environmentId)
+		for _, configId := range removedConfigs[environmentId] {
+			entry, _ := states[environmentId].Get(configId)
+			util.Log.Info("\t\t%s/%s (%s)", entry.Api, entry.Name, entry.Id)
+		}
+	}
+
+	if !options.AssumeYes && !confirm(in, "Do you want to delete these objects?") {
+		util.Log.Info("Pruning cancelled")
+		return nil
+	}
+
+	var pruneErrors = make(map[string][]error)
+
+	for _, environmentId := range environmentIds {
+		environment := environments[environmentId]
+		deploymentState := states[environmentId]
+
+		util.Log.Info("Pruning %d object(s) of environment %s...", len(removedConfigs[environmentId]), environmentId)
+
+		client, err := newClient(environment, options)
+		if err != nil {
+			pruneErrors[environmentId] = []error{err}
+			continue
+		}
+
+		errors := prune(ctx, client, apis, deploymentState, removedConfigs[environmentId])
+
+		err = deploymentState.Save(fs, state.FilePath(workingDir, environmentId))
+		if err != nil {
+			errors = append(errors, err)
+		}
+
+		if len(errors) > 0 {
+			pruneErrors[environmentId] = errors
+		}
+	}
+
+	for environment, errors := range pruneErrors {
+		util.Log.Error("Pruning of %s failed with %d error(s):\n", environment, len(errors))
+		util.PrintErrors(errors)
+	}
+
+	if len(pruneErrors) > 0 {
+		return fmt.Errorf("Errors during pruning! Check log!")
+	}
+	return nil
+}
+
+// prune deletes the objects of the given removed configs in reverse dependency order and removes them from the
+// state. Pruning stops at the first error, as the remaining objects might still be referenced by the failed one.
+func prune(ctx context.Context, client rest.DynatraceClient, apis map[string]api.Api, deploymentState *state.State, configIds []string) (errors []error) {
+
+	for _, configId := range sortForDeletion(deploymentState, configIds) {
+		entry, _ := deploymentState.Get(configId)
+
+		theApi, ok := apis[entry.Api]
+		if !ok {
+			return append(errors, fmt.Errorf("config %s has unknown api %s", configId, entry.Api))
+		}
+
+		util.Log.Debug("\tDeleting %s (%s) of removed config %s", entry.Name, entry.Id, configId)
+
+		err := client.DeleteById(ctx, theApi, entry.Id)
+		if err != nil {
+			return append(errors, fmt.Errorf("%s, responsible config: %s", err.Error(), configId))
+		}
+		deploymentState.Remove(configId)
+	}
+	return errors
+}
+
+// sortForDeletion orders the given configs, so that each config comes before all configs it depends on
+func sortForDeletion(deploymentState *state.State, configIds []string) []string {
+
+	remaining := make(map[string]bool, len(configIds))
+	for _, configId := range configIds {
+		remaining[configId] = true
+	}
+
+	// number of remaining configs depending on each config
+	dependents := make(map[string]int, len(configIds))
+	for _, configId := range configIds {
+		entry, _ := deploymentState.Get(configId)
+		for _, dependency := range entry.Dependencies {
+			if remaining[dependency] {
+				dependents[dependency]++
+			}
+		}
+	}
+
+	sorted := make([]string, 0, len(configIds))
+	for len(remaining) > 0 {
+
+		var next []string
+		for _, configId := range configIds {
+			if remaining[configId] && dependents[configId] == 0 {
+				next = append(next, configId)
+			}
+		}
+
+		// the dependencies of removed configs can't be cyclic, but don't loop forever if the state is broken
+		if len(next) == 0 {
+			for _, configId := range configIds {
+				if remaining[configId] {
+					next = append(next, configId)
+				}
+			}
+		}
+
+		for _, configId := range next {
+			delete(remaining, configId)
+			sorted = append(sorted, configId)
+
+			entry, _ := deploymentState.Get(configId)
+			for _, dependency := range entry.Dependencies {
+				dependents[dependency]--
+			}
+		}
+	}
+	return sorted
+}
+
+// confirm asks the given question and reads the answer from in. Only "y" and "yes" confirm the question.
+func confirm(in io.Reader, question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
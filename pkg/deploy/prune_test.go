@@ -0,0 +1,103 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/state"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+func testPruneState() *state.State {
+	deploymentState := state.New()
+	deploymentState.Put("project/management-zone/zone", state.Entry{
+		Project: "project",
+		Api:     "management-zone",
+		Id:      "zone-id",
+		Name:    "Zone",
+	})
+	deploymentState.Put("project/alerting-profile/profile", state.Entry{
+		Project:      "project",
+		Api:          "alerting-profile",
+		Id:           "profile-id",
+		Name:         "Profile",
+		Dependencies: []string{"project/management-zone/zone"},
+	})
+	deploymentState.Put("project/notification/notification", state.Entry{
+		Project:      "project",
+		Api:          "notification",
+		Id:           "notification-id",
+		Name:         "Notification",
+		Dependencies: []string{"project/alerting-profile/profile"},
+	})
+	return deploymentState
+}
+
+func TestSortForDeletionDeletesDependentsFirst(t *testing.T) {
+	sorted := sortForDeletion(testPruneState(), []string{
+		"project/management-zone/zone",
+		"project/alerting-profile/profile",
+		"project/notification/notification",
+	})
+
+	assert.DeepEqual(t, sorted, []string{
+		"project/notification/notification",
+		"project/alerting-profile/profile",
+		"project/management-zone/zone",
+	})
+}
+
+func TestPruneDeletesObjectsAndRemovesThemFromState(t *testing.T) {
+	deploymentState := testPruneState()
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	gomock.InOrder(
+		client.EXPECT().DeleteById(gomock.Any(), gomock.Any(), "profile-id").Return(nil),
+		client.EXPECT().DeleteById(gomock.Any(), gomock.Any(), "zone-id").Return(nil),
+	)
+
+	errs := prune(context.Background(), client, api.NewApis(), deploymentState, []string{"project/management-zone/zone", "project/alerting-profile/profile"})
+	assert.Equal(t, len(errs), 0)
+	assert.DeepEqual(t, deploymentState.ConfigIds(), []string{"project/notification/notification"})
+}
+
+func TestPruneStopsOnFirstError(t *testing.T) {
+	deploymentState := testPruneState()
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().DeleteById(gomock.Any(), gomock.Any(), "profile-id").Return(errors.New("delete failed"))
+
+	errs := prune(context.Background(), client, api.NewApis(), deploymentState, []string{"project/management-zone/zone", "project/alerting-profile/profile"})
+	assert.Equal(t, len(errs), 1)
+	assert.ErrorContains(t, errs[0], "delete failed, responsible config: project/alerting-profile/profile")
+	assert.Equal(t, len(deploymentState.ConfigIds()), 3)
+}
+
+func TestConfirm(t *testing.T) {
+	assert.Assert(t, confirm(strings.NewReader("y\n"), "Delete?"))
+	assert.Assert(t, confirm(strings.NewReader("YES\n"), "Delete?"))
+	assert.Assert(t, !confirm(strings.NewReader("\n"), "Delete?"))
+	assert.Assert(t, !confirm(strings.NewReader("no\n"), "Delete?"))
+	assert.Assert(t, !confirm(strings.NewReader(""), "Delete?"))
+}
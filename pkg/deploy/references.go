@@ -0,0 +1,108 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/config"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+)
+
+// referenceResolver resolves references to configs which are not deployed in the current run (e.g. because they
+// are skipped or part of a project which has not been selected) from the objects existing in the environment.
+// The referenced object is looked up by the name its config would have in the environment.
+type referenceResolver struct {
+	path string
+	// dependencies contains the configs each loaded config depends on, keyed by full qualified id
+	dependencies map[string][]config.Config
+}
+
+// newReferenceResolver determines the dependencies between all configs of the given projects. These have to
+// include the projects of all referenced configs, even if they are not deployed.
+func newReferenceResolver(projects []project.Project, path string) *referenceResolver {
+
+	var configs []config.Config
+	for _, project := range projects {
+		configs = append(configs, project.GetConfigs()...)
+	}
+
+	dependencies := make(map[string][]config.Config)
+	for _, config := range configs {
+		for _, other := range configs {
+			if config.HasDependencyOn(other) {
+				dependencies[config.GetFullQualifiedId()] = append(dependencies[config.GetFullQualifiedId()], other)
+			}
+		}
+	}
+
+	return &referenceResolver{
+		path:         path,
+		dependencies: dependencies,
+	}
+}
+
+// resolve adds the entities of all configs the given config depends on, which are missing in the dictionary.
+// Without a client (i.e. in a dry run), placeholder entities are added instead.
+func (r *referenceResolver) resolve(ctx context.Context, client rest.DynatraceClient, environment environment.Environment, config config.Config,
+	dict map[string]api.DynatraceEntity) error {
+
+	for _, dependency := range r.dependencies[config.GetFullQualifiedId()] {
+
+		referenceId := strings.TrimPrefix(dependency.GetFullQualifiedId(), r.path+"/")
+		if _, found := dict[referenceId]; found {
+			continue
+		}
+
+		// the name of the referenced config might itself reference other configs
+		err := r.resolve(ctx, client, environment, dependency, dict)
+		if err != nil {
+			return err
+		}
+
+		name, err := dependency.GetObjectNameForEnvironment(environment, dict)
+		if err != nil {
+			return err
+		}
+
+		if client == nil {
+			dict[referenceId] = api.DynatraceEntity{
+				Id:   "unresolved-" + referenceId,
+				Name: name,
+			}
+			continue
+		}
+
+		exists, id, err := client.ExistsByName(ctx, dependency.GetApi(), name)
+		if err != nil {
+			return fmt.Errorf("failed to look up %s referenced by %s: %w", referenceId, config.GetFullQualifiedId(), err)
+		}
+		if !exists {
+			return fmt.Errorf("%s references %s, which is not deployed and no %s named %s exists in the environment",
+				config.GetFullQualifiedId(), referenceId, dependency.GetApi().GetId(), name)
+		}
+
+		dict[referenceId] = api.DynatraceEntity{
+			Id:   id,
+			Name: name,
+		}
+	}
+	return nil
+}
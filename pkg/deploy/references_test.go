@@ -0,0 +1,92 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+func TestResolveLooksUpMissingReferenceInEnvironment(t *testing.T) {
+	dependencyGraph := loadDependencyGraphTestProject(t)
+	resolver := newReferenceResolver([]project.Project{dependencyGraph}, util.ReplacePathSeparators(dependencyGraphTestPath))
+
+	profile := findConfig(t, dependencyGraph.GetConfigs(), "profile-a")
+	zone := findConfig(t, dependencyGraph.GetConfigs(), "zone")
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().ExistsByName(gomock.Any(), zone.GetApi(), "Zone").Return(true, "zone-id", nil)
+
+	dict := make(map[string]api.DynatraceEntity)
+	err := resolver.resolve(context.Background(), client, stateTestEnvironment, profile, dict)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, dict["project/management-zone/zone"], api.DynatraceEntity{Id: "zone-id", Name: "Zone"})
+
+	rendered, err := profile.GetConfigForEnvironment(stateTestEnvironment, dict)
+	assert.NilError(t, err)
+	assert.Assert(t, len(rendered) > 0)
+}
+
+func TestResolveKeepsDeployedReferences(t *testing.T) {
+	dependencyGraph := loadDependencyGraphTestProject(t)
+	resolver := newReferenceResolver([]project.Project{dependencyGraph}, util.ReplacePathSeparators(dependencyGraphTestPath))
+
+	profile := findConfig(t, dependencyGraph.GetConfigs(), "profile-a")
+
+	// no calls expected
+	client := rest.CreateDynatraceClientMockFactory(t)
+
+	dict := map[string]api.DynatraceEntity{
+		"project/management-zone/zone": {Id: "deployed-id", Name: "Zone"},
+	}
+	err := resolver.resolve(context.Background(), client, stateTestEnvironment, profile, dict)
+	assert.NilError(t, err)
+	assert.Equal(t, dict["project/management-zone/zone"].Id, "deployed-id")
+}
+
+func TestResolveFailsIfReferencedObjectDoesNotExist(t *testing.T) {
+	dependencyGraph := loadDependencyGraphTestProject(t)
+	resolver := newReferenceResolver([]project.Project{dependencyGraph}, util.ReplacePathSeparators(dependencyGraphTestPath))
+
+	profile := findConfig(t, dependencyGraph.GetConfigs(), "profile-a")
+	zone := findConfig(t, dependencyGraph.GetConfigs(), "zone")
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().ExistsByName(gomock.Any(), zone.GetApi(), "Zone").Return(false, "", nil)
+
+	err := resolver.resolve(context.Background(), client, stateTestEnvironment, profile, make(map[string]api.DynatraceEntity))
+	assert.ErrorContains(t, err, "no management-zone named Zone exists in the environment")
+}
+
+func TestResolveAddsPlaceholdersInDryRun(t *testing.T) {
+	dependencyGraph := loadDependencyGraphTestProject(t)
+	resolver := newReferenceResolver([]project.Project{dependencyGraph}, util.ReplacePathSeparators(dependencyGraphTestPath))
+
+	profile := findConfig(t, dependencyGraph.GetConfigs(), "profile-a")
+
+	dict := make(map[string]api.DynatraceEntity)
+	err := resolver.resolve(context.Background(), nil, stateTestEnvironment, profile, dict)
+	assert.NilError(t, err)
+	assert.Equal(t, dict["project/management-zone/zone"].Name, "Zone")
+}
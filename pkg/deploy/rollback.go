@@ -0,0 +1,181 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/state"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+)
+
+// objectSnapshot is the remote object of a config before it has been deployed. The id is empty if the object
+// did not exist.
+type objectSnapshot struct {
+	id         string
+	name       string
+	json       []byte
+	stateEntry *state.Entry
+}
+
+// change is a single object created or updated during a deployment
+type change struct {
+	configId string
+	theApi   api.Api
+	id       string
+	previous objectSnapshot
+}
+
+// isCreation is true if the deployed object is not the one found before the deployment
+func (c change) isCreation() bool {
+	return c.previous.id != c.id
+}
+
+// rollbackResult describes which objects have been restored and deleted by a rollback
+type rollbackResult struct {
+	restored []string
+	deleted  []string
+	errors   []error
+}
+
+// rollbackJournal records all objects changed during the deployment to an environment, so that the changes can
+// be reverted if the deployment fails. It is safe for concurrent use.
+type rollbackJournal struct {
+	mutex   sync.Mutex
+	changes []change
+}
+
+// takeSnapshot reads the remote object of the config with the given id. The object is looked up by name.
+// If the config is contained in the state, the object is updated by its stored id, so it is looked up by the name
+// it had on its last deployment first.
+func takeSnapshot(ctx context.Context, client rest.DynatraceClient, deploymentState *state.State, configId string, theApi api.Api,
+	objectName string) (snapshot objectSnapshot, err error) {
+
+	var names []string
+
+	if deploymentState != nil {
+		if entry, found := deploymentState.Get(configId); found {
+			snapshot.stateEntry = &entry
+			if entry.Api == theApi.GetId() && entry.Name != objectName {
+				names = append(names, entry.Name)
+			}
+		}
+	}
+	names = append(names, objectName)
+
+	for _, name := range names {
+		exists, id, err := client.ExistsByName(ctx, theApi, name)
+		if err != nil {
+			return snapshot, err
+		}
+		if !exists {
+			continue
+		}
+
+		remote, err := client.ReadById(ctx, theApi, id)
+		if err != nil {
+			return snapshot, err
+		}
+
+		snapshot.id = id
+		snapshot.name = name
+		snapshot.json = remote
+		return snapshot, nil
+	}
+	return snapshot, nil
+}
+
+// record adds the change of a deployed config to the journal
+func (j *rollbackJournal) record(configId string, theApi api.Api, entity api.DynatraceEntity, previous objectSnapshot) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	j.changes = append(j.changes, change{
+		configId: configId,
+		theApi:   theApi,
+		id:       entity.Id,
+		previous: previous,
+	})
+}
+
+// rollback reverts all recorded changes in reverse order. Created objects are deleted and updated objects are
+// restored to their previous state. The state is reverted accordingly.
+func (j *rollbackJournal) rollback(ctx context.Context, log util.PrefixedLogger, client rest.DynatraceClient, deploymentState *state.State) (result rollbackResult) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	log.Info("Rolling back %d change(s)...", len(j.changes))
+
+	for i := len(j.changes) - 1; i >= 0; i-- {
+		change := j.changes[i]
+
+		var err error
+		if change.isCreation() {
+			log.Debug("\tDeleting created object %s (%s) of %s", change.id, change.theApi.GetId(), change.configId)
+			err = client.DeleteById(ctx, change.theApi, change.id)
+		} else {
+			log.Debug("\tRestoring object %s (%s) of %s", change.id, change.theApi.GetId(), change.configId)
+			err = restore(ctx, client, change)
+		}
+
+		if err != nil {
+			result.errors = append(result.errors, fmt.Errorf("rollback of %s failed: %s", change.configId, err))
+			continue
+		}
+
+		if change.isCreation() {
+			result.deleted = append(result.deleted, change.configId)
+		} else {
+			result.restored = append(result.restored, change.configId)
+		}
+
+		if deploymentState != nil {
+			if change.previous.stateEntry != nil {
+				deploymentState.Put(change.configId, *change.previous.stateEntry)
+			} else {
+				deploymentState.Remove(change.configId)
+			}
+		}
+	}
+	return result
+}
+
+// restore puts the previous json of an updated object. Server managed fields are removed from the snapshot
+// first, as they can't be part of an update.
+func restore(ctx context.Context, client rest.DynatraceClient, change change) error {
+
+	var previous map[string]interface{}
+	err := json.Unmarshal(change.previous.json, &previous)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range util.ServerManagedJsonKeys {
+		delete(previous, key)
+	}
+
+	payload, err := json.Marshal(previous)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.UpsertById(ctx, change.theApi, change.id, change.previous.name, payload)
+	return err
+}
@@ -0,0 +1,106 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/state"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+var (
+	rollbackZoneApi    = api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+	rollbackProfileApi = api.NewStandardApi("alerting-profile", "/api/config/v1/alertingProfiles")
+)
+
+func TestTakeSnapshotReadsExistingObject(t *testing.T) {
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().ExistsByName(gomock.Any(), rollbackZoneApi, "Zone").Return(true, "zone-id", nil)
+	client.EXPECT().ReadById(gomock.Any(), rollbackZoneApi, "zone-id").Return([]byte(`{"id": "zone-id"}`), nil)
+
+	snapshot, err := takeSnapshot(context.Background(), client, nil, "project/management-zone/zone", rollbackZoneApi, "Zone")
+	assert.NilError(t, err)
+	assert.Equal(t, snapshot.id, "zone-id")
+	assert.Equal(t, snapshot.name, "Zone")
+}
+
+func TestTakeSnapshotLooksUpRenamedObjectByPreviousName(t *testing.T) {
+	deploymentState := state.New()
+	deploymentState.Put("project/management-zone/zone", state.Entry{Api: "management-zone", Id: "zone-id", Name: "Old Zone"})
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().ExistsByName(gomock.Any(), rollbackZoneApi, "Old Zone").Return(true, "zone-id", nil)
+	client.EXPECT().ReadById(gomock.Any(), rollbackZoneApi, "zone-id").Return([]byte(`{"id": "zone-id"}`), nil)
+
+	snapshot, err := takeSnapshot(context.Background(), client, deploymentState, "project/management-zone/zone", rollbackZoneApi, "Zone")
+	assert.NilError(t, err)
+	assert.Equal(t, snapshot.id, "zone-id")
+	assert.Equal(t, snapshot.name, "Old Zone")
+	assert.Equal(t, snapshot.stateEntry.Name, "Old Zone")
+}
+
+func TestRollbackRevertsChangesInReverseOrder(t *testing.T) {
+	deploymentState := state.New()
+	deploymentState.Put("project/alerting-profile/profile", state.Entry{Api: "alerting-profile", Id: "profile-id", Name: "Profile"})
+
+	journal := &rollbackJournal{}
+	journal.record("project/management-zone/zone", rollbackZoneApi,
+		api.DynatraceEntity{Id: "zone-id", Name: "Zone"},
+		objectSnapshot{
+			id:   "zone-id",
+			name: "Old Zone",
+			json: []byte(`{"id": "zone-id", "metadata": {"clusterVersion": "1.0"}, "name": "Old Zone"}`),
+		})
+	journal.record("project/alerting-profile/profile", rollbackProfileApi,
+		api.DynatraceEntity{Id: "profile-id", Name: "Profile"},
+		objectSnapshot{})
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	gomock.InOrder(
+		client.EXPECT().DeleteById(gomock.Any(), rollbackProfileApi, "profile-id").Return(nil),
+		client.EXPECT().UpsertById(gomock.Any(), rollbackZoneApi, "zone-id", "Old Zone", []byte(`{"name":"Old Zone"}`)).
+			Return(api.DynatraceEntity{Id: "zone-id", Name: "Old Zone"}, nil),
+	)
+
+	result := journal.rollback(context.Background(), util.NewPrefixedLogger("dev"), client, deploymentState)
+	assert.Equal(t, len(result.errors), 0)
+	assert.DeepEqual(t, result.deleted, []string{"project/alerting-profile/profile"})
+	assert.DeepEqual(t, result.restored, []string{"project/management-zone/zone"})
+	assert.Equal(t, len(deploymentState.ConfigIds()), 0)
+}
+
+func TestRollbackContinuesAfterError(t *testing.T) {
+	journal := &rollbackJournal{}
+	journal.record("project/management-zone/zone", rollbackZoneApi, api.DynatraceEntity{Id: "zone-id"}, objectSnapshot{})
+	journal.record("project/alerting-profile/profile", rollbackProfileApi, api.DynatraceEntity{Id: "profile-id"}, objectSnapshot{})
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().DeleteById(gomock.Any(), rollbackProfileApi, "profile-id").Return(errors.New("delete failed"))
+	client.EXPECT().DeleteById(gomock.Any(), rollbackZoneApi, "zone-id").Return(nil)
+
+	result := journal.rollback(context.Background(), util.NewPrefixedLogger("dev"), client, nil)
+	assert.Equal(t, len(result.errors), 1)
+	assert.ErrorContains(t, result.errors[0], "rollback of project/alerting-profile/profile failed: delete failed")
+	assert.DeepEqual(t, result.deleted, []string{"project/management-zone/zone"})
+}
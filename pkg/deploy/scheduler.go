@@ -0,0 +1,130 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/config"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+)
+
+// entityDictionary maps the reference ids of deployed configs to their Dynatrace entities.
+// It is safe for concurrent use.
+type entityDictionary struct {
+	mutex    sync.RWMutex
+	entities map[string]api.DynatraceEntity
+}
+
+func newEntityDictionary() *entityDictionary {
+	return &entityDictionary{
+		entities: make(map[string]api.DynatraceEntity),
+	}
+}
+
+func (d *entityDictionary) put(referenceId string, entity api.DynatraceEntity) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.entities[referenceId] = entity
+}
+
+// snapshot returns a copy of the dictionary, which can be used to render a config while other configs are
+// deployed concurrently
+func (d *entityDictionary) snapshot() map[string]api.DynatraceEntity {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	entities := make(map[string]api.DynatraceEntity, len(d.entities))
+	for referenceId, entity := range d.entities {
+		entities[referenceId] = entity
+	}
+	return entities
+}
+
+// scheduleConfigs calls deploy for each config of the project as soon as all configs it depends on are done.
+// At most parallel configs are deployed at the same time. Ready configs are scheduled in the sorted order of the
+// project, so a parallelism of one results in the same order as a sequential deployment.
+// As soon as deploy returns false, no further configs are scheduled. In this case aborted is true.
+func scheduleConfigs(project project.Project, parallel int, deploy func(config config.Config) bool) (aborted bool) {
+
+	configs := project.GetConfigs()
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	indices := make(map[string]int, len(configs))
+	for i, config := range configs {
+		indices[config.GetFullQualifiedId()] = i
+	}
+
+	pending := make([]int, len(configs))
+	dependents := make([][]int, len(configs))
+	for i, config := range configs {
+		for _, dependency := range project.GetConfigDependencies(config) {
+			if j, ok := indices[dependency]; ok {
+				pending[i]++
+				dependents[j] = append(dependents[j], i)
+			}
+		}
+	}
+
+	var ready []int
+	for i := range configs {
+		if pending[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+
+	type completion struct {
+		index   int
+		success bool
+	}
+	done := make(chan completion)
+	running := 0
+
+	for {
+		for !aborted && running < parallel && len(ready) > 0 {
+			next := ready[0]
+			ready = ready[1:]
+			running++
+
+			go func(index int) {
+				done <- completion{index: index, success: deploy(configs[index])}
+			}(next)
+		}
+
+		if running == 0 {
+			return aborted
+		}
+
+		finished := <-done
+		running--
+
+		if !finished.success {
+			aborted = true
+		}
+
+		for _, dependent := range dependents[finished.index] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+		sort.Ints(ready)
+	}
+}
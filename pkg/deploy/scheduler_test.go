@@ -0,0 +1,102 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/config"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"gotest.tools/assert"
+)
+
+func loadDependencyGraphTestProject(t *testing.T) project.Project {
+	path := util.ReplacePathSeparators(dependencyGraphTestPath)
+	projects, err := project.LoadProjectsToDeploy(util.CreateTestFileSystem(), "", api.NewApis(), path)
+	assert.NilError(t, err)
+	assert.Equal(t, len(projects), 1)
+	return projects[0]
+}
+
+func TestScheduleConfigsDeploysDependenciesFirst(t *testing.T) {
+	project := loadDependencyGraphTestProject(t)
+
+	var mutex sync.Mutex
+	position := make(map[string]int)
+
+	aborted := scheduleConfigs(project, 4, func(config config.Config) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		position[config.GetId()] = len(position)
+		return true
+	})
+
+	assert.Assert(t, !aborted)
+	assert.Equal(t, len(position), 4)
+	assert.Assert(t, position["zone"] < position["profile-a"])
+	assert.Assert(t, position["zone"] < position["profile-b"])
+}
+
+func TestScheduleConfigsKeepsSortedOrderWithoutParallelism(t *testing.T) {
+	project := loadDependencyGraphTestProject(t)
+
+	var expected []string
+	for _, config := range project.GetConfigs() {
+		expected = append(expected, config.GetFullQualifiedId())
+	}
+
+	var deployed []string
+	scheduleConfigs(project, 1, func(config config.Config) bool {
+		deployed = append(deployed, config.GetFullQualifiedId())
+		return true
+	})
+
+	assert.DeepEqual(t, deployed, expected)
+}
+
+func TestScheduleConfigsStopsSchedulingOnFailure(t *testing.T) {
+	project := loadDependencyGraphTestProject(t)
+
+	var mutex sync.Mutex
+	var deployed []string
+
+	aborted := scheduleConfigs(project, 4, func(config config.Config) bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		deployed = append(deployed, config.GetId())
+		return config.GetId() != "zone"
+	})
+
+	assert.Assert(t, aborted)
+	for _, id := range deployed {
+		assert.Assert(t, id != "profile-a" && id != "profile-b", "config %s depends on failed config", id)
+	}
+}
+
+func TestEntityDictionarySnapshotIsACopy(t *testing.T) {
+	dict := newEntityDictionary()
+	dict.put("project/management-zone/zone", api.DynatraceEntity{Id: "zone-id", Name: "Zone"})
+
+	snapshot := dict.snapshot()
+	dict.put("project/dashboard/overview", api.DynatraceEntity{Id: "dashboard-id", Name: "Overview"})
+
+	assert.Equal(t, len(snapshot), 1)
+	assert.Equal(t, snapshot["project/management-zone/zone"].Id, "zone-id")
+}
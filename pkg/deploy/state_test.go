@@ -0,0 +1,139 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/config"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/state"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+const dependencyGraphTestPath = "test-resources/dependency-graph-test"
+
+var stateTestEnvironment = environment.NewEnvironment("dev", "Dev", "", "https://url/to/dev/environment", "DEV")
+
+func findConfig(t *testing.T, configs []config.Config, id string) config.Config {
+	for _, config := range configs {
+		if config.GetId() == id {
+			return config
+		}
+	}
+	t.Fatalf("config %s not found", id)
+	return nil
+}
+
+func TestUploadConfigUsesIdFromState(t *testing.T) {
+	testProject := loadDependencyGraphTestProject(t)
+	overview := findConfig(t, testProject.GetConfigs(), "overview")
+
+	deploymentState := state.New()
+	deploymentState.Put("project/dashboard/overview", state.Entry{
+		Project: "project",
+		Api:     "dashboard",
+		Id:      "dashboard-id",
+		Name:    "Old Overview",
+	})
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().UpsertById(gomock.Any(), gomock.Any(), "dashboard-id", "Overview", gomock.Any()).
+		Return(api.DynatraceEntity{Id: "dashboard-id", Name: "Overview"}, nil)
+
+	log := util.NewPrefixedLogger("dev")
+	_, err := uploadConfig(context.Background(), log, client, deploymentState, dependencyGraphTestPath, indexConfigs([]project.Project{testProject}), overview, map[string]api.DynatraceEntity{}, stateTestEnvironment)
+	assert.NilError(t, err)
+
+	entry, found := deploymentState.Get("project/dashboard/overview")
+	assert.Assert(t, found)
+	assert.Equal(t, entry.Name, "Overview")
+	assert.Assert(t, entry.PayloadHash != "")
+}
+
+func TestUploadConfigStoresCreatedObjectsInState(t *testing.T) {
+	testProject := loadDependencyGraphTestProject(t)
+	zone := findConfig(t, testProject.GetConfigs(), "zone")
+
+	deploymentState := state.New()
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().UpsertByName(gomock.Any(), gomock.Any(), "Zone", gomock.Any()).
+		Return(api.DynatraceEntity{Id: "zone-id", Name: "Zone"}, nil)
+
+	log := util.NewPrefixedLogger("dev")
+	_, err := uploadConfig(context.Background(), log, client, deploymentState, dependencyGraphTestPath, indexConfigs([]project.Project{testProject}), zone, map[string]api.DynatraceEntity{}, stateTestEnvironment)
+	assert.NilError(t, err)
+
+	entry, found := deploymentState.Get("project/management-zone/zone")
+	assert.Assert(t, found)
+	assert.DeepEqual(t, entry, state.Entry{
+		Project:     "project",
+		Api:         "management-zone",
+		Id:          "zone-id",
+		Name:        "Zone",
+		PayloadHash: entry.PayloadHash,
+	})
+}
+
+func TestFindRemovedConfigs(t *testing.T) {
+	projects := []project.Project{loadDependencyGraphTestProject(t)}
+
+	deploymentState := state.New()
+	deploymentState.Put("project/management-zone/zone", state.Entry{Project: "project", Api: "management-zone", Name: "Zone"})
+	deploymentState.Put("project/dashboard/removed", state.Entry{Project: "project", Api: "dashboard", Name: "Removed"})
+	deploymentState.Put("other-project/dashboard/overview", state.Entry{Project: "other-project", Api: "dashboard", Name: "Other"})
+
+	removed := findRemovedConfigs(deploymentState, projects, dependencyGraphTestPath, false)
+	assert.DeepEqual(t, removed, []string{"project/dashboard/removed"})
+
+	// if all projects are loaded, the configs of other projects have been removed with their project
+	removed = findRemovedConfigs(deploymentState, projects, dependencyGraphTestPath, true)
+	assert.DeepEqual(t, removed, []string{"other-project/dashboard/overview", "project/dashboard/removed"})
+}
+
+func TestUploadConfigStoresDependenciesOnOtherProjects(t *testing.T) {
+	path := util.ReplacePathSeparators("test-resources/cross-project-test")
+	projects, err := project.LoadProjectsToDeploy(util.CreateTestFileSystem(), "", api.NewApis(), path)
+	assert.NilError(t, err)
+
+	configs := indexConfigs(projects)
+	profile := configs[path+"/profiles/alerting-profile/profile"]
+	assert.Assert(t, profile != nil)
+
+	deploymentState := state.New()
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().UpsertByName(gomock.Any(), gomock.Any(), "Profile", gomock.Any()).
+		Return(api.DynatraceEntity{Id: "profile-id", Name: "Profile"}, nil)
+
+	dict := map[string]api.DynatraceEntity{"zones/management-zone/zone": {Id: "zone-id", Name: "Zone"}}
+
+	log := util.NewPrefixedLogger("dev")
+	_, err = uploadConfig(context.Background(), log, client, deploymentState, path, configs, profile, dict, stateTestEnvironment)
+	assert.NilError(t, err)
+
+	entry, found := deploymentState.Get("profiles/alerting-profile/profile")
+	assert.Assert(t, found)
+	assert.DeepEqual(t, entry.Dependencies, []string{"zones/management-zone/zone"})
+}
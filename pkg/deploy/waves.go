@@ -0,0 +1,173 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/hook"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+)
+
+// wave is a set of environments which are deployed together. The next wave is only deployed if all environments
+// of a wave have been deployed without errors and the gate passed.
+type wave struct {
+	// group is the environment group of the wave, empty if the deployment is not split into waves
+	group        string
+	environments map[string]environment.Environment
+	// failed are the ids of the environments of the wave whose deployment failed
+	failed []string
+	// notStarted is the reason why the wave has not been deployed, e.g. because a previous wave failed
+	notStarted error
+}
+
+// gateInput is passed as json on stdin to the gate command
+type gateInput struct {
+	Wave         string   `json:"wave"`
+	Environments []string `json:"environments"`
+	NextWave     string   `json:"nextWave"`
+}
+
+// planWaves splits the environments into one wave per group, in the given order. Without groups, all environments
+// are deployed in a single wave. It is an error if a group doesn't contain any environment, or if an environment
+// is not part of any wave.
+func planWaves(environments map[string]environment.Environment, groups []string) ([]*wave, error) {
+
+	if len(groups) == 0 {
+		return []*wave{{environments: environments}}, nil
+	}
+
+	waves := make([]*wave, 0, len(groups))
+	byGroup := make(map[string]*wave)
+
+	for _, group := range groups {
+		group = strings.TrimSpace(group)
+		if byGroup[group] != nil {
+			return nil, fmt.Errorf("group %s is part of more than one wave", group)
+		}
+
+		w := &wave{
+			group:        group,
+			environments: make(map[string]environment.Environment),
+		}
+		waves = append(waves, w)
+		byGroup[group] = w
+	}
+
+	var missing []string
+	for id, environment := range environments {
+		w := byGroup[environment.GetGroup()]
+		if w == nil {
+			missing = append(missing, id)
+			continue
+		}
+		w.environments[id] = environment
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("environment(s) %s are not part of any wave, add their group to --waves", strings.Join(missing, ", "))
+	}
+
+	for _, w := range waves {
+		if len(w.environments) == 0 {
+			return nil, fmt.Errorf("wave %s doesn't contain any environment", w.group)
+		}
+	}
+	return waves, nil
+}
+
+// checkWave returns why the next wave must not be deployed, or nil if it can be deployed. This is the case if all
+// environments of the previous wave have been deployed without errors and the gate passed.
+func checkWave(ctx context.Context, options Options, previous *wave, next *wave) error {
+
+	if previous.notStarted != nil {
+		return previous.notStarted
+	}
+	if len(previous.failed) > 0 {
+		return fmt.Errorf("wave %s failed", previous.group)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("the deployment has been canceled")
+	}
+
+	err := passGate(ctx, options, previous, next)
+	if err != nil {
+		return fmt.Errorf("the gate did not pass: %w", err)
+	}
+	return nil
+}
+
+// passGate waits for the given time and runs the gate command, if any, before the next wave is deployed.
+// The gate passes if the command exits with exit code 0.
+func passGate(ctx context.Context, options Options, previous *wave, next *wave) error {
+
+	if options.WaveWait > 0 {
+		util.Log.Info("Waiting %s before deploying wave %s...", options.WaveWait, next.group)
+
+		err := util.NewTimelineProvider().Sleep(ctx, options.WaveWait)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.WaveGate == "" {
+		return nil
+	}
+
+	util.Log.Info("Running gate before wave %s: %s", next.group, options.WaveGate)
+
+	input := gateInput{
+		Wave:         previous.group,
+		Environments: previous.environmentIds(),
+		NextWave:     next.group,
+	}
+
+	output, err := hook.Run(ctx, options.WaveGate, "", input)
+	for _, line := range output {
+		util.Log.Info("\t%s", line)
+	}
+	return err
+}
+
+func (w *wave) environmentIds() []string {
+	ids := make([]string, 0, len(w.environments))
+	for id := range w.environments {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// printWaveSummary logs the outcome of each wave
+func printWaveSummary(waves []*wave) {
+	for i, w := range waves {
+		switch {
+		case w.notStarted != nil:
+			util.Log.Warn("Wave %d (%s): not started, %s", i+1, w.group, w.notStarted)
+		case len(w.failed) > 0:
+			sort.Strings(w.failed)
+			util.Log.Error("Wave %d (%s): %d of %d environment(s) failed: %s", i+1, w.group, len(w.failed),
+				len(w.environments), strings.Join(w.failed, ", "))
+		default:
+			util.Log.Info("Wave %d (%s): %d environment(s) deployed without errors: %s", i+1, w.group,
+				len(w.environments), strings.Join(w.environmentIds(), ", "))
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deploy
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"gotest.tools/assert"
+)
+
+func newWaveTestEnvironments() map[string]environment.Environment {
+	return map[string]environment.Environment{
+		"dev1":    environment.NewEnvironment("dev1", "Dev 1", "dev", "https://dev1.com", "TOKEN"),
+		"dev2":    environment.NewEnvironment("dev2", "Dev 2", "dev", "https://dev2.com", "TOKEN"),
+		"staging": environment.NewEnvironment("staging", "Staging", "staging", "https://staging.com", "TOKEN"),
+		"prod":    environment.NewEnvironment("prod", "Prod", "prod", "https://prod.com", "TOKEN"),
+	}
+}
+
+func TestPlanWavesSplitsEnvironmentsByGroup(t *testing.T) {
+	waves, err := planWaves(newWaveTestEnvironments(), []string{"dev", "staging", "prod"})
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(waves), 3)
+	assert.Equal(t, waves[0].group, "dev")
+	assert.DeepEqual(t, waves[0].environmentIds(), []string{"dev1", "dev2"})
+	assert.Equal(t, waves[1].group, "staging")
+	assert.DeepEqual(t, waves[1].environmentIds(), []string{"staging"})
+	assert.Equal(t, waves[2].group, "prod")
+	assert.DeepEqual(t, waves[2].environmentIds(), []string{"prod"})
+}
+
+func TestPlanWavesWithoutGroupsDeploysAllEnvironmentsAtOnce(t *testing.T) {
+	waves, err := planWaves(newWaveTestEnvironments(), nil)
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(waves), 1)
+	assert.Equal(t, len(waves[0].environments), 4)
+}
+
+func TestPlanWavesFailsOnEnvironmentsNotPartOfAnyWave(t *testing.T) {
+	_, err := planWaves(newWaveTestEnvironments(), []string{"dev", "prod"})
+	assert.ErrorContains(t, err, "environment(s) staging are not part of any wave")
+}
+
+func TestPlanWavesFailsOnEmptyWave(t *testing.T) {
+	_, err := planWaves(newWaveTestEnvironments(), []string{"dev", "staging", "qa", "prod"})
+	assert.ErrorContains(t, err, "wave qa doesn't contain any environment")
+}
+
+func TestPlanWavesFailsOnDuplicateGroup(t *testing.T) {
+	_, err := planWaves(newWaveTestEnvironments(), []string{"dev", "staging", "prod", "dev"})
+	assert.ErrorContains(t, err, "group dev is part of more than one wave")
+}
+
+func TestCheckWaveFailsIfPreviousWaveFailed(t *testing.T) {
+	waves, err := planWaves(newWaveTestEnvironments(), []string{"dev", "staging", "prod"})
+	assert.NilError(t, err)
+
+	waves[0].failed = []string{"dev2"}
+	waves[1].notStarted = checkWave(context.Background(), Options{}, waves[0], waves[1])
+	assert.ErrorContains(t, waves[1].notStarted, "wave dev failed")
+
+	// later waves are not started for the same reason
+	err = checkWave(context.Background(), Options{}, waves[1], waves[2])
+	assert.ErrorContains(t, err, "wave dev failed")
+}
+
+func TestCheckWaveRunsGate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a posix shell")
+	}
+
+	waves, err := planWaves(newWaveTestEnvironments(), []string{"dev", "staging", "prod"})
+	assert.NilError(t, err)
+
+	err = checkWave(context.Background(), Options{WaveGate: `grep -q '"nextWave":"staging"'`}, waves[0], waves[1])
+	assert.NilError(t, err)
+
+	err = checkWave(context.Background(), Options{WaveGate: "exit 1"}, waves[0], waves[1])
+	assert.ErrorContains(t, err, "the gate did not pass")
+}
+
+func TestCheckWaveStopsWaitingIfContextIsDone(t *testing.T) {
+	waves, err := planWaves(newWaveTestEnvironments(), []string{"dev", "staging", "prod"})
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+
+	err = checkWave(ctx, Options{WaveWait: time.Hour}, waves[0], waves[1])
+	assert.ErrorContains(t, err, context.Canceled.Error())
+}
+
+func TestDeployFailsIfEnvironmentIsNotPartOfAnyWave(t *testing.T) {
+	fs := util.CreateTestFileSystem()
+	path := util.ReplacePathSeparators("./test-resources/duplicate-name-test")
+	environmentsFile := "../../cmd/monaco/test-resources/test-environments.yaml"
+
+	err := Deploy(context.Background(), path, fs, environmentsFile, "", "project2", Options{DryRun: true, Waves: []string{"dev"}})
+	assert.ErrorContains(t, err, "are not part of any wave")
+}
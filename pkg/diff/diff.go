@@ -0,0 +1,317 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/config"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/delete"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/spf13/afero"
+)
+
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+	ActionDelete    = "delete"
+)
+
+// EnvironmentDiff contains the changes a deployment would apply to a single environment
+type EnvironmentDiff struct {
+	Environment    string       `json:"environment"`
+	EnvironmentUrl string       `json:"environmentUrl"`
+	Configs        []ConfigDiff `json:"configs"`
+}
+
+// ConfigDiff describes how a deployment would change the Dynatrace object of a single config
+type ConfigDiff struct {
+	ConfigId string            `json:"config"`
+	Api      string            `json:"api"`
+	Name     string            `json:"name"`
+	Id       string            `json:"id,omitempty"`
+	Action   string            `json:"action"`
+	Changes  []util.JsonChange `json:"changes,omitempty"`
+
+	// Payload is the rendered config. It is empty for deletions.
+	Payload []byte `json:"-"`
+	// Remote is the json of the live object. It is empty if the object does not exist yet.
+	Remote []byte `json:"-"`
+}
+
+// Diff renders all configs of the given projects for each environment, compares them with the live objects of
+// the environment and prints the resulting diff as json to the given output file (or stdout, if no file is given).
+// The rate limit strategy and rate limit are passed to the clients, see rest.WithRateLimitStrategy and rest.WithRateLimit.
+func Diff(ctx context.Context, workingDir string, fs afero.Fs, environmentsFile string, specificEnvironment string, proj string, outputFile string,
+	rateLimitStrategy string, rateLimit int) error {
+
+	diffs, diffErrors, err := CalculateDiffs(ctx, workingDir, fs, environmentsFile, specificEnvironment, proj, rateLimitStrategy, rateLimit)
+	if err != nil {
+		return err
+	}
+
+	err = writeDiff(fs, diffs, outputFile)
+	if err != nil {
+		return err
+	}
+
+	util.Log.Info("Diff summary:")
+	for _, environmentDiff := range diffs {
+		util.Log.Info("\t%s: %s", environmentDiff.Environment, summarize(environmentDiff))
+	}
+	for environment, errors := range diffErrors {
+		util.Log.Error("Diff of %s failed with %d error(s):\n", environment, len(errors))
+		util.PrintErrors(errors)
+	}
+
+	if len(diffErrors) > 0 {
+		return fmt.Errorf("Errors during diff! Check log!")
+	}
+	return nil
+}
+
+// CalculateDiffs loads the environments and projects and calculates the diff for each environment.
+// Errors which only affect a single environment are returned per environment id.
+func CalculateDiffs(ctx context.Context, workingDir string, fs afero.Fs, environmentsFile string, specificEnvironment string,
+	proj string, rateLimitStrategy string, rateLimit int) (diffs []EnvironmentDiff, diffErrors map[string][]error, err error) {
+
+	environments, errors := environment.LoadEnvironmentList(specificEnvironment, environmentsFile, fs)
+	if len(errors) > 0 {
+		util.PrintErrors(errors)
+		return nil, nil, fmt.Errorf("There were some errors while getting environment files")
+	}
+
+	workingDir = filepath.Clean(workingDir)
+	apis := api.NewApis()
+
+	projects, err := project.LoadProjectsToDeploy(fs, proj, apis, workingDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configsToDelete, err := delete.LoadConfigsToDelete(fs, apis, workingDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	diffs = make([]EnvironmentDiff, 0, len(environments))
+	diffErrors = make(map[string][]error)
+
+	for _, environment := range sortedEnvironments(environments) {
+
+		util.Log.Info("Calculating diff for environment " + environment.GetId() + "...")
+
+		client, err := newClient(environment, rateLimitStrategy, rateLimit)
+		if err != nil {
+			diffErrors[environment.GetId()] = []error{err}
+			continue
+		}
+
+		environmentDiff, errors := CalculateDiff(ctx, client, environment, projects, configsToDelete, workingDir)
+		if len(errors) > 0 {
+			diffErrors[environment.GetId()] = errors
+		}
+		diffs = append(diffs, environmentDiff)
+	}
+
+	return diffs, diffErrors, nil
+}
+
+// CalculateDiff compares the rendered configs of the given projects with the live objects of the environment the
+// client points to. Configs which are going to be created don't have an id yet. References to them are rendered
+// using PendingId.
+func CalculateDiff(ctx context.Context, client rest.DynatraceClient, environment environment.Environment, projects []project.Project,
+	configsToDelete []config.Config, path string) (environmentDiff EnvironmentDiff, errors []error) {
+
+	environmentDiff = EnvironmentDiff{
+		Environment:    environment.GetId(),
+		EnvironmentUrl: environment.GetEnvironmentUrl(),
+		Configs:        make([]ConfigDiff, 0),
+	}
+	dict := make(map[string]api.DynatraceEntity)
+
+	for _, project := range projects {
+		for _, config := range project.GetConfigs() {
+
+			if config.IsSkipDeployment(environment) {
+				util.Log.Debug("\tskipping diff of %s: %s", config.GetId(), config.GetFilePath())
+				continue
+			}
+
+			configDiff, err := diffConfig(ctx, client, environment, config, dict)
+			if err != nil {
+				errors = append(errors, fmt.Errorf("%s, responsible config: %s", err.Error(), config.GetFilePath()))
+				continue
+			}
+
+			referenceId := strings.TrimPrefix(config.GetFullQualifiedId(), path+"/")
+			configDiff.ConfigId = referenceId
+
+			id := configDiff.Id
+			if configDiff.Action == ActionCreate {
+				id = PendingId(referenceId)
+			}
+			dict[referenceId] = api.DynatraceEntity{
+				Id:   id,
+				Name: configDiff.Name,
+			}
+
+			environmentDiff.Configs = append(environmentDiff.Configs, configDiff)
+		}
+	}
+
+	for _, config := range configsToDelete {
+
+		exists, id, err := client.ExistsByName(ctx, config.GetApi(), config.GetId())
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		if !exists {
+			continue
+		}
+
+		remote, err := client.ReadById(ctx, config.GetApi(), id)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+
+		environmentDiff.Configs = append(environmentDiff.Configs, ConfigDiff{
+			ConfigId: config.GetApi().GetId() + "/" + config.GetId(),
+			Api:      config.GetApi().GetId(),
+			Name:     config.GetId(),
+			Id:       id,
+			Action:   ActionDelete,
+			Remote:   remote,
+		})
+	}
+
+	return environmentDiff, errors
+}
+
+// PendingId returns the placeholder used instead of the id of a config which does not exist yet
+func PendingId(referenceId string) string {
+	return "[pending-id:" + referenceId + "]"
+}
+
+func diffConfig(ctx context.Context, client rest.DynatraceClient, environment environment.Environment, config config.Config,
+	dict map[string]api.DynatraceEntity) (configDiff ConfigDiff, err error) {
+
+	name, err := config.GetObjectNameForEnvironment(environment, dict)
+	if err != nil {
+		return configDiff, err
+	}
+
+	payload, err := config.GetConfigForEnvironment(environment, dict)
+	if err != nil {
+		return configDiff, err
+	}
+
+	configDiff = ConfigDiff{
+		Api:     config.GetApi().GetId(),
+		Name:    name,
+		Payload: payload,
+	}
+
+	exists, id, err := client.ExistsByName(ctx, config.GetApi(), name)
+	if err != nil {
+		return configDiff, err
+	}
+
+	if !exists {
+		configDiff.Action = ActionCreate
+		return configDiff, nil
+	}
+
+	remote, err := client.ReadById(ctx, config.GetApi(), id)
+	if err != nil {
+		return configDiff, err
+	}
+
+	changes, err := util.DiffJson(remote, payload, util.ServerManagedJsonKeys...)
+	if err != nil {
+		return configDiff, err
+	}
+
+	configDiff.Id = id
+	configDiff.Remote = remote
+	configDiff.Changes = changes
+
+	if len(changes) == 0 {
+		configDiff.Action = ActionUnchanged
+	} else {
+		configDiff.Action = ActionUpdate
+	}
+	return configDiff, nil
+}
+
+func newClient(environment environment.Environment, rateLimitStrategy string, rateLimit int) (rest.DynatraceClient, error) {
+
+	apiToken, err := environment.GetToken()
+	if err != nil {
+		return nil, err
+	}
+	return rest.NewDynatraceClient(environment.GetEnvironmentUrl(), apiToken, rest.WithRateLimitStrategy(rateLimitStrategy), rest.WithRateLimit(rateLimit))
+}
+
+func sortedEnvironments(environments map[string]environment.Environment) []environment.Environment {
+
+	sorted := make([]environment.Environment, 0, len(environments))
+	for _, environment := range environments {
+		sorted = append(sorted, environment)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].GetId() < sorted[j].GetId()
+	})
+	return sorted
+}
+
+func writeDiff(fs afero.Fs, diffs []EnvironmentDiff, outputFile string) error {
+
+	data, err := json.MarshalIndent(diffs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if outputFile == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+
+	util.Log.Info("Writing diff to %s", outputFile)
+	return afero.WriteFile(fs, outputFile, data, 0664)
+}
+
+func summarize(environmentDiff EnvironmentDiff) string {
+
+	counts := make(map[string]int)
+	for _, configDiff := range environmentDiff.Configs {
+		counts[configDiff.Action]++
+	}
+
+	return fmt.Sprintf("%d to create, %d to update, %d unchanged, %d to delete",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionUnchanged], counts[ActionDelete])
+}
@@ -0,0 +1,106 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diff
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/config"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/delete"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/project"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/golang/mock/gomock"
+	"gotest.tools/assert"
+)
+
+const testPath = "test-resources/diff-test"
+
+var testEnvironment = environment.NewEnvironment("dev", "Dev", "", "https://url/to/dev/environment", "DEV")
+
+func loadTestProjects(t *testing.T) ([]project.Project, []config.Config) {
+	fs := util.CreateTestFileSystem()
+	apis := api.NewApis()
+
+	projects, err := project.LoadProjectsToDeploy(fs, "", apis, testPath)
+	assert.NilError(t, err)
+
+	configsToDelete, err := delete.LoadConfigsToDelete(fs, apis, testPath)
+	assert.NilError(t, err)
+
+	return projects, configsToDelete
+}
+
+func TestCalculateDiff(t *testing.T) {
+	projects, configsToDelete := loadTestProjects(t)
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Zone").Return(true, "zone-id", nil)
+	client.EXPECT().ReadById(gomock.Any(), gomock.Any(), "zone-id").Return([]byte(`{"id": "zone-id", "metadata": {}, "name": "Zone", "rules": []}`), nil)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Profile").Return(false, "", nil)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Overview").Return(true, "dashboard-id", nil)
+	client.EXPECT().ReadById(gomock.Any(), gomock.Any(), "dashboard-id").Return([]byte(`{"id": "dashboard-id", "dashboardMetadata": {"name": "Overview"}, "tiles": [{"name": "tile"}]}`), nil)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Old Overview").Return(true, "old-id", nil)
+	client.EXPECT().ReadById(gomock.Any(), gomock.Any(), "old-id").Return([]byte(`{"id": "old-id"}`), nil)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Gone").Return(false, "", nil)
+
+	environmentDiff, errors := CalculateDiff(context.Background(), client, testEnvironment, projects, configsToDelete, testPath)
+	assert.Equal(t, len(errors), 0)
+	assert.Equal(t, environmentDiff.Environment, "dev")
+
+	actions := make(map[string]ConfigDiff)
+	for _, configDiff := range environmentDiff.Configs {
+		actions[configDiff.ConfigId] = configDiff
+	}
+	assert.Equal(t, len(actions), 4)
+
+	assert.Equal(t, actions["project/management-zone/zone"].Action, ActionUnchanged)
+	assert.Equal(t, actions["project/alerting-profile/profile"].Action, ActionCreate)
+	assert.Assert(t, strings.Contains(string(actions["project/alerting-profile/profile"].Payload), `"zone-id"`))
+
+	overview := actions["project/dashboard/overview"]
+	assert.Equal(t, overview.Action, ActionUpdate)
+	assert.Equal(t, overview.Id, "dashboard-id")
+	assert.DeepEqual(t, overview.Changes, []util.JsonChange{
+		{Path: "tiles[0]", Kind: util.JsonChangeRemoved, Old: map[string]interface{}{"name": "tile"}},
+	})
+
+	assert.Equal(t, actions["dashboard/Old Overview"].Action, ActionDelete)
+	assert.Equal(t, actions["dashboard/Old Overview"].Id, "old-id")
+}
+
+func TestCalculateDiffUsesPendingIdForConfigsToBeCreated(t *testing.T) {
+	projects, _ := loadTestProjects(t)
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, "", nil).Times(3)
+
+	environmentDiff, errors := CalculateDiff(context.Background(), client, testEnvironment, projects, nil, testPath)
+	assert.Equal(t, len(errors), 0)
+
+	for _, configDiff := range environmentDiff.Configs {
+		assert.Equal(t, configDiff.Action, ActionCreate)
+
+		if configDiff.ConfigId == "project/alerting-profile/profile" {
+			assert.Assert(t, strings.Contains(string(configDiff.Payload), PendingId("project/management-zone/zone")))
+		}
+	}
+}
@@ -15,7 +15,10 @@
 package download
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"path/filepath"
 	"strings"
 
@@ -30,9 +33,10 @@ import (
 
 var cont = 0
 
-//GetConfigsFilterByEnvironment filters the enviroments list based on specificEnvironment flag value
-func GetConfigsFilterByEnvironment(workingDir string, fs afero.Fs, environmentsFile string,
-	specificEnvironment string, downloadSpecificAPI string) error {
+//GetConfigsFilterByEnvironment filters the enviroments list based on specificEnvironment flag value. The rate limit
+//strategy and rate limit are passed to the clients, see rest.WithRateLimitStrategy and rest.WithRateLimit.
+func GetConfigsFilterByEnvironment(ctx context.Context, workingDir string, fs afero.Fs, environmentsFile string,
+	specificEnvironment string, downloadSpecificAPI string, rateLimitStrategy string, rateLimit int) error {
 	environments, errors := environment.LoadEnvironmentList(specificEnvironment, environmentsFile, fs)
 	if len(errors) > 0 {
 		for _, err := range errors {
@@ -40,12 +44,13 @@ func GetConfigsFilterByEnvironment(workingDir string, fs afero.Fs, environmentsF
 		}
 		return fmt.Errorf("There were some errors while getting environment files")
 	}
-	return getConfigs(fs, workingDir, environments, downloadSpecificAPI)
+	return getConfigs(ctx, fs, workingDir, environments, downloadSpecificAPI, rateLimitStrategy, rateLimit)
 
 }
 
 //getConfigs Entry point that retrieves the specified configurations from a Dynatrace tenant
-func getConfigs(fs afero.Fs, workingDir string, environments map[string]environment.Environment, downloadSpecificAPI string) error {
+func getConfigs(ctx context.Context, fs afero.Fs, workingDir string, environments map[string]environment.Environment, downloadSpecificAPI string,
+	rateLimitStrategy string, rateLimit int) error {
 	list, err := getAPIList(downloadSpecificAPI)
 	if err != nil {
 		return err
@@ -53,9 +58,9 @@ func getConfigs(fs afero.Fs, workingDir string, environments map[string]environm
 	isError := false
 	for _, environment := range environments {
 		//download configs for each environment
-		err := downloadConfigFromEnvironment(fs, environment, workingDir, list)
+		err := downloadConfigFromEnvironment(ctx, fs, environment, workingDir, list, rateLimitStrategy, rateLimit)
 		if err != nil {
-			util.Log.Error("error while downloading configs for environment %v %v", environment.GetId())
+			util.Log.Error("error while downloading configs for environment %v: %v", environment.GetId(), err)
 			isError = true
 		}
 	}
@@ -96,7 +101,8 @@ func getAPIList(downloadSpecificAPI string) (filterAPIList map[string]api.Api, e
 }
 
 //creates the project and downloads the configs
-func downloadConfigFromEnvironment(fs afero.Fs, environment environment.Environment, basepath string, listApis map[string]api.Api) (err error) {
+func downloadConfigFromEnvironment(ctx context.Context, fs afero.Fs, environment environment.Environment, basepath string, listApis map[string]api.Api,
+	rateLimitStrategy string, rateLimit int) (err error) {
 	projectName := environment.GetId()
 	path := filepath.Join(basepath, projectName)
 
@@ -111,7 +117,7 @@ func downloadConfigFromEnvironment(fs afero.Fs, environment environment.Environm
 		util.Log.Error("error retrieving token for enviroment %v %v", projectName, err)
 		return err
 	}
-	client, err := rest.NewDynatraceClient(environment.GetEnvironmentUrl(), token)
+	client, err := rest.NewDynatraceClient(environment.GetEnvironmentUrl(), token, rest.WithRateLimitStrategy(rateLimitStrategy), rest.WithRateLimit(rateLimit))
 	if err != nil {
 		util.Log.Error("error creating dynatrace client for enviroment %v %v", projectName, err)
 		return err
@@ -120,19 +126,39 @@ func downloadConfigFromEnvironment(fs afero.Fs, environment environment.Environm
 		util.Log.Info(" --- GETTING CONFIGS for %s", api.GetId())
 		jcreator := jsoncreator.NewJSONCreator()
 		ycreator := yamlcreator.NewYamlConfig()
-		errorAPI := createConfigsFromAPI(fs, api, token, path, client, jcreator, ycreator)
+		errorAPI := createConfigsFromAPI(ctx, fs, api, token, path, client, jcreator, ycreator)
 		if errorAPI != nil {
-			util.Log.Error("error getting configs from API %v %v", api.GetId())
+			util.Log.Error("error getting configs from API %v: %v", api.GetId(), errorAPI)
+			if isEnvironmentError(errorAPI) {
+				return errorAPI
+			}
 		}
 	}
 	util.Log.Info("END downloading info %s", projectName)
 	return nil
 }
 
-func createConfigsFromAPI(fs afero.Fs, api api.Api, token string, fullpath string, client rest.DynatraceClient,
+// isEnvironmentError checks whether the error affects all APIs of the environment, so that downloading the other
+// APIs is pointless, e.g. because the environment is not reachable or the token is invalid
+func isEnvironmentError(err error) bool {
+	var transportError rest.TransportError
+	var rateLimitError rest.RateLimitExhaustedError
+	var apiError rest.ApiError
+
+	switch {
+	case errors.As(err, &transportError), errors.As(err, &rateLimitError):
+		return true
+	case errors.As(err, &apiError):
+		return apiError.StatusCode == http.StatusUnauthorized
+	default:
+		return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+	}
+}
+
+func createConfigsFromAPI(ctx context.Context, fs afero.Fs, api api.Api, token string, fullpath string, client rest.DynatraceClient,
 	jcreator jsoncreator.JSONCreator, ycreator yamlcreator.YamlCreator) (err error) {
 	//retrieves all objects for the specific api
-	values, err := client.List(api)
+	values, err := client.List(ctx, api)
 	if err != nil {
 		util.Log.Error("error getting client list from api %v %v", api.GetId(), err)
 		return err
@@ -151,7 +177,7 @@ func createConfigsFromAPI(fs afero.Fs, api api.Api, token string, fullpath strin
 		util.Log.Debug("getting detail %s", val)
 		cont++
 		util.Log.Debug("REQUEST counter %v", cont)
-		name, cleanName, filter, err := jcreator.CreateJSONConfig(fs, client, api, val, subPath)
+		name, cleanName, filter, err := jcreator.CreateJSONConfig(ctx, fs, client, api, val, subPath)
 		if err != nil {
 			util.Log.Error("error creating config api json file: %v", err)
 			continue
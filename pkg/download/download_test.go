@@ -17,6 +17,9 @@
 package download
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"testing"
 
@@ -36,8 +39,9 @@ func TestGetConfigs(t *testing.T) {
 	envs := make(map[string]environment.Environment)
 	fileManager := util.CreateTestFileSystem()
 	envs["e1"] = env
-	err := getConfigs(fileManager, "", envs, "")
-	assert.NilError(t, err)
+	err := getConfigs(context.Background(), fileManager, "", envs, "", "", 0)
+	// the environment doesn't exist, so downloading stops at the first API
+	assert.ErrorContains(t, err, "There were some errors while downloading the environment configs")
 }
 func TestCreateConfigsFromAPI(t *testing.T) {
 	apiMock := api.CreateAPIMockFactory(t)
@@ -48,13 +52,13 @@ func TestCreateConfigsFromAPI(t *testing.T) {
 	list := []api.Value{{Id: "d", Name: "namevalue"}}
 
 	client.EXPECT().
-		List(gomock.Any()).Return(list, nil)
+		List(gomock.Any(), gomock.Any()).Return(list, nil)
 
 	apiMock.EXPECT().
 		GetId().Return("synthetic-monitor").AnyTimes()
 
 	jcreator.EXPECT().
-		CreateJSONConfig(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		CreateJSONConfig(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
 		Return("demo.json", "demo", false, nil)
 
 	ycreator.EXPECT().
@@ -62,7 +66,7 @@ func TestCreateConfigsFromAPI(t *testing.T) {
 		Return(nil)
 	ycreator.EXPECT().AddConfig(gomock.Any(), gomock.Any())
 
-	err := createConfigsFromAPI(fs, apiMock, "123", "/", client, jcreator, ycreator)
+	err := createConfigsFromAPI(context.Background(), fs, apiMock, "123", "/", client, jcreator, ycreator)
 	assert.NilError(t, err, "No errors")
 }
 
@@ -71,7 +75,7 @@ func TestDownloadConfigFromEnvironment(t *testing.T) {
 	env := environment.NewEnvironment("environment1", "test", "", "https://test.live.dynatrace.com", "token")
 
 	fileManager := util.CreateTestFileSystem()
-	err := downloadConfigFromEnvironment(fileManager, env, "", nil)
+	err := downloadConfigFromEnvironment(context.Background(), fileManager, env, "", nil, "", 0)
 	assert.NilError(t, err)
 }
 func TestGetAPIList(t *testing.T) {
@@ -94,3 +98,11 @@ func TestGetAPIList(t *testing.T) {
 	list, err = getAPIList("synthetic-location-test,   extension-test, alerting-profile")
 	assert.ErrorContains(t, err, "There were some errors in the API list provided")
 }
+
+func TestIsEnvironmentError(t *testing.T) {
+	assert.Check(t, isEnvironmentError(rest.TransportError{Method: "GET", Url: "https://test.com", Err: errors.New("connection reset")}))
+	assert.Check(t, isEnvironmentError(fmt.Errorf("failed: %w", rest.ApiError{StatusCode: 401})))
+	assert.Check(t, isEnvironmentError(rest.RateLimitExhaustedError{Url: "https://test.com", Attempts: 6}))
+	assert.Check(t, !isEnvironmentError(fmt.Errorf("failed: %w", rest.ApiError{StatusCode: 400})))
+	assert.Check(t, !isEnvironmentError(errors.New("invalid json")))
+}
@@ -15,6 +15,7 @@
 package jsoncreator
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -30,7 +31,7 @@ import (
 
 //JSONCreator interface allows to mock the methods for unit testing
 type JSONCreator interface {
-	CreateJSONConfig(fs afero.Fs, client rest.DynatraceClient, api api.Api, value api.Value,
+	CreateJSONConfig(ctx context.Context, fs afero.Fs, client rest.DynatraceClient, api api.Api, value api.Value,
 		path string) (name string, cleanName string, filter bool, err error)
 }
 
@@ -44,9 +45,9 @@ func NewJSONCreator() *JsonCreatorImp {
 }
 
 //CreateJSONConfig creates a json file using the specified path and API data
-func (d *JsonCreatorImp) CreateJSONConfig(fs afero.Fs, client rest.DynatraceClient, api api.Api, value api.Value,
+func (d *JsonCreatorImp) CreateJSONConfig(ctx context.Context, fs afero.Fs, client rest.DynatraceClient, api api.Api, value api.Value,
 	path string) (name string, cleanName string, filter bool, err error) {
-	data, filter, err := getDetailFromAPI(client, api, value.Id)
+	data, filter, err := getDetailFromAPI(ctx, client, api, value.Id)
 	if err != nil {
 		util.Log.Error("error getting detail %s from API", api.GetId())
 		return "", "", false, err
@@ -68,12 +69,12 @@ func (d *JsonCreatorImp) CreateJSONConfig(fs afero.Fs, client rest.DynatraceClie
 	return name, cleanName, false, nil
 }
 
-func getDetailFromAPI(client rest.DynatraceClient, api api.Api, name string) (dat map[string]interface{}, filter bool, err error) {
+func getDetailFromAPI(ctx context.Context, client rest.DynatraceClient, api api.Api, name string) (dat map[string]interface{}, filter bool, err error) {
 
 	name = url.QueryEscape(name)
-	resp, err := client.ReadById(api, name)
+	resp, err := client.ReadById(ctx, api, name)
 	if err != nil {
-		util.Log.Error("error getting detail for API %s", api.GetId(), name)
+		util.Log.Error("error getting detail %s for API %s: %v", name, api.GetId(), err)
 		return nil, false, err
 	}
 	err = json.Unmarshal(resp, &dat)
@@ -17,12 +17,14 @@
 package jsoncreator
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/golang/mock/gomock"
 	"gotest.tools/assert"
 )
 
@@ -35,14 +37,14 @@ func TestCreateJsonConfig(t *testing.T) {
 	val := api.Value{Id: "acc3c230-e156-4a11-a5b7-bda1b304e613", Name: "Sockshop Error Profile"}
 	client.
 		EXPECT().
-		ReadById(apiMock, val.Id).
+		ReadById(gomock.Any(), apiMock, val.Id).
 		Return(jsonsample, nil)
 
 	apiMock.EXPECT().GetId().Return("alerting-profile").AnyTimes()
 
 	jcreator := NewJSONCreator()
 
-	name, cleanName, filter, err := jcreator.CreateJSONConfig(fs, client, apiMock, val, "/")
+	name, cleanName, filter, err := jcreator.CreateJSONConfig(context.Background(), fs, client, apiMock, val, "/")
 	assert.NilError(t, err)
 	assert.Equal(t, filter, false)
 	assert.Equal(t, name, "Sockshop Error Profile")
@@ -21,6 +21,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/hook"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
 )
 
@@ -29,6 +30,8 @@ type Environment interface {
 	GetEnvironmentUrl() string
 	GetToken() (string, error)
 	GetGroup() string
+	// GetHooks returns the commands run before and after the deployment to the environment
+	GetHooks() hook.Hooks
 }
 
 type environmentImpl struct {
@@ -37,6 +40,7 @@ type environmentImpl struct {
 	group          string
 	environmentUrl string
 	envTokenName   string
+	hooks          hook.Hooks
 }
 
 func NewEnvironments(maps map[string]map[string]string) (map[string]Environment, []error) {
@@ -90,10 +94,20 @@ func newEnvironment(id string, properties map[string]string) (Environment, error
 		return nil, fmt.Errorf("failed to parse config for environment %s (issues: %s %s %s)", id, nameErr, urlErr, tokenErr)
 	}
 
-	return NewEnvironment(id, environmentName, environmentGroup, environmentUrl, envTokenName), nil
+	hooks := hook.Hooks{
+		PreDeploy:  properties["pre-deploy-hook"],
+		PostDeploy: properties["post-deploy-hook"],
+	}
+
+	return NewEnvironmentWithHooks(id, environmentName, environmentGroup, environmentUrl, envTokenName, hooks), nil
 }
 
 func NewEnvironment(id string, name string, group string, environmentUrl string, envTokenName string) Environment {
+	return NewEnvironmentWithHooks(id, name, group, environmentUrl, envTokenName, hook.Hooks{})
+}
+
+// NewEnvironmentWithHooks creates an environment, which runs the given hooks before and after its deployment
+func NewEnvironmentWithHooks(id string, name string, group string, environmentUrl string, envTokenName string, hooks hook.Hooks) Environment {
 	environmentUrl = strings.TrimSuffix(environmentUrl, "/")
 
 	return &environmentImpl{
@@ -102,6 +116,7 @@ func NewEnvironment(id string, name string, group string, environmentUrl string,
 		group:          group,
 		environmentUrl: environmentUrl,
 		envTokenName:   envTokenName,
+		hooks:          hooks,
 	}
 }
 
@@ -124,3 +139,7 @@ func (s *environmentImpl) GetToken() (string, error) {
 func (s *environmentImpl) GetGroup() string {
 	return s.group
 }
+
+func (s *environmentImpl) GetHooks() hook.Hooks {
+	return s.hooks
+}
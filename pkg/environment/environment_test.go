@@ -79,6 +79,15 @@ development:
     - env-token-name: "DEV"
 `
 
+const testYamlEnvironmentWithHooks = `
+development:
+    - name: "Dev"
+    - env-url: "https://url/to/dev/environment"
+    - env-token-name: "DEV"
+    - pre-deploy-hook: "./pause-alerting.sh"
+    - post-deploy-hook: "./resume-alerting.sh"
+`
+
 var testDevEnvironment = NewEnvironment("development", "Dev", "", "https://url/to/dev/environment", "DEV")
 var testHardeningEnvironment = NewEnvironment("hardening", "Hardening", "", "https://url/to/hardening/environment", "HARDENING")
 var testProductionEnvironment = NewEnvironment("prod-environment", "prod-environment", "production", "https://url/to/production/environment", "PRODUCTION")
@@ -179,6 +188,15 @@ func TestTokenNotAvailableOnGetterCallWithTemplating(t *testing.T) {
 	assert.ErrorContains(t, e, "map has no entry for key \"URL\"")
 }
 
+func TestHooksAreParsed(t *testing.T) {
+
+	e, devEnvironment := setupEnvironment(t, testYamlEnvironmentWithHooks, "development")
+	assert.NilError(t, e)
+
+	assert.Equal(t, "./pause-alerting.sh", devEnvironment.GetHooks().PreDeploy)
+	assert.Equal(t, "./resume-alerting.sh", devEnvironment.GetHooks().PostDeploy)
+}
+
 func TestTrailingSlashTrimmedFromEnvironmentURL(t *testing.T) {
 	envURL := testTrailingSlashEnvironment.GetEnvironmentUrl()
 	last_char := envURL[len(envURL)-1:]
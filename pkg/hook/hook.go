@@ -0,0 +1,134 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/spf13/afero"
+)
+
+const (
+	PreDeploy  = "pre-deploy"
+	PostDeploy = "post-deploy"
+)
+
+// ProjectHooksFile is the name of the file in the root folder of a project, which defines the hooks of the project
+const ProjectHooksFile = "hooks.yaml"
+
+// Hooks are the commands run before and after a deployment. Empty commands are not run.
+type Hooks struct {
+	PreDeploy  string
+	PostDeploy string
+}
+
+// Get returns the command of the given hook (PreDeploy or PostDeploy)
+func (h Hooks) Get(name string) string {
+	if name == PreDeploy {
+		return h.PreDeploy
+	}
+	return h.PostDeploy
+}
+
+// LoadProjectHooks reads the hooks of a project for the given environment from the ProjectHooksFile in the project
+// folder. The hooks section applies to all environments, a hooks.<environment> section overrides it:
+//
+//	hooks:
+//	  - pre-deploy: "./pause-alerting.sh"
+//	hooks.production:
+//	  - post-deploy: "./notify-chat.sh"
+//
+// Projects without hooks file don't have any hooks.
+func LoadProjectHooks(fs afero.Fs, projectFolder string, environmentId string) (hooks Hooks, err error) {
+
+	file := filepath.Join(projectFolder, ProjectHooksFile)
+
+	data, err := afero.ReadFile(fs, file)
+	if os.IsNotExist(err) {
+		return hooks, nil
+	}
+	if err != nil {
+		return hooks, err
+	}
+
+	err, sections := util.UnmarshalYaml(string(data), file)
+	if err != nil {
+		return hooks, err
+	}
+
+	for section := range sections {
+		if section != "hooks" && !strings.HasPrefix(section, "hooks.") {
+			return hooks, fmt.Errorf("unknown section %s in %s", section, file)
+		}
+	}
+
+	for _, section := range []string{"hooks", "hooks." + environmentId} {
+		for name, command := range sections[section] {
+			switch name {
+			case PreDeploy:
+				hooks.PreDeploy = command
+			case PostDeploy:
+				hooks.PostDeploy = command
+			default:
+				return hooks, fmt.Errorf("unknown hook %s in %s, supported hooks are %s and %s", name, file, PreDeploy, PostDeploy)
+			}
+		}
+	}
+	return hooks, nil
+}
+
+// Run executes the command using the shell of the operating system in the given folder (the current folder, if
+// empty). The input is passed as json on stdin. The combined stdout and stderr of the command is returned, split
+// into lines. It is an error if the command exits with a non-zero exit code.
+func Run(ctx context.Context, command string, dir string, input interface{}) (output []string, err error) {
+
+	stdin, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := shellCommand(ctx, command)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(stdin)
+
+	out, err := cmd.CombinedOutput()
+
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\r\n"), "\n") {
+		if line != "" {
+			output = append(output, strings.TrimRight(line, "\r"))
+		}
+	}
+
+	if err != nil {
+		return output, fmt.Errorf("`%s` failed: %w", command, err)
+	}
+	return output, nil
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,91 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hook
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/afero"
+	"gotest.tools/assert"
+)
+
+const testHooksYaml = `
+hooks:
+  - pre-deploy: "./pause-alerting.sh"
+  - post-deploy: "./resume-alerting.sh"
+hooks.production:
+  - post-deploy: "./notify-chat.sh"
+`
+
+func TestLoadProjectHooksAppliesEnvironmentOverride(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "project/hooks.yaml", []byte(testHooksYaml), 0664)
+
+	hooks, err := LoadProjectHooks(fs, "project", "development")
+	assert.NilError(t, err)
+	assert.Equal(t, "./pause-alerting.sh", hooks.Get(PreDeploy))
+	assert.Equal(t, "./resume-alerting.sh", hooks.Get(PostDeploy))
+
+	hooks, err = LoadProjectHooks(fs, "project", "production")
+	assert.NilError(t, err)
+	assert.Equal(t, "./pause-alerting.sh", hooks.Get(PreDeploy))
+	assert.Equal(t, "./notify-chat.sh", hooks.Get(PostDeploy))
+}
+
+func TestLoadProjectHooksWithoutFile(t *testing.T) {
+	hooks, err := LoadProjectHooks(afero.NewMemMapFs(), "project", "development")
+	assert.NilError(t, err)
+	assert.Equal(t, Hooks{}, hooks)
+}
+
+func TestLoadProjectHooksFailsOnUnknownHook(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "project/hooks.yaml", []byte("hooks:\n  - pre-delete: \"./cleanup.sh\"\n"), 0664)
+
+	_, err := LoadProjectHooks(fs, "project", "development")
+	assert.ErrorContains(t, err, "unknown hook pre-delete")
+}
+
+func TestLoadProjectHooksFailsOnUnknownSection(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "project/hooks.yaml", []byte("hook:\n  - pre-deploy: \"./pause-alerting.sh\"\n"), 0664)
+
+	_, err := LoadProjectHooks(fs, "project", "development")
+	assert.ErrorContains(t, err, "unknown section hook")
+}
+
+func TestRunPassesInputOnStdin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a posix shell")
+	}
+
+	output, err := Run(context.Background(), "cat", "", map[string]string{"environment": "dev"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []string{`{"environment":"dev"}`}, output)
+}
+
+func TestRunFailsOnNonZeroExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a posix shell")
+	}
+
+	output, err := Run(context.Background(), "echo paused; exit 3", "", nil)
+	assert.ErrorContains(t, err, "`echo paused; exit 3` failed: exit status 3")
+	assert.DeepEqual(t, []string{"paused"}, output)
+}
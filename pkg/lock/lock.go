@@ -0,0 +1,223 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/google/uuid"
+	"github.com/spf13/afero"
+)
+
+// Locker grants exclusive access to environments, so that concurrent deployments (e.g. of two CI pipelines) to
+// the same environment don't interfere with each other
+type Locker interface {
+
+	// Acquire blocks until the lock of the environment has been acquired. It fails if the lock is still held by
+	// someone else after the timeout, or if the context is done.
+	Acquire(ctx context.Context, environmentId string, timeout time.Duration) (Lock, error)
+
+	// ForceUnlock releases the lock of the environment, regardless of who holds it. It can be used to remove the
+	// lock of a deployment which has been killed. Environments which are not locked are ignored.
+	ForceUnlock(environmentId string) error
+}
+
+// Lock is an acquired lock of an environment
+type Lock interface {
+
+	// Release gives up the lock. If the lock has been removed by ForceUnlock in the meantime, nothing happens.
+	Release() error
+}
+
+// Holder describes who holds the lock of an environment. It is the content of a lock file.
+type Holder struct {
+	Id       string    `json:"id"`
+	Host     string    `json:"host"`
+	Pid      int       `json:"pid"`
+	Acquired time.Time `json:"acquired"`
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("%s (pid %d) since %s", h.Host, h.Pid, h.Acquired.Format(time.RFC3339))
+}
+
+// DefaultDir returns the folder lock files are written to by default: <workingDir>/.monaco/locks
+func DefaultDir(workingDir string) string {
+	return filepath.Join(workingDir, ".monaco", "locks")
+}
+
+// fileLocker locks an environment by creating the file <dir>/<environment>.lock. The file is created exclusively,
+// so the lock works across processes and, if dir is on a shared file system, across machines.
+type fileLocker struct {
+	fs           afero.Fs
+	dir          string
+	pollInterval time.Duration
+}
+
+type fileLock struct {
+	fs     afero.Fs
+	file   string
+	holder Holder
+}
+
+// NewFileLocker creates a Locker which writes its lock files to the given folder
+func NewFileLocker(fs afero.Fs, dir string) Locker {
+	return &fileLocker{
+		fs:           fs,
+		dir:          dir,
+		pollInterval: time.Second,
+	}
+}
+
+func (l *fileLocker) file(environmentId string) string {
+	return filepath.Join(l.dir, environmentId+".lock")
+}
+
+func (l *fileLocker) Acquire(ctx context.Context, environmentId string, timeout time.Duration) (Lock, error) {
+
+	file := l.file(environmentId)
+	deadline := time.Now().Add(timeout)
+
+	host, _ := os.Hostname()
+	holder := Holder{
+		Id:   uuid.NewString(),
+		Host: host,
+		Pid:  os.Getpid(),
+	}
+
+	err := l.fs.MkdirAll(l.dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+
+	for waiting := false; ; waiting = true {
+
+		holder.Acquired = time.Now()
+		acquired, err := l.tryCreate(file, holder)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock environment %s: %w", environmentId, err)
+		}
+		if acquired {
+			return &fileLock{fs: l.fs, file: file, holder: holder}, nil
+		}
+
+		current := describeHolder(l.fs, file)
+
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("environment %s is locked by %s, remove the lock with --force-unlock "+
+				"if that deployment is not running anymore", environmentId, current)
+		}
+		if !waiting {
+			util.Log.Info("Environment %s is locked by %s, waiting up to %s...", environmentId, current, timeout)
+		}
+
+		err = util.NewTimelineProvider().Sleep(ctx, l.pollInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// tryCreate creates the lock file, unless it already exists
+func (l *fileLocker) tryCreate(file string, holder Holder) (bool, error) {
+
+	content, err := json.MarshalIndent(holder, "", "  ")
+	if err != nil {
+		return false, err
+	}
+
+	f, err := l.fs.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0664)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err == nil, err
+}
+
+func (l *fileLocker) ForceUnlock(environmentId string) error {
+
+	file := l.file(environmentId)
+
+	holder, err := readHolder(l.fs, file)
+	if os.IsNotExist(err) {
+		util.Log.Info("Environment %s is not locked", environmentId)
+		return nil
+	}
+	if err != nil {
+		util.Log.Warn("Lock file %s is not valid: %s", file, err)
+	} else {
+		util.Log.Info("Removing lock of environment %s held by %s", environmentId, holder)
+	}
+
+	err = l.fs.Remove(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (l *fileLock) Release() error {
+
+	current, err := readHolder(l.fs, l.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// the lock has been forcefully removed and acquired by someone else in the meantime
+	if current.Id != l.holder.Id {
+		return nil
+	}
+	return l.fs.Remove(l.file)
+}
+
+// describeHolder returns who holds the lock of the given lock file, for logging purposes
+func describeHolder(fs afero.Fs, file string) string {
+	holder, err := readHolder(fs, file)
+	if err != nil {
+		// e.g. the lock has just been released, or its holder has not written the file yet
+		return "an unknown holder"
+	}
+	return holder.String()
+}
+
+func readHolder(fs afero.Fs, file string) (holder Holder, err error) {
+
+	content, err := afero.ReadFile(fs, file)
+	if err != nil {
+		return holder, err
+	}
+
+	err = json.Unmarshal(content, &holder)
+	if err != nil {
+		return holder, fmt.Errorf("lock file %s is not valid: %s", file, err)
+	}
+	return holder, nil
+}
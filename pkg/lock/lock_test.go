@@ -0,0 +1,119 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"gotest.tools/assert"
+)
+
+func newTestLocker(fs afero.Fs) *fileLocker {
+	return &fileLocker{
+		fs:           fs,
+		dir:          DefaultDir("projects"),
+		pollInterval: 10 * time.Millisecond,
+	}
+}
+
+func TestAcquireCreatesLockFileAndReleaseRemovesIt(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	locker := newTestLocker(fs)
+
+	lock, err := locker.Acquire(context.Background(), "dev", 0)
+	assert.NilError(t, err)
+
+	holder, err := readHolder(fs, locker.file("dev"))
+	assert.NilError(t, err)
+	assert.Assert(t, holder.Id != "")
+
+	assert.NilError(t, lock.Release())
+
+	exists, _ := afero.Exists(fs, locker.file("dev"))
+	assert.Assert(t, !exists)
+}
+
+func TestAcquireFailsAfterTimeoutIfEnvironmentIsLocked(t *testing.T) {
+	locker := newTestLocker(afero.NewMemMapFs())
+
+	_, err := locker.Acquire(context.Background(), "dev", 0)
+	assert.NilError(t, err)
+
+	_, err = locker.Acquire(context.Background(), "dev", 50*time.Millisecond)
+	assert.ErrorContains(t, err, "environment dev is locked by")
+
+	// other environments are not affected
+	_, err = locker.Acquire(context.Background(), "prod", 0)
+	assert.NilError(t, err)
+}
+
+func TestAcquireWaitsUntilLockIsReleased(t *testing.T) {
+	locker := newTestLocker(afero.NewMemMapFs())
+
+	lock, err := locker.Acquire(context.Background(), "dev", 0)
+	assert.NilError(t, err)
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		_ = lock.Release()
+	}()
+
+	_, err = locker.Acquire(context.Background(), "dev", 10*time.Second)
+	assert.NilError(t, err)
+}
+
+func TestAcquireStopsWaitingIfContextIsDone(t *testing.T) {
+	locker := newTestLocker(afero.NewMemMapFs())
+
+	_, err := locker.Acquire(context.Background(), "dev", 0)
+	assert.NilError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = locker.Acquire(ctx, "dev", 10*time.Second)
+	assert.ErrorContains(t, err, context.Canceled.Error())
+}
+
+func TestForceUnlockRemovesLockOfOtherHolder(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	locker := newTestLocker(fs)
+
+	stale, err := locker.Acquire(context.Background(), "dev", 0)
+	assert.NilError(t, err)
+
+	assert.NilError(t, locker.ForceUnlock("dev"))
+
+	lock, err := locker.Acquire(context.Background(), "dev", 0)
+	assert.NilError(t, err)
+
+	// releasing the removed lock must not release the new one
+	assert.NilError(t, stale.Release())
+	exists, _ := afero.Exists(fs, locker.file("dev"))
+	assert.Assert(t, exists)
+
+	assert.NilError(t, lock.Release())
+}
+
+func TestForceUnlockIgnoresUnlockedEnvironment(t *testing.T) {
+	locker := newTestLocker(afero.NewMemMapFs())
+
+	assert.NilError(t, locker.ForceUnlock("dev"))
+}
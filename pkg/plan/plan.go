@@ -0,0 +1,378 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/diff"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/environment"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/lock"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/spf13/afero"
+)
+
+// Plan contains all rendered payloads of a deployment, so that the deployment can be reviewed before it is
+// applied exactly as planned
+type Plan struct {
+	Environments []EnvironmentPlan `json:"environments"`
+}
+
+// EnvironmentPlan contains the planned operations for a single environment in the order they have to be applied
+type EnvironmentPlan struct {
+	Environment    string  `json:"environment"`
+	EnvironmentUrl string  `json:"environmentUrl"`
+	Entries        []Entry `json:"entries"`
+}
+
+// Entry is a single planned operation. The operation is one of the diff actions (create, update, unchanged or
+// delete). RemoteHash is the hash of the live object at planning time, it is empty if the object did not exist.
+type Entry struct {
+	ConfigId   string          `json:"config"`
+	Api        string          `json:"api"`
+	Name       string          `json:"name"`
+	Id         string          `json:"id,omitempty"`
+	Operation  string          `json:"operation"`
+	RemoteHash string          `json:"remoteHash"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+}
+
+// CreatePlan calculates the changes a deployment would apply to each environment and writes them as plan to planFile
+func CreatePlan(ctx context.Context, workingDir string, fs afero.Fs, environmentsFile string, specificEnvironment string, proj string,
+	planFile string, rateLimitStrategy string, rateLimit int) error {
+
+	diffs, diffErrors, err := diff.CalculateDiffs(ctx, workingDir, fs, environmentsFile, specificEnvironment, proj, rateLimitStrategy, rateLimit)
+	if err != nil {
+		return err
+	}
+
+	if len(diffErrors) > 0 {
+		for environment, errors := range diffErrors {
+			util.Log.Error("Planning of %s failed with %d error(s):\n", environment, len(errors))
+			util.PrintErrors(errors)
+		}
+		return fmt.Errorf("Errors during planning! Check log!")
+	}
+
+	plan := Plan{
+		Environments: make([]EnvironmentPlan, 0, len(diffs)),
+	}
+	for _, environmentDiff := range diffs {
+		environmentPlan, err := NewEnvironmentPlan(environmentDiff)
+		if err != nil {
+			return err
+		}
+		plan.Environments = append(plan.Environments, environmentPlan)
+	}
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = afero.WriteFile(fs, planFile, data, 0664)
+	if err != nil {
+		return err
+	}
+
+	util.Log.Info("Plan written to %s", planFile)
+	return nil
+}
+
+// NewEnvironmentPlan converts the diff of an environment into a plan
+func NewEnvironmentPlan(environmentDiff diff.EnvironmentDiff) (EnvironmentPlan, error) {
+
+	environmentPlan := EnvironmentPlan{
+		Environment:    environmentDiff.Environment,
+		EnvironmentUrl: environmentDiff.EnvironmentUrl,
+		Entries:        make([]Entry, 0, len(environmentDiff.Configs)),
+	}
+
+	for _, configDiff := range environmentDiff.Configs {
+		remoteHash, err := hashRemote(configDiff.Remote)
+		if err != nil {
+			return environmentPlan, fmt.Errorf("failed to hash live object of %s: %s", configDiff.ConfigId, err)
+		}
+
+		environmentPlan.Entries = append(environmentPlan.Entries, Entry{
+			ConfigId:   configDiff.ConfigId,
+			Api:        configDiff.Api,
+			Name:       configDiff.Name,
+			Id:         configDiff.Id,
+			Operation:  configDiff.Action,
+			RemoteHash: remoteHash,
+			Payload:    configDiff.Payload,
+		})
+	}
+	return environmentPlan, nil
+}
+
+// ApplyOptions configures how a plan is applied
+type ApplyOptions struct {
+	// RateLimitStrategy is passed to the clients, see rest.WithRateLimitStrategy
+	RateLimitStrategy string
+	// RateLimit is passed to the clients, see rest.WithRateLimit
+	RateLimit int
+	// Locker grants exclusive access to each environment from the verification until the plan has been applied,
+	// so that deployments to the environment can't interfere. If nil, lock files are written to LockDir.
+	Locker lock.Locker
+	// LockDir is the folder of the lock files, it should be the one of the deployments to the environments.
+	// Defaults to lock.DefaultDir of the current directory.
+	LockDir string
+	// LockTimeout is how long to wait for the lock of an environment held by another deployment
+	LockTimeout time.Duration
+}
+
+// Apply executes the plan stored in planFile. Before anything is changed, all live objects are compared with
+// the state at planning time. If any of them changed, the plan is not applied at all. The environments are locked
+// from the verification until the plan has been executed.
+func Apply(ctx context.Context, fs afero.Fs, environmentsFile string, planFile string, options ApplyOptions) error {
+
+	plan, err := loadPlan(fs, planFile)
+	if err != nil {
+		return err
+	}
+
+	environments, errors := environment.LoadEnvironmentList("", environmentsFile, fs)
+	if len(errors) > 0 {
+		util.PrintErrors(errors)
+		return fmt.Errorf("There were some errors while getting environment files")
+	}
+
+	locker := options.Locker
+	if locker == nil {
+		lockDir := options.LockDir
+		if lockDir == "" {
+			lockDir = lock.DefaultDir(".")
+		}
+		locker = lock.NewFileLocker(fs, lockDir)
+	}
+
+	var locks = make(map[string]lock.Lock)
+	defer func() {
+		for environmentId, environmentLock := range locks {
+			if err := environmentLock.Release(); err != nil {
+				util.Log.Warn("Failed to release lock of %s: %s", environmentId, err)
+			}
+		}
+	}()
+
+	apis := api.NewApis()
+	clients := make(map[string]rest.DynatraceClient)
+	var verificationErrors = make(map[string][]error)
+
+	for _, environmentPlan := range plan.Environments {
+		util.Log.Info("Verifying plan for environment %s...", environmentPlan.Environment)
+
+		environmentLock, err := locker.Acquire(ctx, environmentPlan.Environment, options.LockTimeout)
+		if err != nil {
+			verificationErrors[environmentPlan.Environment] = []error{err}
+			continue
+		}
+		locks[environmentPlan.Environment] = environmentLock
+
+		client, err := newClient(environments, environmentPlan, options.RateLimitStrategy, options.RateLimit)
+		if err != nil {
+			verificationErrors[environmentPlan.Environment] = []error{err}
+			continue
+		}
+		clients[environmentPlan.Environment] = client
+
+		errors := Verify(ctx, client, apis, environmentPlan)
+		if len(errors) > 0 {
+			verificationErrors[environmentPlan.Environment] = errors
+		}
+	}
+
+	if len(verificationErrors) > 0 {
+		for environment, errors := range verificationErrors {
+			util.Log.Error("Plan for %s is outdated or invalid. Found %d error(s):\n", environment, len(errors))
+			util.PrintErrors(errors)
+		}
+		return fmt.Errorf("Plan can't be applied! Check log!")
+	}
+
+	var deploymentErrors = make(map[string][]error)
+
+	for _, environmentPlan := range plan.Environments {
+		util.Log.Info("Applying plan for environment %s...", environmentPlan.Environment)
+
+		errors := Execute(ctx, clients[environmentPlan.Environment], apis, environmentPlan)
+		if len(errors) > 0 {
+			deploymentErrors[environmentPlan.Environment] = errors
+		}
+	}
+
+	util.Log.Info("Deployment summary:")
+	for environment, errors := range deploymentErrors {
+		util.Log.Error("Deployment to %s failed with error!\n", environment)
+		util.PrintErrors(errors)
+	}
+
+	if len(deploymentErrors) > 0 {
+		return fmt.Errorf("Errors during deployment! Check log!")
+	}
+
+	util.Log.Info("Deployment finished without errors")
+	return nil
+}
+
+// Verify checks that the live objects of the environment still match the state at planning time
+func Verify(ctx context.Context, client rest.DynatraceClient, apis map[string]api.Api, environmentPlan EnvironmentPlan) (errors []error) {
+
+	for _, entry := range environmentPlan.Entries {
+
+		theApi, ok := apis[entry.Api]
+		if !ok {
+			errors = append(errors, fmt.Errorf("config %s has unknown api %s", entry.ConfigId, entry.Api))
+			continue
+		}
+
+		exists, id, err := client.ExistsByName(ctx, theApi, entry.Name)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+
+		var remote []byte
+		if exists {
+			remote, err = client.ReadById(ctx, theApi, id)
+			if err != nil {
+				errors = append(errors, err)
+				continue
+			}
+		}
+
+		remoteHash, err := hashRemote(remote)
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+
+		if remoteHash != entry.RemoteHash || id != entry.Id {
+			errors = append(errors, fmt.Errorf("%s '%s' (config %s) changed since planning", entry.Api, entry.Name, entry.ConfigId))
+		}
+	}
+	return errors
+}
+
+// Execute applies the planned operations of an environment in order. Placeholders for ids of objects created
+// by the plan are replaced with the actual ids, once they are known.
+func Execute(ctx context.Context, client rest.DynatraceClient, apis map[string]api.Api, environmentPlan EnvironmentPlan) (errors []error) {
+
+	pendingIds := make(map[string]string)
+
+	for _, entry := range environmentPlan.Entries {
+
+		theApi, ok := apis[entry.Api]
+		if !ok {
+			return append(errors, fmt.Errorf("config %s has unknown api %s", entry.ConfigId, entry.Api))
+		}
+
+		switch entry.Operation {
+		case diff.ActionCreate, diff.ActionUpdate:
+			util.Log.Debug("\tApplying %s of config %s", entry.Operation, entry.ConfigId)
+
+			payload := string(entry.Payload)
+			for placeholder, id := range pendingIds {
+				payload = strings.ReplaceAll(payload, placeholder, id)
+			}
+
+			entity, err := client.UpsertByName(ctx, theApi, entry.Name, []byte(payload))
+			if err != nil {
+				return append(errors, fmt.Errorf("%s, responsible config: %s", err.Error(), entry.ConfigId))
+			}
+			pendingIds[diff.PendingId(entry.ConfigId)] = entity.Id
+
+		case diff.ActionDelete:
+			util.Log.Debug("\tDeleting %s (%s)", entry.Name, entry.Api)
+
+			err := client.DeleteByName(ctx, theApi, entry.Name)
+			if err != nil {
+				return append(errors, err)
+			}
+
+		case diff.ActionUnchanged:
+			util.Log.Debug("\tConfig %s is unchanged", entry.ConfigId)
+
+		default:
+			return append(errors, fmt.Errorf("config %s has unknown operation %s", entry.ConfigId, entry.Operation))
+		}
+	}
+	return errors
+}
+
+func loadPlan(fs afero.Fs, planFile string) (plan Plan, err error) {
+
+	data, err := afero.ReadFile(fs, planFile)
+	if err != nil {
+		return plan, err
+	}
+
+	err = json.Unmarshal(data, &plan)
+	if err != nil {
+		return plan, fmt.Errorf("plan %s is not valid: %s", planFile, err)
+	}
+	return plan, nil
+}
+
+func newClient(environments map[string]environment.Environment, environmentPlan EnvironmentPlan, rateLimitStrategy string, rateLimit int) (rest.DynatraceClient, error) {
+
+	environment, ok := environments[environmentPlan.Environment]
+	if !ok {
+		return nil, fmt.Errorf("environment %s of plan not found in environments file", environmentPlan.Environment)
+	}
+
+	if environment.GetEnvironmentUrl() != environmentPlan.EnvironmentUrl {
+		return nil, fmt.Errorf("environment %s has url %s, but the plan was created for %s",
+			environment.GetId(), environment.GetEnvironmentUrl(), environmentPlan.EnvironmentUrl)
+	}
+
+	apiToken, err := environment.GetToken()
+	if err != nil {
+		return nil, err
+	}
+	return rest.NewDynatraceClient(environment.GetEnvironmentUrl(), apiToken, rest.WithRateLimitStrategy(rateLimitStrategy), rest.WithRateLimit(rateLimit))
+}
+
+// hashRemote hashes the normalized json of a live object, so that formatting and key order don't matter.
+// An empty hash is returned for objects which don't exist.
+func hashRemote(remote []byte) (string, error) {
+
+	if len(remote) == 0 {
+		return "", nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(remote, &value); err != nil {
+		return "", err
+	}
+
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(normalized)
+	return hex.EncodeToString(hash[:]), nil
+}
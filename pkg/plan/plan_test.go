@@ -0,0 +1,172 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/diff"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/lock"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/golang/mock/gomock"
+	"github.com/spf13/afero"
+	"gotest.tools/assert"
+)
+
+const remoteZone = `{"id": "zone-id", "name": "Zone"}`
+
+func testEnvironmentPlan(t *testing.T) EnvironmentPlan {
+	environmentPlan, err := NewEnvironmentPlan(diff.EnvironmentDiff{
+		Environment:    "dev",
+		EnvironmentUrl: "https://url/to/dev/environment",
+		Configs: []diff.ConfigDiff{
+			{
+				ConfigId: "project/management-zone/zone",
+				Api:      "management-zone",
+				Name:     "Zone",
+				Id:       "zone-id",
+				Action:   diff.ActionUpdate,
+				Payload:  []byte(`{"name": "Zone", "rules": []}`),
+				Remote:   []byte(remoteZone),
+			},
+			{
+				ConfigId: "project/alerting-profile/profile",
+				Api:      "alerting-profile",
+				Name:     "Profile",
+				Action:   diff.ActionCreate,
+				Payload:  []byte(`{"displayName": "Profile"}`),
+			},
+			{
+				ConfigId: "project/dashboard/overview",
+				Api:      "dashboard",
+				Name:     "Overview",
+				Action:   diff.ActionCreate,
+				Payload:  []byte(`{"profile": "` + diff.PendingId("project/alerting-profile/profile") + `"}`),
+			},
+		},
+	})
+	assert.NilError(t, err)
+	return environmentPlan
+}
+
+func TestNewEnvironmentPlanHashesRemoteState(t *testing.T) {
+	environmentPlan := testEnvironmentPlan(t)
+
+	assert.Equal(t, len(environmentPlan.Entries), 3)
+	assert.Equal(t, environmentPlan.Entries[0].Operation, diff.ActionUpdate)
+	assert.Assert(t, environmentPlan.Entries[0].RemoteHash != "")
+	assert.Equal(t, environmentPlan.Entries[1].RemoteHash, "")
+
+	// formatting and key order must not influence the hash
+	hash, err := hashRemote([]byte(`{
+		"name": "Zone",
+		"id": "zone-id"
+	}`))
+	assert.NilError(t, err)
+	assert.Equal(t, environmentPlan.Entries[0].RemoteHash, hash)
+}
+
+func TestVerifySucceedsOnUnchangedRemoteState(t *testing.T) {
+	environmentPlan := testEnvironmentPlan(t)
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Zone").Return(true, "zone-id", nil)
+	client.EXPECT().ReadById(gomock.Any(), gomock.Any(), "zone-id").Return([]byte(remoteZone), nil)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Profile").Return(false, "", nil)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Overview").Return(false, "", nil)
+
+	errors := Verify(context.Background(), client, api.NewApis(), environmentPlan)
+	assert.Equal(t, len(errors), 0)
+}
+
+func TestVerifyFailsOnChangedRemoteState(t *testing.T) {
+	environmentPlan := testEnvironmentPlan(t)
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Zone").Return(true, "zone-id", nil)
+	client.EXPECT().ReadById(gomock.Any(), gomock.Any(), "zone-id").Return([]byte(`{"id": "zone-id", "name": "Zone", "rules": [{}]}`), nil)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Profile").Return(false, "", nil)
+	client.EXPECT().ExistsByName(gomock.Any(), gomock.Any(), "Overview").Return(true, "dashboard-id", nil)
+	client.EXPECT().ReadById(gomock.Any(), gomock.Any(), "dashboard-id").Return([]byte(`{}`), nil)
+
+	errors := Verify(context.Background(), client, api.NewApis(), environmentPlan)
+	assert.Equal(t, len(errors), 2)
+	assert.ErrorContains(t, errors[0], "management-zone 'Zone' (config project/management-zone/zone) changed since planning")
+	assert.ErrorContains(t, errors[1], "dashboard 'Overview' (config project/dashboard/overview) changed since planning")
+}
+
+func TestExecuteReplacesPendingIds(t *testing.T) {
+	environmentPlan := testEnvironmentPlan(t)
+
+	client := rest.CreateDynatraceClientMockFactory(t)
+	client.EXPECT().UpsertByName(gomock.Any(), gomock.Any(), "Zone", []byte(`{"name": "Zone", "rules": []}`)).
+		Return(api.DynatraceEntity{Id: "zone-id", Name: "Zone"}, nil)
+	client.EXPECT().UpsertByName(gomock.Any(), gomock.Any(), "Profile", []byte(`{"displayName": "Profile"}`)).
+		Return(api.DynatraceEntity{Id: "profile-id", Name: "Profile"}, nil)
+	client.EXPECT().UpsertByName(gomock.Any(), gomock.Any(), "Overview", []byte(`{"profile": "profile-id"}`)).
+		Return(api.DynatraceEntity{Id: "dashboard-id", Name: "Overview"}, nil)
+
+	errors := Execute(context.Background(), client, api.NewApis(), environmentPlan)
+	assert.Equal(t, len(errors), 0)
+}
+
+func TestApplyFailsIfEnvironmentIsLocked(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writeApplyTestFiles(t, fs)
+
+	locker := lock.NewFileLocker(fs, "locks")
+	environmentLock, err := locker.Acquire(context.Background(), "dev", 0)
+	assert.NilError(t, err)
+
+	err = Apply(context.Background(), fs, "environments.yaml", "plan.json", ApplyOptions{Locker: locker})
+	assert.ErrorContains(t, err, "Plan can't be applied")
+
+	// the lock of the other deployment must not be touched
+	_, err = locker.Acquire(context.Background(), "dev", 0)
+	assert.ErrorContains(t, err, "environment dev is locked")
+	assert.NilError(t, environmentLock.Release())
+}
+
+func TestApplyReleasesLocks(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	writeApplyTestFiles(t, fs)
+
+	locker := lock.NewFileLocker(fs, "locks")
+
+	// the token is missing, so applying fails after the lock has been acquired
+	err := Apply(context.Background(), fs, "environments.yaml", "plan.json", ApplyOptions{Locker: locker})
+	assert.ErrorContains(t, err, "Plan can't be applied")
+
+	_, err = locker.Acquire(context.Background(), "dev", 0)
+	assert.NilError(t, err)
+}
+
+func writeApplyTestFiles(t *testing.T, fs afero.Fs) {
+	environments := `dev:
+    - name: "Dev"
+    - env-url: "https://url/to/dev/environment"
+    - env-token-name: "APPLY_TEST_MISSING_TOKEN"
+`
+	assert.NilError(t, afero.WriteFile(fs, "environments.yaml", []byte(environments), 0644))
+
+	data, err := json.Marshal(Plan{Environments: []EnvironmentPlan{testEnvironmentPlan(t)}})
+	assert.NilError(t, err)
+	assert.NilError(t, afero.WriteFile(fs, "plan.json", data, 0644))
+}
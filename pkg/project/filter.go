@@ -0,0 +1,206 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package project
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/config"
+)
+
+// filteredProject is a project which only contains some of the configs of the underlying project
+type filteredProject struct {
+	Project
+	configs []config.Config
+	kept    map[string]bool
+}
+
+// FilterConfigs returns the projects containing only the configs for which keep returns true. Projects without
+// any remaining config are omitted. The order of projects and configs is preserved.
+func FilterConfigs(projects []Project, keep func(config config.Config) bool) []Project {
+
+	filtered := make([]Project, 0, len(projects))
+
+	for _, project := range projects {
+		filteredProject := &filteredProject{
+			Project: project,
+			configs: make([]config.Config, 0),
+			kept:    make(map[string]bool),
+		}
+
+		for _, config := range project.GetConfigs() {
+			if keep(config) {
+				filteredProject.configs = append(filteredProject.configs, config)
+				filteredProject.kept[config.GetFullQualifiedId()] = true
+			}
+		}
+
+		if len(filteredProject.configs) > 0 {
+			filtered = append(filtered, filteredProject)
+		}
+	}
+	return filtered
+}
+
+func (p *filteredProject) GetConfigs() []config.Config {
+	return p.configs
+}
+
+func (p *filteredProject) GetConfig(id string) (config.Config, error) {
+	if !p.kept[id] {
+		return nil, fmt.Errorf("config with id %s not found", id)
+	}
+	return p.Project.GetConfig(id)
+}
+
+func (p *filteredProject) GetConfigDependencies(config config.Config) []string {
+	var dependencies []string
+	for _, dependency := range p.Project.GetConfigDependencies(config) {
+		if p.kept[dependency] {
+			dependencies = append(dependencies, dependency)
+		}
+	}
+	return dependencies
+}
+
+func (p *filteredProject) HasDependencyOn(project Project) bool {
+	for _, myConfig := range p.configs {
+		for _, otherConfig := range project.GetConfigs() {
+			if myConfig.HasDependencyOn(otherConfig) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SelectConfigs returns the full qualified ids of all configs matching one of the given patterns, together with all
+// configs they transitively depend on, even if those are part of other projects.
+// Patterns are matched against the id of a config relative to the projects root folder, e.g. infra/dashboard/overview.
+// They can contain wildcards as supported by path.Match, e.g. infra/dashboard/*. Each pattern has to match at least
+// one config.
+func SelectConfigs(projects []Project, patterns []string, projectsRootFolder string) (map[string]bool, error) {
+
+	allConfigs := allConfigsOf(projects)
+
+	selected := make(map[string]bool)
+	var pending []config.Config
+
+	for _, pattern := range patterns {
+		matched := false
+
+		for _, config := range allConfigs {
+			relativeId := filepath.ToSlash(strings.TrimPrefix(config.GetFullQualifiedId(), projectsRootFolder+string(filepath.Separator)))
+
+			matches, err := path.Match(pattern, relativeId)
+			if err != nil {
+				return nil, fmt.Errorf("config pattern %s is not valid: %s", pattern, err)
+			}
+
+			if matches {
+				matched = true
+				if !selected[config.GetFullQualifiedId()] {
+					selected[config.GetFullQualifiedId()] = true
+					pending = append(pending, config)
+				}
+			}
+		}
+
+		if !matched {
+			return nil, fmt.Errorf("no config matches %s", pattern)
+		}
+	}
+
+	for len(pending) > 0 {
+		config := pending[0]
+		pending = pending[1:]
+
+		for _, other := range allConfigs {
+			if !selected[other.GetFullQualifiedId()] && config.HasDependencyOn(other) {
+				selected[other.GetFullQualifiedId()] = true
+				pending = append(pending, other)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// SelectApis returns the full qualified ids of all configs whose API is contained in apis (all APIs if empty) and
+// not contained in excludedApis. Use CheckExcludedDependencies to make sure no selected config depends on an
+// excluded one.
+func SelectApis(projects []Project, apis []string, excludedApis []string) (map[string]bool, error) {
+
+	included, err := toApiSet(apis)
+	if err != nil {
+		return nil, err
+	}
+	excluded, err := toApiSet(excludedApis)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]bool)
+	for _, config := range allConfigsOf(projects) {
+		if (len(included) == 0 || included[config.GetType()]) && !excluded[config.GetType()] {
+			selected[config.GetFullQualifiedId()] = true
+		}
+	}
+	return selected, nil
+}
+
+// CheckExcludedDependencies returns an error naming both configs, if a selected config depends on a config
+// which has been excluded by the API filter (see SelectApis)
+func CheckExcludedDependencies(projects []Project, selected map[string]bool) error {
+
+	allConfigs := allConfigsOf(projects)
+
+	for _, config := range allConfigs {
+		if !selected[config.GetFullQualifiedId()] {
+			continue
+		}
+
+		for _, other := range allConfigs {
+			if !selected[other.GetFullQualifiedId()] && config.HasDependencyOn(other) {
+				return fmt.Errorf("config %s depends on %s, which is excluded by the API filter (%s)",
+					config.GetFullQualifiedId(), other.GetFullQualifiedId(), other.GetType())
+			}
+		}
+	}
+	return nil
+}
+
+func allConfigsOf(projects []Project) []config.Config {
+	var allConfigs []config.Config
+	for _, project := range projects {
+		allConfigs = append(allConfigs, project.GetConfigs()...)
+	}
+	return allConfigs
+}
+
+func toApiSet(apis []string) (map[string]bool, error) {
+	set := make(map[string]bool, len(apis))
+	for _, id := range apis {
+		id = strings.TrimSpace(id)
+		if !api.IsApi(id) {
+			return nil, fmt.Errorf("value %s is not a valid API name", id)
+		}
+		set[id] = true
+	}
+	return set, nil
+}
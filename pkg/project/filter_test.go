@@ -0,0 +1,167 @@
+// +build unit
+
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package project
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/config"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"gotest.tools/assert"
+)
+
+func loadTransitionalDependencyTestProjects(t *testing.T) (string, []Project) {
+	folder := util.ReplacePathSeparators("test-resources/transitional-dependency-test")
+	projects, err := LoadProjectsToDeploy(util.CreateTestFileSystem(), "", api.NewApis(), folder)
+	assert.NilError(t, err)
+	return folder, projects
+}
+
+func selectedIds(folder string, selected map[string]bool) []string {
+	ids := make([]string, 0, len(selected))
+	for id := range selected {
+		ids = append(ids, id[len(folder)+1:])
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSelectConfigsIncludesTransitiveDependenciesOfOtherProjects(t *testing.T) {
+	folder, projects := loadTransitionalDependencyTestProjects(t)
+
+	selected, err := SelectConfigs(projects, []string{"aseed/auto-tag/application-tagging"}, folder)
+	assert.NilError(t, err)
+
+	expected := []string{
+		util.ReplacePathSeparators("aseed/auto-tag/application-tagging"),
+		util.ReplacePathSeparators("marvin/management-zone/zone"),
+		util.ReplacePathSeparators("trillian/dashboard/dashboard"),
+		util.ReplacePathSeparators("zaphod/alerting-profile/profile"),
+	}
+	assert.DeepEqual(t, selectedIds(folder, selected), expected)
+}
+
+func TestSelectConfigsWithWildcard(t *testing.T) {
+	folder, projects := loadTransitionalDependencyTestProjects(t)
+
+	selected, err := SelectConfigs(projects, []string{"caveman/*/*/management-zone/*", "zaphod/*/*"}, folder)
+	assert.NilError(t, err)
+
+	expected := []string{
+		util.ReplacePathSeparators("caveman/anjie/garkbit/management-zone/zone"),
+		util.ReplacePathSeparators("zaphod/alerting-profile/profile"),
+	}
+	assert.DeepEqual(t, selectedIds(folder, selected), expected)
+}
+
+func TestSelectConfigsFailsIfPatternDoesNotMatch(t *testing.T) {
+	folder, projects := loadTransitionalDependencyTestProjects(t)
+
+	_, err := SelectConfigs(projects, []string{"zaphod/*/*", "zaphod/dashboard/*"}, folder)
+	assert.ErrorContains(t, err, "no config matches zaphod/dashboard/*")
+}
+
+func TestSelectConfigsFailsOnInvalidPattern(t *testing.T) {
+	folder, projects := loadTransitionalDependencyTestProjects(t)
+
+	_, err := SelectConfigs(projects, []string{"zaphod/[/*"}, folder)
+	assert.ErrorContains(t, err, "config pattern zaphod/[/* is not valid")
+}
+
+func TestFilterConfigsOmitsEmptyProjects(t *testing.T) {
+	folder, projects := loadTransitionalDependencyTestProjects(t)
+
+	selected, err := SelectConfigs(projects, []string{"marvin/management-zone/zone"}, folder)
+	assert.NilError(t, err)
+
+	filtered := FilterConfigs(projects, func(config config.Config) bool {
+		return selected[config.GetFullQualifiedId()]
+	})
+
+	ps := string(os.PathSeparator)
+	assert.Equal(t, len(filtered), 3)
+	assert.Equal(t, filtered[0].GetId(), folder+ps+"zaphod")
+	assert.Equal(t, filtered[1].GetId(), folder+ps+"trillian")
+	assert.Equal(t, filtered[2].GetId(), folder+ps+"marvin")
+	assert.Assert(t, filtered[2].HasDependencyOn(filtered[1]))
+	assert.Assert(t, filtered[1].HasDependencyOn(filtered[0]))
+}
+
+func TestFilterConfigsKeepsOnlySelectedConfigsOfProject(t *testing.T) {
+	folder, projects := loadTransitionalDependencyTestProjects(t)
+
+	var aseed Project
+	for _, project := range projects {
+		if project.GetId() == folder+string(os.PathSeparator)+"aseed" {
+			aseed = project
+		}
+	}
+	assert.Assert(t, aseed != nil)
+
+	tagging := util.ReplacePathSeparators(folder + "/aseed/auto-tag/application-tagging")
+
+	filtered := FilterConfigs([]Project{aseed}, func(config config.Config) bool {
+		return config.GetFullQualifiedId() == tagging
+	})
+
+	assert.Equal(t, len(filtered), 1)
+	assert.Equal(t, len(filtered[0].GetConfigs()), 1)
+	assert.Equal(t, filtered[0].GetConfigs()[0].GetFullQualifiedId(), tagging)
+
+	_, err := filtered[0].GetConfig(tagging)
+	assert.NilError(t, err)
+
+	_, err = filtered[0].GetConfig(util.ReplacePathSeparators(folder + "/aseed/management-zone/mg-zone"))
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestSelectApisIncludesOnlyGivenApis(t *testing.T) {
+	folder, projects := loadTransitionalDependencyTestProjects(t)
+
+	selected, err := SelectApis(projects, []string{"alerting-profile", "synthetic-monitor"}, nil)
+	assert.NilError(t, err)
+
+	expected := []string{
+		util.ReplacePathSeparators("caveman/eddie/synthetic-monitor/synthetic-monitor"),
+		util.ReplacePathSeparators("zaphod/alerting-profile/profile"),
+		util.ReplacePathSeparators("zem/alerting-profile/profile"),
+	}
+	assert.DeepEqual(t, selectedIds(folder, selected), expected)
+}
+
+func TestCheckExcludedDependenciesFailsIfSelectedConfigDependsOnExcludedApi(t *testing.T) {
+	_, projects := loadTransitionalDependencyTestProjects(t)
+
+	selected, err := SelectApis(projects, nil, []string{"alerting-profile"})
+	assert.NilError(t, err)
+
+	err = CheckExcludedDependencies(projects, selected)
+	assert.ErrorContains(t, err, util.ReplacePathSeparators("trillian/dashboard/dashboard depends on"))
+	assert.ErrorContains(t, err, util.ReplacePathSeparators("zaphod/alerting-profile/profile, which is excluded"))
+}
+
+func TestSelectApisFailsOnUnknownApi(t *testing.T) {
+	_, projects := loadTransitionalDependencyTestProjects(t)
+
+	_, err := SelectApis(projects, []string{"dashboard", "unknown-api"}, nil)
+	assert.ErrorContains(t, err, "value unknown-api is not a valid API name")
+}
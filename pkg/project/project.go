@@ -33,18 +33,21 @@ type Project interface {
 	HasDependencyOn(project Project) bool
 	GetConfigs() []config.Config
 	GetConfig(id string) (config.Config, error)
+	GetConfigDependencies(config config.Config) []string
 	GetId() string
 }
 
 type projectImpl struct {
-	id      string
-	configs []config.Config
+	id           string
+	configs      []config.Config
+	dependencies map[string][]string
 }
 
 type projectBuilder struct {
 	projectRootFolder string
 	projectId         string
 	configs           []config.Config
+	dependencies      map[string][]string
 	apis              map[string]api.Api
 	configFactory     config.ConfigFactory
 	fs                afero.Fs
@@ -82,8 +85,9 @@ func NewProject(fs afero.Fs, fullQualifiedProjectFolderName string, projectFolde
 	warnIfProjectNameClashesWithApiName(projectFolderName, apis, projectRootFolder)
 
 	return &projectImpl{
-		id:      fullQualifiedProjectFolderName,
-		configs: builder.configs,
+		id:           fullQualifiedProjectFolderName,
+		configs:      builder.configs,
+		dependencies: builder.dependencies,
 	}, nil
 }
 
@@ -243,9 +247,10 @@ func isYaml(file string) bool {
 
 func (p *projectBuilder) sortConfigsAccordingToDependencies() error {
 
-	configs, err := sortConfigurations(p.configs)
+	configs, dependencies, err := sortConfigurations(p.configs)
 	if err == nil {
 		p.configs = configs
+		p.dependencies = dependencies
 	}
 	return err
 }
@@ -267,6 +272,11 @@ func (p *projectImpl) GetConfig(id string) (config config.Config, err error) {
 	return config, fmt.Errorf("config with id %s not found", id)
 }
 
+// GetConfigDependencies returns the full qualified ids of the configs in this project the given config depends on
+func (p *projectImpl) GetConfigDependencies(config config.Config) []string {
+	return p.dependencies[config.GetFullQualifiedId()]
+}
+
 // GetId returns the id for this project
 func (p *projectImpl) GetId() string {
 	return p.id
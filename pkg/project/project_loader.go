@@ -88,6 +88,28 @@ func LoadProjectsToDeploy(fs afero.Fs, specificProjectToDeploy string, apis map[
 	return returnSortedProjects(projectsToDeploy)
 }
 
+// WithoutDependencyProjects returns only the projects specified by the -p parameter (including their subprojects).
+// Projects which have only been loaded because a specified project depends on them are removed.
+func WithoutDependencyProjects(projects []Project, specificProjectToDeploy string, path string) []Project {
+
+	projectsFolder := filepath.Clean(path)
+	specifiedFolders := make([]string, 0)
+	for _, projectFolderName := range strings.Split(specificProjectToDeploy, ",") {
+		specifiedFolders = append(specifiedFolders, filepath.Join(projectsFolder, strings.TrimSpace(projectFolderName)))
+	}
+
+	specifiedProjects := make([]Project, 0, len(projects))
+	for _, project := range projects {
+		for _, folder := range specifiedFolders {
+			if project.GetId() == folder || strings.HasPrefix(project.GetId(), folder+string(os.PathSeparator)) {
+				specifiedProjects = append(specifiedProjects, project)
+				break
+			}
+		}
+	}
+	return specifiedProjects
+}
+
 func returnSortedProjects(projectsToDeploy []Project) ([]Project, error) {
 	util.Log.Debug("Sorting projects...")
 	projectsToDeploy, err := sortProjects(projectsToDeploy)
@@ -194,3 +194,19 @@ func TestGetAllProjectFoldersRecursivelyPassesOnSeparatedFolders(t *testing.T) {
 	_, err := getAllProjectFoldersRecursively(fs, path)
 	assert.NilError(t, err)
 }
+
+func TestWithoutDependencyProjectsKeepsOnlySpecifiedProjects(t *testing.T) {
+	folder := util.ReplacePathSeparators("test-resources/transitional-dependency-test")
+	fs := util.CreateTestFileSystem()
+	projects, err := LoadProjectsToDeploy(fs, "zem, caveman", api.NewApis(), folder)
+	assert.NilError(t, err)
+
+	projects = WithoutDependencyProjects(projects, "zem, caveman", folder)
+
+	assert.Equal(t, len(projects), 3, "Check if there are only 3 projects in the list.")
+
+	ps := string(os.PathSeparator)
+	assert.Equal(t, projects[0].GetId(), folder+ps+"caveman"+ps+"eddie", "Check if `caveman/eddie` in projects list")
+	assert.Equal(t, projects[1].GetId(), folder+ps+"caveman"+ps+"anjie"+ps+"garkbit", "Check if `caveman/anjie/garkbit` in projects list")
+	assert.Equal(t, projects[2].GetId(), folder+ps+"zem", "Check if `zem` in projects list")
+}
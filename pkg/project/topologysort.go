@@ -59,20 +59,40 @@ func calculateIncomingProjectDependencies(projects []Project) (adjacencyMatrix [
 	return adjacencyMatrix, inDegrees
 }
 
-func sortConfigurations(configs []config.Config) (sorted []config.Config, err error) {
+// sortConfigurations sorts the configs according to their dependencies. In addition to the sorted configs, the
+// dependency graph is returned: for each full qualified config id the ids of the configs it depends on.
+func sortConfigurations(configs []config.Config) (sorted []config.Config, dependencies map[string][]string, err error) {
 	sorted = []config.Config{}
 	incomingDeps, inDegrees := calculateIncomingConfigDependencies(configs)
+	dependencies = collectConfigDependencies(configs, incomingDeps)
+
 	reverse, err, errorOn := topologySort(incomingDeps, inDegrees)
 	if err != nil {
 		util.Log.Debug(err.Error())
-		return sorted, fmt.Errorf("failed to sort configs, circular dependency on config %s detected, please check dependencies", configs[errorOn].GetFullQualifiedId())
+		return sorted, nil, fmt.Errorf("failed to sort configs, circular dependency on config %s detected, please check dependencies", configs[errorOn].GetFullQualifiedId())
 	}
 
 	for i := len(reverse) - 1; i >= 0; i-- {
 		sorted = append(sorted, configs[reverse[i]])
 		util.Log.Debug("\t\t%s", configs[reverse[i]].GetFullQualifiedId())
 	}
-	return sorted, nil
+	return sorted, dependencies, nil
+}
+
+// collectConfigDependencies converts the adjacency matrix into a map of full qualified config ids. It has to be
+// called before the topology sort, as the sort removes the edges from the matrix.
+func collectConfigDependencies(configs []config.Config, adjacencyMatrix [][]bool) map[string][]string {
+	dependencies := make(map[string][]string)
+
+	for i := range configs {
+		for j := range configs {
+			if adjacencyMatrix[i][j] {
+				dependent := configs[j].GetFullQualifiedId()
+				dependencies[dependent] = append(dependencies[dependent], configs[i].GetFullQualifiedId())
+			}
+		}
+	}
+	return dependencies
 }
 
 func calculateIncomingConfigDependencies(configs []config.Config) (adjacencyMatrix [][]bool, inDegrees []int) {
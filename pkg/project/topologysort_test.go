@@ -57,11 +57,14 @@ func TestSortingByConfigDependencyWithRootDirectory(t *testing.T) {
 
 	configs := []config.Config{configB, configA} // reverse ordering
 
-	configs, err := sortConfigurations(configs)
+	configs, dependencies, err := sortConfigurations(configs)
 	assert.NilError(t, err)
 
 	assert.Equal(t, configA, configs[0])
 	assert.Equal(t, configB, configs[1])
+	assert.DeepEqual(t, dependencies, map[string][]string{
+		configB.GetFullQualifiedId(): {configA.GetFullQualifiedId()},
+	})
 
 	assert.Check(t, !configA.HasDependencyOn(configB))
 	assert.Check(t, configB.HasDependencyOn(configA))
@@ -76,7 +79,7 @@ func TestFailsOnCircularConfigDependency(t *testing.T) {
 
 	configs := []config.Config{configB, configA} // reverse ordering
 
-	configs, err := sortConfigurations(configs)
+	configs, _, err := sortConfigurations(configs)
 	assert.Error(t, err, "failed to sort configs, circular dependency on config "+pathB+"profile detected, please check dependencies")
 
 	assert.Check(t, configA.HasDependencyOn(configB))
@@ -92,7 +95,7 @@ func TestSortingByConfigDependencyWithoutRootDirectory(t *testing.T) {
 
 	configs := []config.Config{configB, configA} // reverse ordering
 
-	configs, err := sortConfigurations(configs)
+	configs, _, err := sortConfigurations(configs)
 	assert.NilError(t, err)
 	assert.Equal(t, configA, configs[0])
 	assert.Equal(t, configB, configs[1])
@@ -110,7 +113,7 @@ func TestSortingByConfigDependencyWithRelativePath(t *testing.T) {
 
 	configs := []config.Config{configB, configA} // reverse ordering
 
-	configs, err := sortConfigurations(configs)
+	configs, _, err := sortConfigurations(configs)
 	assert.NilError(t, err)
 	assert.Equal(t, configA, configs[0])
 	assert.Equal(t, configB, configs[1])
@@ -0,0 +1,257 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/spf13/afero"
+)
+
+const (
+	FormatJson  = "json"
+	FormatJunit = "junit"
+)
+
+const (
+	OperationCreated   = "created"
+	OperationUpdated   = "updated"
+	OperationUnchanged = "unchanged"
+	OperationSkipped   = "skipped"
+	OperationValidated = "validated"
+	OperationFailed    = "failed"
+)
+
+// Report is the machine-readable result of a deployment
+type Report struct {
+	Environments []EnvironmentReport `json:"environments"`
+}
+
+// EnvironmentReport contains the results of all configs deployed to an environment. Errors which are not related
+// to a single config (e.g. a missing token) are listed separately.
+type EnvironmentReport struct {
+	Environment string         `json:"environment"`
+	Configs     []ConfigReport `json:"configs"`
+	Hooks       []HookReport   `json:"hooks,omitempty"`
+	Retries     int            `json:"retries,omitempty"`
+	Errors      []ErrorReport  `json:"errors,omitempty"`
+}
+
+// ConfigReport is the result of the deployment of a single config
+type ConfigReport struct {
+	Config     string       `json:"config"`
+	Api        string       `json:"api"`
+	Operation  string       `json:"operation"`
+	Id         string       `json:"id,omitempty"`
+	DurationMs int64        `json:"durationMs"`
+	HttpStatus int          `json:"httpStatus,omitempty"`
+	Error      *ErrorReport `json:"error,omitempty"`
+}
+
+// HookReport is the outcome of a hook run before or after the deployment of an environment or project.
+// Project is empty for hooks of the environment.
+type HookReport struct {
+	Hook       string       `json:"hook"`
+	Project    string       `json:"project,omitempty"`
+	Command    string       `json:"command"`
+	DurationMs int64        `json:"durationMs"`
+	Error      *ErrorReport `json:"error,omitempty"`
+}
+
+// ErrorReport describes an error. File, line and column are only set for errors which contain this information.
+type ErrorReport struct {
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// IsSupportedFormat checks whether a report can be written in the given format
+func IsSupportedFormat(format string) bool {
+	return format == FormatJson || format == FormatJunit
+}
+
+// NewErrorReport converts an error into its report. Line information of json validation errors is kept.
+func NewErrorReport(err error) ErrorReport {
+
+	errorReport := ErrorReport{
+		Message: err.Error(),
+	}
+
+	var jsonError util.JsonValidationError
+	if errors.As(err, &jsonError) {
+		errorReport.File = jsonError.FileName
+		if jsonError.ContainsLineInformation() {
+			errorReport.Line = jsonError.LineNumber
+			errorReport.Column = jsonError.CharacterNumberInLine
+		}
+	}
+	return errorReport
+}
+
+// HttpStatusOf returns the HTTP status of a failed request contained in the error, or 0 if there is none
+func HttpStatusOf(err error) int {
+	var apiError rest.ApiError
+	if errors.As(err, &apiError) {
+		return apiError.StatusCode
+	}
+	return 0
+}
+
+// Write writes the report in the given format to the given file
+func Write(fs afero.Fs, report Report, format string, file string) error {
+
+	sort.Slice(report.Environments, func(i, j int) bool {
+		return report.Environments[i].Environment < report.Environments[j].Environment
+	})
+
+	var data []byte
+	var err error
+
+	switch format {
+	case FormatJson:
+		data, err = json.MarshalIndent(report, "", "  ")
+	case FormatJunit:
+		data, err = xml.MarshalIndent(toJunit(report), "", "  ")
+		data = append([]byte(xml.Header), data...)
+	default:
+		return fmt.Errorf("unknown report format %s, supported formats are %s and %s", format, FormatJson, FormatJunit)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return afero.WriteFile(fs, file, data, 0664)
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// toJunit converts the report into JUnit XML. Each environment is a test suite and each config a test case.
+// Errors which are not related to a config are reported as failed test case named after the environment.
+func toJunit(report Report) junitTestSuites {
+
+	testSuites := junitTestSuites{
+		TestSuites: make([]junitTestSuite, 0, len(report.Environments)),
+	}
+
+	for _, environment := range report.Environments {
+		testSuite := junitTestSuite{
+			Name:      environment.Environment,
+			TestCases: make([]junitTestCase, 0, len(environment.Configs)),
+		}
+
+		var duration time.Duration
+
+		for _, config := range environment.Configs {
+			configDuration := time.Duration(config.DurationMs) * time.Millisecond
+			duration += configDuration
+
+			testCase := junitTestCase{
+				Name:      config.Config,
+				ClassName: environment.Environment + "." + config.Api,
+				Time:      formatSeconds(configDuration),
+			}
+
+			switch config.Operation {
+			case OperationFailed:
+				testSuite.Failures++
+				testCase.Failure = newJunitFailure(config.Error)
+			case OperationSkipped:
+				testSuite.Skipped++
+				testCase.Skipped = &junitSkipped{Message: "skipped"}
+			default:
+				testCase.SystemOut = config.Operation + " " + config.Id
+			}
+
+			testSuite.TestCases = append(testSuite.TestCases, testCase)
+		}
+
+		for _, errorReport := range environment.Errors {
+			errorReport := errorReport
+			testSuite.Failures++
+			testSuite.TestCases = append(testSuite.TestCases, junitTestCase{
+				Name:      environment.Environment,
+				ClassName: environment.Environment,
+				Time:      formatSeconds(0),
+				Failure:   newJunitFailure(&errorReport),
+			})
+		}
+
+		testSuite.Tests = len(testSuite.TestCases)
+		testSuite.Time = formatSeconds(duration)
+		testSuites.TestSuites = append(testSuites.TestSuites, testSuite)
+	}
+	return testSuites
+}
+
+func newJunitFailure(errorReport *ErrorReport) *junitFailure {
+	if errorReport == nil {
+		return &junitFailure{Message: "failed"}
+	}
+
+	failure := &junitFailure{
+		Message: errorReport.Message,
+		Text:    errorReport.Message,
+	}
+	if errorReport.File != "" {
+		failure.Text = fmt.Sprintf("%s\n%s", errorReport.Message, errorReport.File)
+		if errorReport.Line > 0 {
+			failure.Text = fmt.Sprintf("%s\n%s:%d:%d", errorReport.Message, errorReport.File, errorReport.Line, errorReport.Column)
+		}
+	}
+	return failure
+}
+
+func formatSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
@@ -0,0 +1,108 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/rest"
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"github.com/spf13/afero"
+	"gotest.tools/assert"
+)
+
+func testReport() Report {
+	return Report{
+		Environments: []EnvironmentReport{
+			{
+				Environment: "prod",
+				Errors:      []ErrorReport{{Message: "no token"}},
+			},
+			{
+				Environment: "dev",
+				Configs: []ConfigReport{
+					{Config: "project/management-zone/zone", Api: "management-zone", Operation: OperationCreated, Id: "zone-id", DurationMs: 1500, HttpStatus: 201},
+					{Config: "project/dashboard/overview", Api: "dashboard", Operation: OperationSkipped},
+					{Config: "project/alerting-profile/profile", Api: "alerting-profile", Operation: OperationFailed, DurationMs: 20, HttpStatus: 400,
+						Error: &ErrorReport{Message: "invalid json", File: "profile.json", Line: 3, Column: 7}},
+				},
+			},
+		},
+	}
+}
+
+func TestNewErrorReportKeepsLineInformation(t *testing.T) {
+	err := fmt.Errorf("%w, responsible config: profile.yaml", util.JsonValidationError{
+		FileName:              "profile.json",
+		LineNumber:            3,
+		CharacterNumberInLine: 7,
+		LineContent:           `"name": ,`,
+		Cause:                 errors.New("invalid character ','"),
+	})
+
+	errorReport := NewErrorReport(err)
+	assert.Equal(t, errorReport.Message, err.Error())
+	assert.Equal(t, errorReport.File, "profile.json")
+	assert.Equal(t, errorReport.Line, 3)
+	assert.Equal(t, errorReport.Column, 7)
+}
+
+func TestHttpStatusOf(t *testing.T) {
+	err := fmt.Errorf("%w, responsible config: zone.yaml", rest.ApiError{StatusCode: 400, Body: "{}", Url: "https://test.com/api/config/v1/managementZones"})
+
+	assert.Equal(t, HttpStatusOf(err), 400)
+	assert.Equal(t, HttpStatusOf(errors.New("connection reset")), 0)
+}
+
+func TestWriteJson(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	err := Write(fs, testReport(), FormatJson, "report.json")
+	assert.NilError(t, err)
+
+	data, err := afero.ReadFile(fs, "report.json")
+	assert.NilError(t, err)
+
+	var written Report
+	assert.NilError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, written.Environments[0].Environment, "dev")
+	assert.DeepEqual(t, written.Environments[0].Configs[2].Error, &ErrorReport{Message: "invalid json", File: "profile.json", Line: 3, Column: 7})
+}
+
+func TestWriteJunit(t *testing.T) {
+	fs := afero.NewMemMapFs()
+
+	err := Write(fs, testReport(), FormatJunit, "report.xml")
+	assert.NilError(t, err)
+
+	data, err := afero.ReadFile(fs, "report.xml")
+	assert.NilError(t, err)
+
+	xml := string(data)
+	assert.Assert(t, strings.Contains(xml, `<testsuite name="dev" tests="3" failures="1" skipped="1" time="1.520">`), xml)
+	assert.Assert(t, strings.Contains(xml, `<failure message="invalid json">invalid json&#xA;profile.json:3:7</failure>`), xml)
+	assert.Assert(t, strings.Contains(xml, `<testsuite name="prod" tests="1" failures="1" skipped="0" time="0.000">`), xml)
+}
+
+func TestWriteFailsOnUnknownFormat(t *testing.T) {
+	err := Write(afero.NewMemMapFs(), testReport(), "xml", "report.xml")
+	assert.ErrorContains(t, err, "unknown report format xml")
+}
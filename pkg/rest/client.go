@@ -17,7 +17,9 @@
 package rest
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
@@ -37,52 +39,101 @@ import (
 // Its design is intentionally not dependant on the Config and Environment interfaces included in monaco.
 // This makes sure, that DynatraceClient can be used as a base for future tooling, which relies on
 // a standardized way to access Dynatrace APIs.
+// All operations take a context. Once it is done, pending requests are canceled and no further requests are sent.
 type DynatraceClient interface {
 
 	// List lists the available configs for an API.
 	// It calls the underlying GET endpoint of the API. E.g. for alerting profiles this would be:
 	//    GET <environment-url>/api/config/v1/alertingProfiles
 	// The result is expressed using a list of Value (id and name tuples).
-	List(a Api) (values []Value, err error)
+	List(ctx context.Context, a Api) (values []Value, err error)
 
 	// ReadByName reads a Dynatrace config identified by name from the given API.
 	// It calls the underlying GET endpoints for the API. E.g. for alerting profiles this would be:
 	//    GET <environment-url>/api/config/v1/alertingProfiles ... to get the id of the existing alerting profile
 	//    GET <environment-url>/api/config/v1/alertingProfiles/<id> ... to get the alerting profile
-	ReadByName(a Api, name string) (json []byte, err error)
+	ReadByName(ctx context.Context, a Api, name string) (json []byte, err error)
 
 	// ReadById reads a Dynatrace config identified by id from the given API.
 	// It calls the underlying GET endpoint for the API. E.g. for alerting profiles this would be:
 	//    GET <environment-url>/api/config/v1/alertingProfiles/<id> ... to get the alerting profile
-	ReadById(a Api, name string) (json []byte, err error)
+	ReadById(ctx context.Context, a Api, name string) (json []byte, err error)
 
 	// Upsert creates a given Dynatrace config it it doesn't exists and updates it otherwise using its name
 	// It calls the underlying GET, POST, and PUT endpoints for the API. E.g. for alerting profiles this would be:
 	//    GET <environment-url>/api/config/v1/alertingProfiles ... to check if the config is already available
 	//    POST <environment-url>/api/config/v1/alertingProfiles ... afterwards, if the config is not yet available
 	//    PUT <environment-url>/api/config/v1/alertingProfiles/<id> ... instead of POST, if the config is already available
-	UpsertByName(a Api, name string, payload []byte) (entity DynatraceEntity, err error)
+	UpsertByName(ctx context.Context, a Api, name string, payload []byte) (entity DynatraceEntity, err error)
+
+	// UpsertById updates the Dynatrace config with the given id, which allows to rename it.
+	// If no config with the given id exists anymore, it falls back to UpsertByName.
+	// It calls the underlying GET and PUT endpoints for the API. E.g. for alerting profiles this would be:
+	//    GET <environment-url>/api/config/v1/alertingProfiles/<id> ... to check if the config is still available
+	//    PUT <environment-url>/api/config/v1/alertingProfiles/<id> ... to update the config
+	UpsertById(ctx context.Context, a Api, id string, name string, payload []byte) (entity DynatraceEntity, err error)
 
 	// Delete removed a given config for a given API using its name.
 	// It calls the underlying GET and DELETE endpoints for the API. E.g. for alerting profiles this would be:
 	//    GET <environment-url>/api/config/v1/alertingProfiles ... to get the id of the existing config
 	//    DELETE <environment-url>/api/config/v1/alertingProfiles/<id> ... to delete the config
-	DeleteByName(a Api, name string) error
+	DeleteByName(ctx context.Context, a Api, name string) error
+
+	// DeleteById removes the config with the given id from the given API. Configs which don't exist are ignored.
+	// It calls the underlying DELETE endpoint for the API. E.g. for alerting profiles this would be:
+	//    DELETE <environment-url>/api/config/v1/alertingProfiles/<id> ... to delete the config
+	DeleteById(ctx context.Context, a Api, id string) error
 
 	// ExistsByName checks if a config with the given name exists for the given API.
 	// It cally the underlying GET endpoint for the API. E.g. for alerting profiles this would be:
 	//    GET <environment-url>/api/config/v1/alertingProfiles
-	ExistsByName(a Api, name string) (exists bool, id string, err error)
+	ExistsByName(ctx context.Context, a Api, name string) (exists bool, id string, err error)
 }
 
 type dynatraceClientImpl struct {
 	environmentUrl string
 	token          string
 	client         *http.Client
+	cache          *valueCache
+}
+
+// ClientOption configures a DynatraceClient created by NewDynatraceClient
+type ClientOption func(options *clientOptions)
+
+type clientOptions struct {
+	rateLimitStrategy string
+	rateLimit         int
+	log               util.PrefixedLogger
+}
+
+// WithRateLimitStrategy selects how the client handles the rate limit of the environment, either simple or
+// token-bucket. Without this option or if the name is empty, the environment variable MONACO_RATE_LIMIT_STRATEGY
+// selects the strategy.
+func WithRateLimitStrategy(name string) ClientOption {
+	return func(options *clientOptions) {
+		options.rateLimitStrategy = name
+	}
+}
+
+// WithRateLimit sets the maximum number of requests per minute the token-bucket strategy sends to the environment.
+// Lower limits reported by the environment are respected. Without this option or if the limit isn't positive,
+// requests are only throttled once the environment reported its limit.
+func WithRateLimit(requestsPerMinute int) ClientOption {
+	return func(options *clientOptions) {
+		options.rateLimit = requestsPerMinute
+	}
+}
+
+// WithLogger makes the client log retries and rate limiting with the given logger, e.g. to prefix the messages with
+// the environment. Without this option, messages are logged without prefix.
+func WithLogger(log util.PrefixedLogger) ClientOption {
+	return func(options *clientOptions) {
+		options.log = log
+	}
 }
 
 // NewDynatraceClient creates a new DynatraceClient
-func NewDynatraceClient(environmentUrl, token string) (DynatraceClient, error) {
+func NewDynatraceClient(environmentUrl, token string, opts ...ClientOption) (DynatraceClient, error) {
 
 	if environmentUrl == "" {
 		return nil, errors.New("no environment url")
@@ -101,15 +152,40 @@ func NewDynatraceClient(environmentUrl, token string) (DynatraceClient, error) {
 		return nil, errors.New("environment url " + environmentUrl + " was not valid")
 	}
 
+	options := clientOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	if !isNewDynatraceTokenFormat(token) {
-		util.Log.Warn("You used an old token format. Please consider switching to the new 1.205+ token format.")
-		util.Log.Warn("More information: https://www.dynatrace.com/support/help/dynatrace-api/basics/dynatrace-api-authentication/#-dynatrace-version-1205--token-format")
+		options.log.Warn("You used an old token format. Please consider switching to the new 1.205+ token format.")
+		options.log.Warn("More information: https://www.dynatrace.com/support/help/dynatrace-api/basics/dynatrace-api-authentication/#-dynatrace-version-1205--token-format")
 	}
 
+	retryPolicy, err := retryPolicyFromEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	if options.rateLimitStrategy == "" {
+		options.rateLimitStrategy, err = rateLimitStrategyFromEnvironment()
+	} else {
+		err = validateRateLimitStrategy(options.rateLimitStrategy)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	transport := newRetryTransport(retryPolicy)
+	transport.rateLimitStrategy = options.rateLimitStrategy
+	transport.rateLimit = options.rateLimit
+	transport.log = options.log
+
 	return &dynatraceClientImpl{
 		environmentUrl: environmentUrl,
 		token:          token,
-		client:         &http.Client{},
+		client:         &http.Client{Transport: transport},
+		cache:          newValueCache(),
 	}, nil
 }
 
@@ -117,16 +193,16 @@ func isNewDynatraceTokenFormat(token string) bool {
 	return strings.HasPrefix(token, "dt0c01.") && strings.Count(token, ".") == 2
 }
 
-func (d *dynatraceClientImpl) List(api Api) (values []Value, err error) {
+func (d *dynatraceClientImpl) List(ctx context.Context, api Api) (values []Value, err error) {
 
 	fullUrl := api.GetUrlFromEnvironmentUrl(d.environmentUrl)
-	values, err = getExistingValuesFromEndpoint(d.client, api, fullUrl, d.token)
+	values, err = listValues(ctx, d.client, d.cache, api, fullUrl, d.token)
 	return values, err
 }
 
-func (d *dynatraceClientImpl) ReadByName(api Api, name string) (json []byte, err error) {
+func (d *dynatraceClientImpl) ReadByName(ctx context.Context, api Api, name string) (json []byte, err error) {
 
-	exists, id, err := d.ExistsByName(api, name)
+	exists, id, err := d.ExistsByName(ctx, api, name)
 	if err != nil {
 		return nil, err
 	}
@@ -135,32 +211,40 @@ func (d *dynatraceClientImpl) ReadByName(api Api, name string) (json []byte, err
 		return nil, errors.New("404 - no config found with name " + name)
 	}
 
-	return d.ReadById(api, id)
+	return d.ReadById(ctx, api, id)
 }
 
-func (d *dynatraceClientImpl) ReadById(api Api, id string) (json []byte, err error) {
+func (d *dynatraceClientImpl) ReadById(ctx context.Context, api Api, id string) (json []byte, err error) {
 	fullUrl := api.GetUrlFromEnvironmentUrl(d.environmentUrl) + "/" + id
-	response, err := get(d.client, fullUrl, d.token)
+	response, err := get(ctx, d.client, fullUrl, d.token)
 
 	if err != nil {
 		return nil, err
 	}
+	if !success(response) {
+		return nil, fmt.Errorf("Failed to read DT object %s of %s: %w", id, api.GetId(), newApiError(response, fullUrl))
+	}
 
 	return response.Body, nil
 }
 
-func (d *dynatraceClientImpl) DeleteByName(api Api, name string) error {
+func (d *dynatraceClientImpl) DeleteByName(ctx context.Context, api Api, name string) error {
 
-	return deleteDynatraceObject(d.client, api, name, api.GetUrlFromEnvironmentUrl(d.environmentUrl), d.token)
+	return deleteDynatraceObject(ctx, d.client, d.cache, api, name, api.GetUrlFromEnvironmentUrl(d.environmentUrl), d.token)
 }
 
-func (d *dynatraceClientImpl) ExistsByName(api Api, name string) (exists bool, id string, err error) {
+func (d *dynatraceClientImpl) DeleteById(ctx context.Context, api Api, id string) error {
 
-	existingObjectId, err := getObjectIdIfAlreadyExists(d.client, api, api.GetUrlFromEnvironmentUrl(d.environmentUrl), name, d.token)
+	return deleteDynatraceObjectById(ctx, d.client, d.cache, api, id, api.GetUrlFromEnvironmentUrl(d.environmentUrl), d.token)
+}
+
+func (d *dynatraceClientImpl) ExistsByName(ctx context.Context, api Api, name string) (exists bool, id string, err error) {
+
+	existingObjectId, err := getObjectIdIfAlreadyExists(ctx, d.client, d.cache, api, api.GetUrlFromEnvironmentUrl(d.environmentUrl), name, d.token)
 	return existingObjectId != "", existingObjectId, err
 }
 
-func (d *dynatraceClientImpl) UpsertByName(api Api, name string, payload []byte) (entity DynatraceEntity, err error) {
+func (d *dynatraceClientImpl) UpsertByName(ctx context.Context, api Api, name string, payload []byte) (entity DynatraceEntity, err error) {
 
 	fullUrl := api.GetUrlFromEnvironmentUrl(d.environmentUrl)
 
@@ -168,8 +252,21 @@ func (d *dynatraceClientImpl) UpsertByName(api Api, name string, payload []byte)
 		return DynatraceEntity{}, err
 	}
 
-	if api.GetId() == "extension" {
-		return uploadExtension(d.client, fullUrl, name, payload, d.token)
+	if api.GetBehavior().IsUpload() {
+		entity, err := uploadExtension(ctx, d.client, fullUrl, name, payload, d.token)
+		// the upload response doesn't contain the id of the extension, so it has to be listed again
+		d.cache.invalidate(api)
+		return entity, err
 	}
-	return upsertDynatraceObject(d.client, fullUrl, name, api, payload, d.token)
+	return upsertDynatraceObject(ctx, d.client, d.cache, fullUrl, name, api, payload, d.token)
+}
+
+func (d *dynatraceClientImpl) UpsertById(ctx context.Context, api Api, id string, name string, payload []byte) (entity DynatraceEntity, err error) {
+
+	if api.GetBehavior().IsUpload() {
+		return d.UpsertByName(ctx, api, name, payload)
+	}
+
+	fullUrl := api.GetUrlFromEnvironmentUrl(d.environmentUrl)
+	return upsertDynatraceObjectById(ctx, d.client, d.cache, fullUrl, id, name, api, payload, d.token)
 }
@@ -17,140 +17,131 @@
 package rest
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
 )
 
-func upsertDynatraceObject(client *http.Client, fullUrl string, objectName string, theApi api.Api, payload []byte, apiToken string) (api.DynatraceEntity, error) {
+func upsertDynatraceObject(ctx context.Context, client *http.Client, cache *valueCache, fullUrl string, objectName string, theApi api.Api, payload []byte, apiToken string) (api.DynatraceEntity, error) {
 
-	existingObjectId, err := getObjectIdIfAlreadyExists(client, theApi, fullUrl, objectName, apiToken)
+	existingObjectId, err := getObjectIdIfAlreadyExists(ctx, client, cache, theApi, fullUrl, objectName, apiToken)
 	if err != nil {
 		return api.DynatraceEntity{}, err
 	}
 
-	var resp Response
-	path := fullUrl
-	body := payload
-	configType := theApi.GetId()
+	if existingObjectId != "" {
+		path := joinUrl(fullUrl, existingObjectId)
 
-	// The calculated-metrics-log API doesn't have a POST endpoint, to create a new log metric we need to use PUT which
-	// requires a metric key for which we can just take the objectName
-	if configType == "calculated-metrics-log" && existingObjectId == "" {
-		existingObjectId = objectName
+		existing, err := get(ctx, client, path, apiToken)
+		if err == nil && success(existing) && isUnchanged(existing.Body, payload) {
+			return unchangedEntity(existingObjectId, objectName), nil
+		}
+
+		return updateDynatraceObject(ctx, client, cache, path, existingObjectId, objectName, theApi, payload, apiToken)
 	}
 
-	isUpdate := existingObjectId != ""
+	method, path := theApi.GetBehavior().CreateRequest(fullUrl, objectName)
 
-	if isUpdate {
-		path = joinUrl(fullUrl, existingObjectId)
-		// Updating a dashboard requires the ID to be contained in the JSON, so we just add it...
-		if isApiDashboard(theApi) {
-			tmp := strings.Replace(string(payload), "{", "{\n\"id\":\""+existingObjectId+"\",\n", 1)
-			body = []byte(tmp)
-		}
-		resp, err = put(client, path, body, apiToken)
+	// errors caused by the config not being propagated to all cluster nodes yet (e.g. "must have a unique name")
+	// are retried by the RetryPolicy of the client
+	resp, err := send(ctx, client, method, path, payload, apiToken)
+	if err != nil {
+		return api.DynatraceEntity{}, err
+	}
+	if !success(resp) {
+		return api.DynatraceEntity{}, fmt.Errorf("Failed to create DT object %s: %w", objectName, newApiError(resp, path))
+	}
 
-		if err != nil {
-			return api.DynatraceEntity{}, err
-		}
+	dtEntity, err := theApi.GetBehavior().ParseCreateResponse(fullUrl, objectName, resp.Headers, resp.Body)
+	if err != nil {
+		return api.DynatraceEntity{}, fmt.Errorf("Failed to read id of created DT object %s of %s: %w", objectName, theApi.GetId(), err)
+	}
 
-		if success(resp) {
-			util.Log.Debug("\t\t\tUpdated existing object for %s (%s)", objectName, existingObjectId)
-			return api.DynatraceEntity{
-				Id:          existingObjectId,
-				Name:        objectName,
-				Description: "Updated existing object",
-			}, nil
-		} else {
-			return api.DynatraceEntity{}, fmt.Errorf("Failed to update DT object %s (HTTP %d)!\n    Response was: %s", objectName, resp.StatusCode, string(resp.Body))
-		}
+	util.Log.Debug("\t\t\tCreated new object for %s (%s)", dtEntity.Name, dtEntity.Id)
+	dtEntity.Created = true
+	dtEntity.StatusCode = resp.StatusCode
+	cache.put(theApi, api.Value{Id: dtEntity.Id, Name: objectName})
 
-	} else {
-		if configType == "app-detection-rule" {
-			path += "?position=PREPEND"
-		}
-		resp, err = post(client, path, body, apiToken)
+	return dtEntity, nil
+}
 
-		if err != nil {
-			return api.DynatraceEntity{}, err
-		}
+// upsertDynatraceObjectById updates the object with the given id. In contrast to upsertDynatraceObject, this also
+// works if the name of the object changes. If the object doesn't exist anymore, it is upserted by name instead.
+func upsertDynatraceObjectById(ctx context.Context, client *http.Client, cache *valueCache, fullUrl string, id string, objectName string, theApi api.Api, payload []byte, apiToken string) (api.DynatraceEntity, error) {
 
-		// It can happen that the post fails because config needs time to be propagated on all cluster nodes. If the error
-		// constraintViolations":[{"path":"name","message":"X must have a unique name...
-		// is returned, try once again
-		if !success(resp) && strings.Contains(string(resp.Body), "must have a unique name") {
-			// Try again after 5 seconds:
-			util.Log.Warn("\t\tConfig '%s - %s' needs to have a unique name. Waiting for 5 seconds before retry...", configType, objectName)
-			time.Sleep(5 * time.Second)
-			resp, err = post(client, path, body, apiToken)
+	path := joinUrl(fullUrl, id)
 
-			if err != nil {
-				return api.DynatraceEntity{}, err
-			}
-		}
-		// It can take longer until request attributes are ready to be used
-		if !success(resp) && strings.Contains(string(resp.Body), "must specify a known request attribute") {
-			util.Log.Warn("\t\tSpecified request attribute not known for %s. Waiting for 10 seconds before retry...", objectName)
-			time.Sleep(10 * time.Second)
-			resp, err = post(client, path, body, apiToken)
+	resp, err := get(ctx, client, path, apiToken)
+	if err != nil {
+		return api.DynatraceEntity{}, err
+	}
 
-			if err != nil {
-				return api.DynatraceEntity{}, err
-			}
-		}
-		if !success(resp) {
-			return api.DynatraceEntity{}, fmt.Errorf("Failed to create DT object %s (HTTP %d)!\n    Response was: %s", objectName, resp.StatusCode, string(resp.Body))
-		}
+	if resp.StatusCode == http.StatusNotFound {
+		util.Log.Debug("\t\t\tObject %s (%s) does not exist anymore, looking it up by name", objectName, id)
+		return upsertDynatraceObject(ctx, client, cache, fullUrl, objectName, theApi, payload, apiToken)
 	}
 
-	var dtEntity api.DynatraceEntity
+	if !success(resp) {
+		return api.DynatraceEntity{}, fmt.Errorf("Failed to read DT object %s: %w", objectName, newApiError(resp, path))
+	}
 
-	if configType == "synthetic-monitor" || configType == "synthetic-location" {
-		var entity api.SyntheticEntity
-		err := json.Unmarshal(resp.Body, &entity)
-		if util.CheckError(err, "Cannot unmarshal Synthetic API response") {
-			return api.DynatraceEntity{}, err
-		}
-		dtEntity = translateSyntheticEntityResponse(entity, objectName)
+	if isUnchanged(resp.Body, payload) {
+		return unchangedEntity(id, objectName), nil
+	}
 
-	} else if available, locationSlice := isLocationHeaderAvailable(resp); available {
+	return updateDynatraceObject(ctx, client, cache, path, id, objectName, theApi, payload, apiToken)
+}
 
-		// The POST of the SLO API does not return the ID of the config in its response. Instead, it contains a
-		// Location header, which contains the URL to the created resource. This URL needs to be cleaned, to get the
-		// ID of the config.
+// updateDynatraceObject replaces the existing object at the given path with the payload
+func updateDynatraceObject(ctx context.Context, client *http.Client, cache *valueCache, path string, existingObjectId string, objectName string, theApi api.Api, payload []byte, apiToken string) (api.DynatraceEntity, error) {
 
-		if len(locationSlice) == 0 {
-			return api.DynatraceEntity{},
-				fmt.Errorf("location response header was empty for API %s (name: %s)", configType, objectName)
-		}
+	body := theApi.GetBehavior().UpdatePayload(payload, existingObjectId)
 
-		location := locationSlice[0]
+	resp, err := put(ctx, client, path, body, apiToken)
+	if err != nil {
+		return api.DynatraceEntity{}, err
+	}
 
-		// Some APIs prepend the environment URL. If available, trim it from the location
-		existingObjectId = strings.TrimPrefix(location, fullUrl)
-		existingObjectId = strings.TrimPrefix(existingObjectId, "/")
+	if !success(resp) {
+		return api.DynatraceEntity{}, fmt.Errorf("Failed to update DT object %s: %w", objectName, newApiError(resp, path))
+	}
 
-		dtEntity = api.DynatraceEntity{
-			Id:          existingObjectId,
-			Name:        objectName,
-			Description: "Created object",
-		}
+	util.Log.Debug("\t\t\tUpdated existing object for %s (%s)", objectName, existingObjectId)
+	cache.put(theApi, api.Value{Id: existingObjectId, Name: objectName})
 
-	} else {
-		err := json.Unmarshal(resp.Body, &dtEntity)
-		if util.CheckError(err, "Cannot unmarshal API response") {
-			return api.DynatraceEntity{}, err
-		}
+	return api.DynatraceEntity{
+		Id:          existingObjectId,
+		Name:        objectName,
+		Description: "Updated existing object",
+		StatusCode:  resp.StatusCode,
+	}, nil
+}
+
+func unchangedEntity(id string, objectName string) api.DynatraceEntity {
+	util.Log.Debug("\t\t\tSkipped update of unchanged object %s (%s)", objectName, id)
+	return api.DynatraceEntity{
+		Id:          id,
+		Name:        objectName,
+		Description: "Unchanged existing object",
+		Unchanged:   true,
 	}
-	util.Log.Debug("\t\t\tCreated new object for %s (%s)", dtEntity.Name, dtEntity.Id)
+}
 
-	return dtEntity, nil
+// isUnchanged checks whether the existing object is semantically equal to the payload.
+// Server-managed fields like the id are ignored.
+func isUnchanged(existingObject []byte, payload []byte) bool {
+
+	equal, err := util.JsonEquals(existingObject, payload, util.ServerManagedJsonKeys...)
+	if err != nil {
+		util.Log.Debug("\t\t\tFailed to compare existing object with payload: %s", err)
+		return false
+	}
+	return equal
 }
 
 func joinUrl(urlBase string, path string) string {
@@ -160,29 +151,44 @@ func joinUrl(urlBase string, path string) string {
 	return urlBase + "/" + path
 }
 
-func isLocationHeaderAvailable(resp Response) (headerAvailable bool, headerArray []string) {
-	if resp.Headers["Location"] != nil {
-		return true, resp.Headers["Location"]
+func deleteDynatraceObject(ctx context.Context, client *http.Client, cache *valueCache, api api.Api, name string, url string, token string) error {
+
+	existingId, err := getObjectIdIfAlreadyExists(ctx, client, cache, api, url, name, token)
+	if err != nil {
+		return err
 	}
-	return false, make([]string, 0)
+
+	if len(existingId) > 0 {
+		if err := deleteConfig(ctx, client, url, token, existingId); err != nil {
+			return fmt.Errorf("Failed to delete DT object %s (%s) of %s: %w", name, existingId, api.GetId(), err)
+		}
+		cache.remove(api, existingId)
+	}
+	return nil
 }
 
-func deleteDynatraceObject(client *http.Client, api api.Api, name string, url string, token string) error {
+func deleteDynatraceObjectById(ctx context.Context, client *http.Client, cache *valueCache, api api.Api, id string, url string, token string) error {
 
-	existingId, err := getObjectIdIfAlreadyExists(client, api, url, name, token)
+	req, err := request(ctx, http.MethodDelete, joinUrl(url, id), token)
 	if err != nil {
 		return err
 	}
 
-	if len(existingId) > 0 {
-		deleteConfig(client, url, token, existingId)
+	resp, err := executeRequest(client, req)
+	if err != nil {
+		return err
+	}
+	if !success(resp) && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("Failed to delete DT object %s of %s: %w", id, api.GetId(), newApiError(resp, req.URL.String()))
 	}
+
+	cache.remove(api, id)
 	return nil
 }
 
-func getObjectIdIfAlreadyExists(client *http.Client, api api.Api, url string, objectName string, apiToken string) (existingId string, err error) {
+func getObjectIdIfAlreadyExists(ctx context.Context, client *http.Client, cache *valueCache, theApi api.Api, url string, objectName string, apiToken string) (existingId string, err error) {
 
-	values, err := getExistingValuesFromEndpoint(client, api, url, apiToken)
+	values, err := listValues(ctx, client, cache, theApi, url, apiToken)
 	if err != nil {
 		return "", err
 	}
@@ -196,34 +202,43 @@ func getObjectIdIfAlreadyExists(client *http.Client, api api.Api, url string, ob
 	return "", nil
 }
 
-func isApiDashboard(api api.Api) bool {
-	return api.GetId() == "dashboard"
+// listValues returns the existing values of the API. They are only requested from the API, if they are not cached yet.
+func listValues(ctx context.Context, client *http.Client, cache *valueCache, theApi api.Api, url string, apiToken string) ([]api.Value, error) {
+	return cache.getOrLoad(theApi, func() ([]api.Value, error) {
+		return getExistingValuesFromEndpoint(ctx, client, theApi, url, apiToken)
+	})
 }
 
-func getExistingValuesFromEndpoint(client *http.Client, theApi api.Api, url string, apiToken string) (values []api.Value, err error) {
+func getExistingValuesFromEndpoint(ctx context.Context, client *http.Client, theApi api.Api, url string, apiToken string) (values []api.Value, err error) {
 
 	var existingValues []api.Value
-	resp, err := get(client, url, apiToken)
+	resp, err := get(ctx, client, url, apiToken)
 
 	if err != nil {
 		return nil, err
 	}
+	if !success(resp) {
+		return nil, fmt.Errorf("Failed to list existing objects of %s: %w", theApi.GetId(), newApiError(resp, url))
+	}
 
 	for {
 
-		err, values, objmap := unmarshalJson(theApi, err, resp)
+		values, err := theApi.GetBehavior().ParseList(theApi, resp.Body)
 		if err != nil {
 			return values, err
 		}
 		existingValues = append(existingValues, values...)
 
 		// Does the API support paging?
-		if isPaginated, nextPage := isPaginatedResponse(objmap); isPaginated {
-			resp, err = get(client, url+"?nextPageKey="+nextPage, apiToken)
+		if nextPage := nextPageKeyOf(resp.Body); nextPage != "" {
+			resp, err = get(ctx, client, url+"?nextPageKey="+nextPage, apiToken)
 
 			if err != nil {
 				return nil, err
 			}
+			if !success(resp) {
+				return nil, fmt.Errorf("Failed to list existing objects of %s: %w", theApi.GetId(), newApiError(resp, url))
+			}
 		} else {
 			break
 		}
@@ -232,138 +247,15 @@ func getExistingValuesFromEndpoint(client *http.Client, theApi api.Api, url stri
 	return existingValues, nil
 }
 
-func unmarshalJson(theApi api.Api, err error, resp Response) (error, []api.Value, map[string]interface{}) {
-
-	var values []api.Value
-	var objmap map[string]interface{}
-
-	// This API returns an untyped list as a response -> it needs a special handling
-	if theApi.GetId() == "aws-credentials" {
-
-		var jsonResp []api.Value
-		err := json.Unmarshal(resp.Body, &jsonResp)
-		if util.CheckError(err, "Cannot unmarshal API response for existing aws-credentials") {
-			return err, values, objmap
-		}
-		values = jsonResp
-
-	} else {
-
-		if err := json.Unmarshal(resp.Body, &objmap); err != nil {
-			return err, nil, nil
-		}
-
-		if theApi.GetId() == "synthetic-location" {
-
-			var jsonResp api.SyntheticLocationResponse
-			err = json.Unmarshal(resp.Body, &jsonResp)
-			if util.CheckError(err, "Cannot unmarshal API response for existing synthetic location") {
-				return err, nil, nil
-			}
-			values = translateSyntheticValues(jsonResp.Locations)
-
-		} else if theApi.GetId() == "synthetic-monitor" {
-
-			var jsonResp api.SyntheticMonitorsResponse
-			err = json.Unmarshal(resp.Body, &jsonResp)
-			if util.CheckError(err, "Cannot unmarshal API response for existing synthetic location") {
-				return err, nil, nil
-			}
-			values = translateSyntheticValues(jsonResp.Monitors)
-
-		} else if !theApi.IsStandardApi() {
-
-			if available, array := isResultArrayAvailable(objmap, theApi); available {
-				jsonResp, err := translateGenericValues(array, theApi.GetId())
-				if err != nil {
-					return err, nil, nil
-				}
-				values = jsonResp
-			}
-
-		} else {
-
-			var jsonResponse api.ValuesResponse
-			err = json.Unmarshal(resp.Body, &jsonResponse)
-			if util.CheckError(err, "Cannot unmarshal API response for existing objects") {
-				return err, nil, nil
-			}
-			values = jsonResponse.Values
-		}
-	}
-
-	return nil, values, objmap
-}
-
-func isResultArrayAvailable(jsonResponse map[string]interface{}, theApi api.Api) (resultArrayAvailable bool, results []interface{}) {
-	if jsonResponse[theApi.GetPropertyNameOfGetAllResponse()] != nil {
-		return true, jsonResponse[theApi.GetPropertyNameOfGetAllResponse()].([]interface{})
-	}
-	return false, make([]interface{}, 0)
-}
-
-func isPaginatedResponse(jsonResponse map[string]interface{}) (paginated bool, pageKey string) {
-	if jsonResponse["nextPageKey"] != nil {
-		return true, jsonResponse["nextPageKey"].(string)
+// nextPageKeyOf returns the key of the next page of a paginated list response, or an empty string
+func nextPageKeyOf(body []byte) string {
+	var page struct {
+		NextPageKey string `json:"nextPageKey"`
 	}
-	return false, ""
-}
-
-func translateGenericValues(inputValues []interface{}, configType string) ([]api.Value, error) {
-
-	numValues := len(inputValues)
-	values := make([]api.Value, numValues, numValues)
-
-	for i := 0; i < numValues; i++ {
-		input := inputValues[i].(map[string]interface{})
-
-		if input["id"] == nil {
-			return values, fmt.Errorf("config of type %s was invalid: No id", configType)
-		}
-
-		// repair invalid configs - but log them
-		if input["name"] == nil {
-			jsonStr, err := json.Marshal(input)
-			if err != nil {
-				util.Log.Warn("Config of type %s was invalid. Ignoring it!", configType)
-				continue
-			}
-
-			util.Log.Warn("Config of type %s was invalid. Auto-corrected to use ID as name!\nInvalid config: %s", configType, string(jsonStr))
-
-			values[i] = api.Value{
-				Id:   input["id"].(string),
-				Name: input["id"].(string), // use the id as name
-			}
-			continue
-		}
-
-		values[i] = api.Value{
-			Id:   input["id"].(string),
-			Name: input["name"].(string),
-		}
-	}
-	return values, nil
-}
-
-func translateSyntheticValues(syntheticValues []api.SyntheticValue) []api.Value {
-	numValues := len(syntheticValues)
-	values := make([]api.Value, numValues, numValues)
-	for i := 0; i < numValues; i++ {
-		loc := syntheticValues[i]
-		values[i] = api.Value{
-			Id:   loc.EntityId,
-			Name: loc.Name,
-		}
-	}
-	return values
-}
-
-func translateSyntheticEntityResponse(resp api.SyntheticEntity, objectName string) api.DynatraceEntity {
-	return api.DynatraceEntity{
-		Name: objectName,
-		Id:   resp.EntityId,
+	if err := json.Unmarshal(body, &page); err != nil {
+		return ""
 	}
+	return page.NextPageKey
 }
 
 func success(resp Response) bool {
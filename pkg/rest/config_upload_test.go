@@ -19,54 +19,176 @@
 package rest
 
 import (
-	"gotest.tools/assert"
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"gotest.tools/assert"
 )
 
-func TestTranslateGenericValuesOnStandardResponse(t *testing.T) {
+func newUpsertTestServer(t *testing.T, existingObject string, putCount *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config/v1/managementZones":
+			_, _ = w.Write([]byte(`{"values": [{"id": "zone-id", "name": "Zone"}]}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config/v1/managementZones/zone-id":
+			_, _ = w.Write([]byte(existingObject))
+		case r.Method == http.MethodPut && r.URL.Path == "/api/config/v1/managementZones/zone-id":
+			*putCount++
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
 
-	entry := make(map[string]interface{})
-	entry["id"] = "foo"
-	entry["name"] = "bar"
+func TestUpsertSkipsUpdateOfUnchangedObject(t *testing.T) {
+	putCount := 0
+	server := newUpsertTestServer(t, `{"metadata": {"clusterVersion": "1.0"}, "id": "zone-id", "rules": [], "name": "Zone"}`, &putCount)
+	defer server.Close()
 
-	response := make([]interface{}, 1)
-	response[0] = entry
+	zoneApi := api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+	url := zoneApi.GetUrlFromEnvironmentUrl(server.URL)
 
-	values, err := translateGenericValues(response, "extensions")
+	entity, err := upsertDynatraceObject(context.Background(), server.Client(), nil, url, "Zone", zoneApi, []byte(`{"name": "Zone", "rules": []}`), "token")
 
 	assert.NilError(t, err)
-	assert.Check(t, len(values) == 1)
+	assert.Equal(t, putCount, 0)
+	assert.Equal(t, entity.Id, "zone-id")
+	assert.Assert(t, entity.Unchanged)
+}
 
-	assert.Equal(t, values[0].Id, "foo")
-	assert.Equal(t, values[0].Name, "bar")
+func TestUpsertUpdatesChangedObject(t *testing.T) {
+	putCount := 0
+	server := newUpsertTestServer(t, `{"id": "zone-id", "rules": [{}], "name": "Zone"}`, &putCount)
+	defer server.Close()
+
+	zoneApi := api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+	url := zoneApi.GetUrlFromEnvironmentUrl(server.URL)
+
+	entity, err := upsertDynatraceObject(context.Background(), server.Client(), nil, url, "Zone", zoneApi, []byte(`{"name": "Zone", "rules": []}`), "token")
+
+	assert.NilError(t, err)
+	assert.Equal(t, putCount, 1)
+	assert.Equal(t, entity.Id, "zone-id")
+	assert.Assert(t, !entity.Unchanged)
 }
 
-func TestTranslateGenericValuesOnIdMissing(t *testing.T) {
+func TestUpsertByIdRenamesExistingObject(t *testing.T) {
+	putCount := 0
+	server := newUpsertTestServer(t, `{"id": "zone-id", "rules": [], "name": "Old Zone"}`, &putCount)
+	defer server.Close()
 
-	entry := make(map[string]interface{})
-	entry["name"] = "bar"
+	zoneApi := api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+	url := zoneApi.GetUrlFromEnvironmentUrl(server.URL)
 
-	response := make([]interface{}, 1)
-	response[0] = entry
+	entity, err := upsertDynatraceObjectById(context.Background(), server.Client(), nil, url, "zone-id", "New Zone", zoneApi, []byte(`{"name": "New Zone", "rules": []}`), "token")
 
-	_, err := translateGenericValues(response, "extensions")
+	assert.NilError(t, err)
+	assert.Equal(t, putCount, 1)
+	assert.Equal(t, entity.Id, "zone-id")
+	assert.Equal(t, entity.Name, "New Zone")
+}
+
+func TestUpsertByIdFallsBackToNameOfDeletedObject(t *testing.T) {
+	putCount := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config/v1/managementZones/deleted-id":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config/v1/managementZones":
+			_, _ = w.Write([]byte(`{"values": [{"id": "zone-id", "name": "Zone"}]}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config/v1/managementZones/zone-id":
+			_, _ = w.Write([]byte(`{"id": "zone-id", "rules": [], "name": "Zone"}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/api/config/v1/managementZones/zone-id":
+			putCount++
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	zoneApi := api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+	url := zoneApi.GetUrlFromEnvironmentUrl(server.URL)
+
+	entity, err := upsertDynatraceObjectById(context.Background(), server.Client(), nil, url, "deleted-id", "Zone", zoneApi, []byte(`{"name": "Zone", "rules": [{}]}`), "token")
 
-	assert.ErrorContains(t, err, "config of type extensions was invalid: No id")
+	assert.NilError(t, err)
+	assert.Equal(t, putCount, 1)
+	assert.Equal(t, entity.Id, "zone-id")
 }
 
-func TestTranslateGenericValuesOnNameMissing(t *testing.T) {
+func TestUpsertFailsIfContextIsDone(t *testing.T) {
+	putCount := 0
+	server := newUpsertTestServer(t, `{"id": "zone-id", "rules": [{}], "name": "Zone"}`, &putCount)
+	defer server.Close()
 
-	entry := make(map[string]interface{})
-	entry["id"] = "foo"
+	zoneApi := api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+	url := zoneApi.GetUrlFromEnvironmentUrl(server.URL)
 
-	response := make([]interface{}, 1)
-	response[0] = entry
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-	values, err := translateGenericValues(response, "extensions")
+	_, err := upsertDynatraceObject(ctx, server.Client(), nil, url, "Zone", zoneApi, []byte(`{"name": "Zone", "rules": []}`), "token")
+
+	assert.ErrorContains(t, err, context.Canceled.Error())
+	assert.Equal(t, putCount, 0)
+}
+
+func TestUpsertCreatesLogMetricByPut(t *testing.T) {
+	putCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config/v1/calculatedMetrics/log":
+			_, _ = w.Write([]byte(`{"values": []}`))
+		case r.Method == http.MethodPut && r.URL.Path == "/api/config/v1/calculatedMetrics/log/log.metric":
+			putCount++
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	logMetricApi := api.NewStandardApi("calculated-metrics-log", "/api/config/v1/calculatedMetrics/log")
+	url := logMetricApi.GetUrlFromEnvironmentUrl(server.URL)
+
+	entity, err := upsertDynatraceObject(context.Background(), server.Client(), nil, url, "log.metric", logMetricApi, []byte(`{"metricKey": "log.metric"}`), "token")
 
 	assert.NilError(t, err)
-	assert.Check(t, len(values) == 1)
+	assert.Equal(t, putCount, 1)
+	assert.Equal(t, entity.Id, "log.metric")
+	assert.Assert(t, entity.Created)
+}
 
-	assert.Equal(t, values[0].Id, "foo")
-	assert.Equal(t, values[0].Name, "foo")
+func TestUpsertPrependsNewAppDetectionRule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config/v1/applicationDetectionRules":
+			_, _ = w.Write([]byte(`{"values": []}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/config/v1/applicationDetectionRules":
+			assert.Equal(t, r.URL.Query().Get("position"), "PREPEND")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": "rule-id", "name": "Rule"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ruleApi := api.NewStandardApi("app-detection-rule", "/api/config/v1/applicationDetectionRules")
+	url := ruleApi.GetUrlFromEnvironmentUrl(server.URL)
+
+	entity, err := upsertDynatraceObject(context.Background(), server.Client(), nil, url, "Rule", ruleApi, []byte(`{"name": "Rule"}`), "token")
+
+	assert.NilError(t, err)
+	assert.Equal(t, entity.Id, "rule-id")
+	assert.Assert(t, entity.Created)
 }
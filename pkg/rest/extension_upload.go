@@ -19,6 +19,7 @@ package rest
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"mime/multipart"
 	"net/http"
 	"time"
@@ -27,7 +28,7 @@ import (
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
 )
 
-func uploadExtension(client *http.Client, apiPath string, extensionName string, payload []byte, apiToken string) (api.DynatraceEntity, error) {
+func uploadExtension(ctx context.Context, client *http.Client, apiPath string, extensionName string, payload []byte, apiToken string) (api.DynatraceEntity, error) {
 	buffer, contentType, err := writeMultiPartForm(extensionName, payload)
 	if err != nil {
 		return api.DynatraceEntity{
@@ -35,7 +36,7 @@ func uploadExtension(client *http.Client, apiPath string, extensionName string,
 		}, err
 	}
 
-	resp, err := postMultiPartFile(client, apiPath, buffer, contentType, apiToken)
+	resp, err := postMultiPartFile(ctx, client, apiPath, buffer, contentType, apiToken)
 
 	if err != nil {
 		return api.DynatraceEntity{}, err
@@ -47,11 +48,14 @@ func uploadExtension(client *http.Client, apiPath string, extensionName string,
 		util.Log.Debug("\t\t\tExtension upload successful for %s", extensionName)
 
 		// As other configs depend on metrics created by extensions, and metric creation seems to happen with delay...
-		time.Sleep(1 * time.Second)
+		if err := util.NewTimelineProvider().Sleep(ctx, 1*time.Second); err != nil {
+			return api.DynatraceEntity{}, err
+		}
 	}
 
 	return api.DynatraceEntity{
-		Name: extensionName,
+		Name:       extensionName,
+		StatusCode: resp.StatusCode,
 	}, nil
 
 }
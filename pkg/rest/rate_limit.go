@@ -17,33 +17,106 @@
 package rest
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 )
 
 // rateLimitStrategy ensures that the concrete implementation of the rate limiting strategy can be hidden
-// behind this interface
+// behind this interface. Implementations stop waiting and return the error of the context once it is done.
+// They log with the logger of the client sending the request.
 type rateLimitStrategy interface {
-	executeRequest(timelineProvider util.TimelineProvider, callback func() (Response, error)) (Response, error)
+	executeRequest(ctx context.Context, timelineProvider util.TimelineProvider, log util.PrefixedLogger, callback func() (Response, error)) (Response, error)
 }
 
-// createRateLimitStrategy creates a rateLimitStrategy. In the future this can be extended to instantiate
-// different rate limiting strategies based on e.g. environment variables. The current implementation
-// always returns the strategy simpleSleepRateLimitStrategy, which suspends the current goroutine until
+const (
+	simpleSleepRateLimitStrategyName = "simple"
+	tokenBucketRateLimitStrategyName = "token-bucket"
+)
+
+// createRateLimitStrategy creates the rateLimitStrategy with the given name for requests to the given environment:
+// simple (default) returns the strategy simpleSleepRateLimitStrategy, which suspends the current goroutine until
 // the time in the rate limiting header 'X-RateLimit-Reset' is up.
-func createRateLimitStrategy() rateLimitStrategy {
+// token-bucket returns the tokenBucketRateLimitStrategy of the environment, which is shared by all requests to it.
+// It sends at most rateLimit requests per minute, if rateLimit is positive.
+func createRateLimitStrategy(name string, environment string, rateLimit int) rateLimitStrategy {
+	if name == tokenBucketRateLimitStrategyName {
+		return sharedTokenBucketRateLimitStrategy(environment, float64(rateLimit))
+	}
 	return &simpleSleepRateLimitStrategy{}
 }
 
+// rateLimitStrategyOf returns the rateLimitStrategy of the client for requests to the given environment. Clients not
+// created by NewDynatraceClient use the strategy of the environment variable MONACO_RATE_LIMIT_STRATEGY.
+func rateLimitStrategyOf(client *http.Client, environment string) rateLimitStrategy {
+	if transport, ok := client.Transport.(*retryTransport); ok && transport.rateLimitStrategy != "" {
+		return createRateLimitStrategy(transport.rateLimitStrategy, environment, transport.rateLimit)
+	}
+	return createRateLimitStrategy(os.Getenv("MONACO_RATE_LIMIT_STRATEGY"), environment, 0)
+}
+
+// validateRateLimitStrategy fails if the given name is not the name of a rate limit strategy
+func validateRateLimitStrategy(name string) error {
+	switch name {
+	case simpleSleepRateLimitStrategyName, tokenBucketRateLimitStrategyName:
+		return nil
+	default:
+		return fmt.Errorf("rate limit strategy must be %s or %s, but was %s",
+			simpleSleepRateLimitStrategyName, tokenBucketRateLimitStrategyName, name)
+	}
+}
+
+// rateLimitStrategyFromEnvironment returns the name of the strategy selected by the environment variable
+// MONACO_RATE_LIMIT_STRATEGY, which defaults to simple. It fails if the variable names an unknown strategy.
+func rateLimitStrategyFromEnvironment() (string, error) {
+	switch name := os.Getenv("MONACO_RATE_LIMIT_STRATEGY"); name {
+	case "":
+		return simpleSleepRateLimitStrategyName, nil
+	case simpleSleepRateLimitStrategyName, tokenBucketRateLimitStrategyName:
+		return name, nil
+	default:
+		return "", fmt.Errorf("MONACO_RATE_LIMIT_STRATEGY must be %s or %s, but was %s",
+			simpleSleepRateLimitStrategyName, tokenBucketRateLimitStrategyName, name)
+	}
+}
+
+// environmentOf returns the environment a request url belongs to, i.e. the host and, for Dynatrace Managed,
+// the environment path /e/<environment-id>
+func environmentOf(requestUrl *url.URL) string {
+	environment := requestUrl.Scheme + "://" + requestUrl.Host
+	if parts := strings.SplitN(requestUrl.Path, "/", 4); len(parts) >= 3 && parts[1] == "e" {
+		environment += "/e/" + parts[2]
+	}
+	return environment
+}
+
+// rateLimitHeader returns the first value of the given rate limiting header, or an empty string. Headers of
+// received responses are canonicalized (X-Ratelimit-Limit), so both spellings are looked up.
+func rateLimitHeader(response Response, name string) string {
+	values := response.Headers[name]
+	if values == nil {
+		values = response.Headers[http.CanonicalHeaderKey(name)]
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
 // simpleSleepRateLimitStrategy, is a rate limiting strategy which suspends the current goroutine until
 // the time in the rate limiting header 'X-RateLimit-Reset' is up.
 // It has a min sleep duration of 5 seconds and a max sleep duration of one minute and performs maximal 5
-// polling iterations before giving up.
+// polling iterations before giving up with a RateLimitExhaustedError. If the rate limiting headers are missing,
+// the response is returned as is.
 type simpleSleepRateLimitStrategy struct{}
 
-func (s *simpleSleepRateLimitStrategy) executeRequest(timelineProvider util.TimelineProvider, callback func() (Response, error)) (Response, error) {
+func (s *simpleSleepRateLimitStrategy) executeRequest(ctx context.Context, timelineProvider util.TimelineProvider, log util.PrefixedLogger, callback func() (Response, error)) (Response, error) {
 
 	response, err := callback()
 	if err != nil {
@@ -57,11 +130,12 @@ func (s *simpleSleepRateLimitStrategy) executeRequest(timelineProvider util.Time
 
 		limit, humanReadableTimestamp, timeInMicroseconds, err := s.extractRateLimitHeaders(response)
 		if err != nil {
-			return response, err
+			log.Warn("Rate limit reached, but the response can't be used to wait for its reset: %s", err)
+			return response, nil
 		}
 
-		util.Log.Info("Rate limit of %d requests/min reached: Applying rate limit strategy (simpleSleepRateLimitStrategy, iteration: %d)", limit, currentIteration+1)
-		util.Log.Info("simpleSleepRateLimitStrategy: Attempting to sleep until %s", humanReadableTimestamp)
+		log.Info("Rate limit of %d requests/min reached: Applying rate limit strategy (simpleSleepRateLimitStrategy, iteration: %d)", limit, currentIteration+1)
+		log.Info("simpleSleepRateLimitStrategy: Attempting to sleep until %s", humanReadableTimestamp)
 
 		// Attention: this uses client time:
 		now := timelineProvider.Now()
@@ -71,14 +145,16 @@ func (s *simpleSleepRateLimitStrategy) executeRequest(timelineProvider util.Time
 
 		// Attention: this mixes client and server time:
 		sleepDuration := resetTime.Sub(now)
-		util.Log.Debug("simpleSleepRateLimitStrategy: Calculated sleep duration of %f seconds...", sleepDuration.Seconds())
+		log.Debug("simpleSleepRateLimitStrategy: Calculated sleep duration of %f seconds...", sleepDuration.Seconds())
 
 		// That's why we need plausible min/max wait time defaults:
-		sleepDuration = s.applyMinMaxDefaults(sleepDuration)
+		sleepDuration = s.applyMinMaxDefaults(log, sleepDuration)
 
-		util.Log.Debug("simpleSleepRateLimitStrategy: Sleeping for %f seconds...", sleepDuration.Seconds())
-		timelineProvider.Sleep(sleepDuration)
-		util.Log.Debug("simpleSleepRateLimitStrategy: Slept for %f seconds", sleepDuration.Seconds())
+		log.Debug("simpleSleepRateLimitStrategy: Sleeping for %f seconds...", sleepDuration.Seconds())
+		if err := timelineProvider.Sleep(ctx, sleepDuration); err != nil {
+			return Response{}, err
+		}
+		log.Debug("simpleSleepRateLimitStrategy: Slept for %f seconds", sleepDuration.Seconds())
 
 		// Checking again:
 		currentIteration++
@@ -89,23 +165,25 @@ func (s *simpleSleepRateLimitStrategy) executeRequest(timelineProvider util.Time
 		}
 	}
 
+	if response.StatusCode == http.StatusTooManyRequests {
+		return Response{}, RateLimitExhaustedError{Attempts: currentIteration + 1}
+	}
 	return response, nil
 }
 
 func (s *simpleSleepRateLimitStrategy) extractRateLimitHeaders(response Response) (limit string, humanReadableResetTimestamp string, resetTimeInMicroseconds int64, err error) {
 
-	limitAsArray := response.Headers["X-RateLimit-Limit"]
-	resetAsArray := response.Headers["X-RateLimit-Reset"]
+	limit = rateLimitHeader(response, "X-RateLimit-Limit")
+	resetTimestamp := rateLimitHeader(response, "X-RateLimit-Reset")
 
-	if limitAsArray == nil || limitAsArray[0] == "" {
+	if limit == "" {
 		return "", "", 0, errors.New("rate limit header 'X-RateLimit-Limit' not found")
 	}
-	if resetAsArray == nil || resetAsArray[0] == "" {
+	if resetTimestamp == "" {
 		return "", "", 0, errors.New("rate limit header 'X-RateLimit-Reset' not found")
 	}
 
-	limit = limitAsArray[0]
-	humanReadableResetTimestamp, resetTimeInMicroseconds, err = util.StringTimestampToHumanReadableFormat(resetAsArray[0])
+	humanReadableResetTimestamp, resetTimeInMicroseconds, err = util.StringTimestampToHumanReadableFormat(resetTimestamp)
 	if err != nil {
 		return "", "", 0, err
 	}
@@ -113,18 +191,18 @@ func (s *simpleSleepRateLimitStrategy) extractRateLimitHeaders(response Response
 	return limit, humanReadableResetTimestamp, resetTimeInMicroseconds, nil
 }
 
-func (s *simpleSleepRateLimitStrategy) applyMinMaxDefaults(sleepDuration time.Duration) time.Duration {
+func (s *simpleSleepRateLimitStrategy) applyMinMaxDefaults(log util.PrefixedLogger, sleepDuration time.Duration) time.Duration {
 
 	minWaitTimeInNanoseconds := 5 * time.Second
 	maxWaitTimeInNanoseconds := 1 * time.Minute
 
 	if sleepDuration.Nanoseconds() < minWaitTimeInNanoseconds.Nanoseconds() {
 		sleepDuration = minWaitTimeInNanoseconds
-		util.Log.Debug("simpleSleepRateLimitStrategy: Reset sleep duration to %f seconds...", sleepDuration.Seconds())
+		log.Debug("simpleSleepRateLimitStrategy: Reset sleep duration to %f seconds...", sleepDuration.Seconds())
 	}
 	if sleepDuration.Nanoseconds() > maxWaitTimeInNanoseconds.Nanoseconds() {
 		sleepDuration = maxWaitTimeInNanoseconds
-		util.Log.Debug("simpleSleepRateLimitStrategy: Reset sleep duration to %f seconds...", sleepDuration.Seconds())
+		log.Debug("simpleSleepRateLimitStrategy: Reset sleep duration to %f seconds...", sleepDuration.Seconds())
 	}
 	return sleepDuration
 }
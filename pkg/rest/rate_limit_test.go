@@ -19,6 +19,7 @@
 package rest
 
 import (
+	"context"
 	"errors"
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
 	"github.com/golang/mock/gomock"
@@ -53,9 +54,9 @@ func TestDurationStaysTheSameIfInputIsWithinMinMaxLimits(t *testing.T) {
 
 	rateLimitStrategy := simpleSleepRateLimitStrategy{}
 
-	value := rateLimitStrategy.applyMinMaxDefaults(6 * time.Second)
+	value := rateLimitStrategy.applyMinMaxDefaults(util.PrefixedLogger{}, 6 * time.Second)
 	assert.Equal(t, 6, int(value.Seconds()))
-	value = rateLimitStrategy.applyMinMaxDefaults(59 * time.Second)
+	value = rateLimitStrategy.applyMinMaxDefaults(util.PrefixedLogger{}, 59 * time.Second)
 	assert.Equal(t, 59, int(value.Seconds()))
 }
 
@@ -63,9 +64,9 @@ func TestDurationWillBeTheMinimumIfInputIsSmallerThanMinLimit(t *testing.T) {
 
 	rateLimitStrategy := simpleSleepRateLimitStrategy{}
 
-	value := rateLimitStrategy.applyMinMaxDefaults(4 * time.Second)
+	value := rateLimitStrategy.applyMinMaxDefaults(util.PrefixedLogger{}, 4 * time.Second)
 	assert.Equal(t, 5, int(value.Seconds()))
-	value = rateLimitStrategy.applyMinMaxDefaults(-19 * time.Second)
+	value = rateLimitStrategy.applyMinMaxDefaults(util.PrefixedLogger{}, -19 * time.Second)
 	assert.Equal(t, 5, int(value.Seconds()))
 }
 
@@ -73,9 +74,9 @@ func TestDurationWillBeTheMaximumIfInputIsLargerThanMaxLimit(t *testing.T) {
 
 	rateLimitStrategy := simpleSleepRateLimitStrategy{}
 
-	value := rateLimitStrategy.applyMinMaxDefaults(61 * time.Second)
+	value := rateLimitStrategy.applyMinMaxDefaults(util.PrefixedLogger{}, 61 * time.Second)
 	assert.Equal(t, 60, int(value.Seconds()))
-	value = rateLimitStrategy.applyMinMaxDefaults(3600 * time.Second)
+	value = rateLimitStrategy.applyMinMaxDefaults(util.PrefixedLogger{}, 3600 * time.Second)
 	assert.Equal(t, 60, int(value.Seconds()))
 }
 
@@ -142,9 +143,9 @@ func TestSimpleRateLimitStrategySleepsFor42Seconds(t *testing.T) {
 	}
 
 	timelineProvider.EXPECT().Now().Times(1).Return(time.Unix(0, 0)) // time travel to the 70s
-	timelineProvider.EXPECT().Sleep(42 * time.Second).Times(1)
+	timelineProvider.EXPECT().Sleep(gomock.Any(), 42*time.Second).Times(1)
 
-	response, err := rateLimitStrategy.executeRequest(timelineProvider, callback)
+	response, err := rateLimitStrategy.executeRequest(context.Background(), timelineProvider, util.PrefixedLogger{}, callback)
 
 	assert.NilError(t, err)
 	assert.Equal(t, response.StatusCode, 200)
@@ -172,9 +173,9 @@ func TestSimpleRateLimitStrategy2Iterations(t *testing.T) {
 	}
 
 	timelineProvider.EXPECT().Now().Times(2).Return(time.Unix(0, 0)) // time travel to the 70s
-	timelineProvider.EXPECT().Sleep(42 * time.Second).Times(2)
+	timelineProvider.EXPECT().Sleep(gomock.Any(), 42*time.Second).Times(2)
 
-	response, err := rateLimitStrategy.executeRequest(timelineProvider, callback)
+	response, err := rateLimitStrategy.executeRequest(context.Background(), timelineProvider, util.PrefixedLogger{}, callback)
 
 	assert.NilError(t, err)
 	assert.Equal(t, response.StatusCode, 200)
@@ -188,6 +189,54 @@ func TestHandleEmptyResponse(t *testing.T) {
 		return Response{}, errors.New("foo Error")
 	}
 
-	_, err := rateLimitStrategy.executeRequest(timelineProvider, callback)
+	_, err := rateLimitStrategy.executeRequest(context.Background(), timelineProvider, util.PrefixedLogger{}, callback)
 	assert.ErrorContains(t, err, "foo Error")
 }
+
+func TestSimpleRateLimitStrategyStopsWaitingWhenContextIsDone(t *testing.T) {
+
+	rateLimitStrategy := simpleSleepRateLimitStrategy{}
+	timelineProvider := createTimelineProviderMock(t)
+	headers := createTestHeaders(42 * time.Second.Microseconds()) // in 42 seconds
+	invocationCount := 0
+	callback := func() (Response, error) {
+		invocationCount++
+		return Response{
+			StatusCode: 429,
+			Headers:    headers,
+		}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	timelineProvider.EXPECT().Now().Times(1).Return(time.Unix(0, 0))
+	timelineProvider.EXPECT().Sleep(ctx, 42*time.Second).Times(1).Return(context.Canceled)
+
+	_, err := rateLimitStrategy.executeRequest(ctx, timelineProvider, util.PrefixedLogger{}, callback)
+
+	assert.ErrorContains(t, err, context.Canceled.Error())
+	assert.Equal(t, invocationCount, 1)
+}
+
+func TestSimpleRateLimitStrategyGivesUpAfterMaxIterations(t *testing.T) {
+
+	rateLimitStrategy := simpleSleepRateLimitStrategy{}
+	timelineProvider := createTimelineProviderMock(t)
+	headers := createTestHeaders(42 * time.Second.Microseconds()) // in 42 seconds
+	callback := func() (Response, error) {
+		return Response{
+			StatusCode: 429,
+			Headers:    headers,
+		}, nil
+	}
+
+	timelineProvider.EXPECT().Now().Times(5).Return(time.Unix(0, 0))
+	timelineProvider.EXPECT().Sleep(gomock.Any(), 42*time.Second).Times(5)
+
+	_, err := rateLimitStrategy.executeRequest(context.Background(), timelineProvider, util.PrefixedLogger{}, callback)
+
+	var rateLimitError RateLimitExhaustedError
+	assert.Assert(t, errors.As(err, &rateLimitError))
+	assert.Equal(t, rateLimitError.Attempts, 6)
+}
@@ -0,0 +1,70 @@
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rest
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+)
+
+// readOnlyClient only performs GET requests. All operations which would change the environment fail.
+type readOnlyClient struct {
+	client DynatraceClient
+}
+
+// NewReadOnlyDynatraceClient wraps the given client, so that it can only be used to read from the environment
+func NewReadOnlyDynatraceClient(client DynatraceClient) DynatraceClient {
+	return &readOnlyClient{client: client}
+}
+
+func (r *readOnlyClient) List(ctx context.Context, a Api) (values []Value, err error) {
+	return r.client.List(ctx, a)
+}
+
+func (r *readOnlyClient) ReadByName(ctx context.Context, a Api, name string) (json []byte, err error) {
+	return r.client.ReadByName(ctx, a, name)
+}
+
+func (r *readOnlyClient) ReadById(ctx context.Context, a Api, id string) (json []byte, err error) {
+	return r.client.ReadById(ctx, a, id)
+}
+
+func (r *readOnlyClient) ExistsByName(ctx context.Context, a Api, name string) (exists bool, id string, err error) {
+	return r.client.ExistsByName(ctx, a, name)
+}
+
+func (r *readOnlyClient) UpsertByName(ctx context.Context, a Api, name string, payload []byte) (entity DynatraceEntity, err error) {
+	return entity, readOnlyError("upsert", a, name)
+}
+
+func (r *readOnlyClient) UpsertById(ctx context.Context, a Api, id string, name string, payload []byte) (entity DynatraceEntity, err error) {
+	return entity, readOnlyError("upsert", a, name)
+}
+
+func (r *readOnlyClient) DeleteByName(ctx context.Context, a Api, name string) error {
+	return readOnlyError("delete", a, name)
+}
+
+func (r *readOnlyClient) DeleteById(ctx context.Context, a Api, id string) error {
+	return readOnlyError("delete", a, id)
+}
+
+func readOnlyError(operation string, a Api, object string) error {
+	return fmt.Errorf("can't %s %s %s, the client is read-only", operation, a.GetId(), object)
+}
@@ -0,0 +1,59 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestReadOnlyClientOnlySendsGetRequests(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			t.Errorf("unexpected %s request to %s", req.Method, req.URL)
+		}
+		rw.Write([]byte(`{"values": [{"id": "zone-id", "name": "Zone"}]}`))
+	}))
+	defer server.Close()
+
+	client := NewReadOnlyDynatraceClient(&dynatraceClientImpl{
+		environmentUrl: server.URL,
+		token:          "token",
+		client:         server.Client(),
+	})
+
+	exists, id, err := client.ExistsByName(context.Background(), cacheTestApi, "Zone")
+	assert.NilError(t, err)
+	assert.Assert(t, exists)
+	assert.Equal(t, id, "zone-id")
+
+	_, err = client.UpsertByName(context.Background(), cacheTestApi, "Zone", []byte(`{"name": "Zone"}`))
+	assert.ErrorContains(t, err, "can't upsert management-zone Zone, the client is read-only")
+
+	_, err = client.UpsertById(context.Background(), cacheTestApi, "zone-id", "Zone", []byte(`{"name": "Zone"}`))
+	assert.ErrorContains(t, err, "read-only")
+
+	err = client.DeleteByName(context.Background(), cacheTestApi, "Zone")
+	assert.ErrorContains(t, err, "can't delete management-zone Zone, the client is read-only")
+
+	err = client.DeleteById(context.Background(), cacheTestApi, "zone-id")
+	assert.ErrorContains(t, err, "read-only")
+}
@@ -18,9 +18,13 @@ package rest
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"runtime"
 
 	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
@@ -34,41 +38,87 @@ type Response struct {
 	Headers    map[string][]string
 }
 
-func get(client *http.Client, url string, apiToken string) (Response, error) {
-	req, err := request(http.MethodGet, url, apiToken)
+// ApiError is returned if a Dynatrace API responded with an unsuccessful status code
+type ApiError struct {
+	StatusCode int
+	Body       string
+	Url        string
+}
 
-	if err != nil {
-		return Response{}, err
+func (e ApiError) Error() string {
+	return fmt.Sprintf("HTTP %d from %s\n    Response was: %s", e.StatusCode, e.Url, e.Body)
+}
+
+func newApiError(resp Response, url string) ApiError {
+	return ApiError{
+		StatusCode: resp.StatusCode,
+		Body:       string(resp.Body),
+		Url:        url,
 	}
+}
 
-	return executeRequest(client, req), nil
+// TransportError is returned if no response has been received for a request, e.g. because the connection has been
+// reset or timed out. Transient errors have already been retried (see RetryPolicy).
+type TransportError struct {
+	Method string
+	Url    string
+	Err    error
 }
 
-// the name delete() would collide with the built-in function
-func deleteConfig(client *http.Client, url string, apiToken string, id string) error {
-	req, err := request(http.MethodDelete, url+"/"+id, apiToken)
+func (e TransportError) Error() string {
+	return fmt.Sprintf("%s %s failed: %s", e.Method, e.Url, e.Err)
+}
 
-	if err != nil {
-		return err
-	}
+func (e TransportError) Unwrap() error {
+	return e.Err
+}
 
-	executeRequest(client, req)
+// RateLimitExhaustedError is returned if the rate limit of the environment is still exceeded after waiting for
+// it to reset several times
+type RateLimitExhaustedError struct {
+	Url      string
+	Attempts int
+}
 
-	return nil
+func (e RateLimitExhaustedError) Error() string {
+	return fmt.Sprintf("rate limit still exceeded after %d attempts to request %s", e.Attempts, e.Url)
 }
 
-func post(client *http.Client, url string, data []byte, apiToken string) (Response, error) {
-	req, err := requestWithBody(http.MethodPost, url, bytes.NewBuffer(data), apiToken)
+func get(ctx context.Context, client *http.Client, url string, apiToken string) (Response, error) {
+	req, err := request(ctx, http.MethodGet, url, apiToken)
 
 	if err != nil {
 		return Response{}, err
 	}
 
-	return executeRequest(client, req), nil
+	return executeRequest(client, req)
+}
+
+// the name delete() would collide with the built-in function. A config which doesn't exist anymore is considered
+// to be deleted, all other unsuccessful responses are returned as ApiError.
+func deleteConfig(ctx context.Context, client *http.Client, url string, apiToken string, id string) error {
+	req, err := request(ctx, http.MethodDelete, url+"/"+id, apiToken)
+
+	if err != nil {
+		return err
+	}
+
+	resp, err := executeRequest(client, req)
+	if err != nil {
+		return err
+	}
+	if (resp.StatusCode < 200 || resp.StatusCode > 299) && resp.StatusCode != http.StatusNotFound {
+		return newApiError(resp, req.URL.String())
+	}
+	return nil
+}
+
+func post(ctx context.Context, client *http.Client, url string, data []byte, apiToken string) (Response, error) {
+	return send(ctx, client, http.MethodPost, url, data, apiToken)
 }
 
-func postMultiPartFile(client *http.Client, url string, data *bytes.Buffer, contentType string, apiToken string) (Response, error) {
-	req, err := requestWithBody(http.MethodPost, url, data, apiToken)
+func postMultiPartFile(ctx context.Context, client *http.Client, url string, data *bytes.Buffer, contentType string, apiToken string) (Response, error) {
+	req, err := requestWithBody(ctx, http.MethodPost, url, data, apiToken)
 
 	if err != nil {
 		return Response{}, err
@@ -76,25 +126,30 @@ func postMultiPartFile(client *http.Client, url string, data *bytes.Buffer, cont
 
 	req.Header.Set("Content-type", contentType)
 
-	return executeRequest(client, req), nil
+	return executeRequest(client, req)
+}
+
+func put(ctx context.Context, client *http.Client, url string, data []byte, apiToken string) (Response, error) {
+	return send(ctx, client, http.MethodPut, url, data, apiToken)
 }
 
-func put(client *http.Client, url string, data []byte, apiToken string) (Response, error) {
-	req, err := requestWithBody(http.MethodPut, url, bytes.NewBuffer(data), apiToken)
+// send sends the data with the given method, e.g. to create an object by POST or PUT
+func send(ctx context.Context, client *http.Client, method string, url string, data []byte, apiToken string) (Response, error) {
+	req, err := requestWithBody(ctx, method, url, bytes.NewBuffer(data), apiToken)
 
 	if err != nil {
 		return Response{}, err
 	}
 
-	return executeRequest(client, req), nil
+	return executeRequest(client, req)
 }
 
-func request(method string, url string, apiToken string) (*http.Request, error) {
-	return requestWithBody(method, url, nil, apiToken)
+func request(ctx context.Context, method string, url string, apiToken string) (*http.Request, error) {
+	return requestWithBody(ctx, method, url, nil, apiToken)
 }
 
-func requestWithBody(method string, url string, body io.Reader, apiToken string) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, body)
+func requestWithBody(ctx context.Context, method string, url string, body io.Reader, apiToken string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 
 	if err != nil {
 		return nil, err
@@ -106,7 +161,10 @@ func requestWithBody(method string, url string, body io.Reader, apiToken string)
 	return req, nil
 }
 
-func executeRequest(client *http.Client, request *http.Request) Response {
+// executeRequest sends the request, applying the rate limit strategy. If no response has been received, a
+// TransportError, RateLimitExhaustedError or the error of the done context is returned. Unsuccessful responses
+// are no errors, callers have to check the status code (see ApiError).
+func executeRequest(client *http.Client, request *http.Request) (Response, error) {
 	var requestId string
 	if util.IsRequestLoggingActive() {
 		requestId = uuid.NewString()
@@ -117,12 +175,11 @@ func executeRequest(client *http.Client, request *http.Request) Response {
 		}
 	}
 
-	rateLimitStrategy := createRateLimitStrategy()
+	rateLimitStrategy := rateLimitStrategyOf(client, environmentOf(request.URL))
 
-	response, err := rateLimitStrategy.executeRequest(util.NewTimelineProvider(), func() (Response, error) {
+	response, err := rateLimitStrategy.executeRequest(request.Context(), util.NewTimelineProvider(), loggerOf(client), func() (Response, error) {
 		resp, err := client.Do(request)
 		if err != nil {
-			util.Log.Error("HTTP Request failed with Error: " + err.Error())
 			return Response{}, err
 		}
 		defer func() {
@@ -150,8 +207,26 @@ func executeRequest(client *http.Client, request *http.Request) Response {
 	})
 
 	if err != nil {
-		// TODO properly handle error
-		return Response{}
+		if request.Context().Err() != nil {
+			return Response{}, request.Context().Err()
+		}
+
+		var rateLimitError RateLimitExhaustedError
+		if errors.As(err, &rateLimitError) {
+			rateLimitError.Url = request.URL.String()
+			return Response{}, rateLimitError
+		}
+
+		// client.Do wraps errors in an url.Error, which repeats method and url
+		var urlError *url.Error
+		if errors.As(err, &urlError) {
+			err = urlError.Err
+		}
+		return Response{}, TransportError{
+			Method: request.Method,
+			Url:    request.URL.String(),
+			Err:    err,
+		}
 	}
-	return response
+	return response, nil
 }
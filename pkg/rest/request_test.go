@@ -0,0 +1,143 @@
+// +build unit
+
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"gotest.tools/assert"
+)
+
+func TestExecuteRequestReturnsTransportErrorIfServerIsNotReachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	_, err := get(context.Background(), &http.Client{}, url, "token")
+
+	var transportError TransportError
+	assert.Assert(t, errors.As(err, &transportError), "expected a TransportError, but got %v", err)
+	assert.Equal(t, transportError.Method, http.MethodGet)
+	assert.Equal(t, transportError.Url, url)
+}
+
+func TestExecuteRequestReturnsContextErrorIfContextIsDone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := get(ctx, &http.Client{}, server.URL, "token")
+	assert.Equal(t, err, context.Canceled)
+}
+
+func TestUpsertReturnsApiErrorOnUnsuccessfulResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"values": []}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error": "invalid rules"}`))
+	}))
+	defer server.Close()
+
+	zoneApi := api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+	url := zoneApi.GetUrlFromEnvironmentUrl(server.URL)
+
+	_, err := upsertDynatraceObject(context.Background(), server.Client(), nil, url, "Zone", zoneApi, []byte(`{}`), "token")
+	assert.ErrorContains(t, err, "Failed to create DT object Zone: HTTP 400")
+
+	var apiError ApiError
+	assert.Assert(t, errors.As(err, &apiError), "expected an ApiError, but got %v", err)
+	assert.Equal(t, apiError.StatusCode, http.StatusBadRequest)
+	assert.Equal(t, apiError.Body, `{"error": "invalid rules"}`)
+	assert.Equal(t, apiError.Url, url)
+}
+
+func TestListReturnsApiErrorOnUnsuccessfulResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error": "invalid token"}`))
+	}))
+	defer server.Close()
+
+	zoneApi := api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+	url := zoneApi.GetUrlFromEnvironmentUrl(server.URL)
+
+	_, err := listValues(context.Background(), server.Client(), nil, zoneApi, url, "token")
+
+	var apiError ApiError
+	assert.Assert(t, errors.As(err, &apiError), "expected an ApiError, but got %v", err)
+	assert.Equal(t, apiError.StatusCode, http.StatusUnauthorized)
+}
+
+func TestDeleteReturnsApiErrorOnUnsuccessfulResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"values": [{"id": "zone-id", "name": "Zone"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error": "missing permission"}`))
+	}))
+	defer server.Close()
+
+	zoneApi := api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+	url := zoneApi.GetUrlFromEnvironmentUrl(server.URL)
+	cache := newValueCache()
+
+	err := deleteDynatraceObject(context.Background(), server.Client(), cache, zoneApi, "Zone", url, "token")
+	assert.ErrorContains(t, err, "Failed to delete DT object Zone (zone-id) of management-zone: HTTP 403")
+
+	var apiError ApiError
+	assert.Assert(t, errors.As(err, &apiError), "expected an ApiError, but got %v", err)
+	assert.Equal(t, apiError.StatusCode, http.StatusForbidden)
+	assert.Equal(t, apiError.Url, url+"/zone-id")
+
+	// the object still exists, so it's kept in the cache
+	values, err := cache.getOrLoad(zoneApi, func() ([]api.Value, error) {
+		return nil, errors.New("values should be cached")
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []api.Value{{Id: "zone-id", Name: "Zone"}})
+}
+
+func TestDeleteIgnoresObjectWhichDoesNotExistAnymore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"values": [{"id": "zone-id", "name": "Zone"}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	zoneApi := api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+	url := zoneApi.GetUrlFromEnvironmentUrl(server.URL)
+
+	err := deleteDynatraceObject(context.Background(), server.Client(), nil, zoneApi, "Zone", url, "token")
+	assert.NilError(t, err)
+}
@@ -0,0 +1,251 @@
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+)
+
+// defaultRetryBodyPatterns match errors of the Dynatrace API which are resolved by waiting, as the config needs
+// time to be propagated to all cluster nodes
+var defaultRetryBodyPatterns = []string{
+	"must have a unique name",
+	"must specify a known request attribute",
+}
+
+// RetryPolicy defines how requests failing with transient errors are retried. Idempotent requests are retried on
+// 5xx responses, connection resets and timeouts. As these don't tell whether a POST has created an object, a POST
+// is only retried on unsuccessful responses whose body matches one of the BodyPatterns, like all other requests.
+// The backoff between two attempts grows exponentially from InitialBackoff up to MaxBackoff, half of it is random.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent, including the first attempt
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	BodyPatterns   []*regexp.Regexp
+}
+
+// DefaultRetryPolicy sends each request up to 5 times, with backoffs of up to 1, 2, 4 and 8 seconds
+func DefaultRetryPolicy() RetryPolicy {
+	policy := RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+	}
+	for _, pattern := range defaultRetryBodyPatterns {
+		policy.BodyPatterns = append(policy.BodyPatterns, regexp.MustCompile(regexp.QuoteMeta(pattern)))
+	}
+	return policy
+}
+
+// retryPolicyFromEnvironment creates the DefaultRetryPolicy, adjusted by the environment variables
+// MONACO_RETRY_MAX_ATTEMPTS, MONACO_RETRY_INITIAL_BACKOFF, MONACO_RETRY_MAX_BACKOFF and MONACO_RETRY_BODY_PATTERN.
+// The body pattern is a regular expression, which is added to the default patterns.
+func retryPolicyFromEnvironment() (RetryPolicy, error) {
+
+	policy := DefaultRetryPolicy()
+
+	if value, found := os.LookupEnv("MONACO_RETRY_MAX_ATTEMPTS"); found {
+		maxAttempts, err := strconv.Atoi(value)
+		if err != nil || maxAttempts < 1 {
+			return policy, fmt.Errorf("MONACO_RETRY_MAX_ATTEMPTS must be a positive number, but was %s", value)
+		}
+		policy.MaxAttempts = maxAttempts
+	}
+
+	for name, backoff := range map[string]*time.Duration{
+		"MONACO_RETRY_INITIAL_BACKOFF": &policy.InitialBackoff,
+		"MONACO_RETRY_MAX_BACKOFF":     &policy.MaxBackoff,
+	} {
+		if value, found := os.LookupEnv(name); found {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return policy, fmt.Errorf("%s must be a duration like 10s, but was %s", name, value)
+			}
+			*backoff = duration
+		}
+	}
+
+	if value, found := os.LookupEnv("MONACO_RETRY_BODY_PATTERN"); found && value != "" {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return policy, fmt.Errorf("MONACO_RETRY_BODY_PATTERN is not a valid regular expression: %w", err)
+		}
+		policy.BodyPatterns = append(policy.BodyPatterns, pattern)
+	}
+	return policy, nil
+}
+
+// backoff returns how long to wait before the given retry, starting at 1 for the first one
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 1; i < retry && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
+
+// retryReason returns why the request with the given method has to be retried, or an empty string if it must not
+// be retried
+func (p RetryPolicy) retryReason(method string, resp *http.Response, body []byte, err error) string {
+	if err != nil {
+		if isIdempotent(method) && isTransientError(err) {
+			return err.Error()
+		}
+		return ""
+	}
+	if resp.StatusCode >= 500 && isIdempotent(method) {
+		return fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	if resp.StatusCode >= 400 {
+		for _, pattern := range p.BodyPatterns {
+			if pattern.Match(body) {
+				return fmt.Sprintf("HTTP %d, response matches '%s'", resp.StatusCode, pattern)
+			}
+		}
+	}
+	return ""
+}
+
+// isIdempotent is true for the methods, whose requests can be sent again without changing their result
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodPut || method == http.MethodDelete
+}
+
+func isTransientError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryTransport sends requests using the RetryPolicy. It counts the retries, so that they can be reported.
+type retryTransport struct {
+	// retries is accessed atomically and therefore the first field, to be 64-bit aligned on 32-bit platforms
+	retries          int64
+	base             http.RoundTripper
+	policy           RetryPolicy
+	timelineProvider util.TimelineProvider
+	// rateLimitStrategy is the name of the rate limit strategy of the client using this transport. It is kept by
+	// the transport, as requests are executed by functions which only get the http.Client. The same applies to
+	// rateLimit and log.
+	rateLimitStrategy string
+	rateLimit         int
+	log               util.PrefixedLogger
+}
+
+func newRetryTransport(policy RetryPolicy) *retryTransport {
+	return &retryTransport{
+		base:             http.DefaultTransport,
+		policy:           policy,
+		timelineProvider: util.NewTimelineProvider(),
+	}
+}
+
+func (t *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+
+	for attempt := 1; ; attempt++ {
+
+		attemptRequest := request
+		if attempt > 1 && request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptRequest = request.Clone(request.Context())
+			attemptRequest.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptRequest)
+
+		// the body has to be read to match it, so it is replaced by the read copy
+		var body []byte
+		if err == nil && resp.StatusCode >= 400 {
+			body, err = ioutil.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if err != nil {
+				resp = nil
+			} else {
+				resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+		}
+
+		reason := t.policy.retryReason(request.Method, resp, body, err)
+		if reason == "" || attempt >= t.policy.MaxAttempts || request.Context().Err() != nil {
+			return resp, err
+		}
+
+		backoff := t.policy.backoff(attempt)
+		t.log.Warn("\t\t%s %s failed (%s), retrying in %s (attempt %d of %d)...", request.Method, request.URL.Path,
+			reason, backoff.Round(time.Millisecond), attempt+1, t.policy.MaxAttempts)
+		atomic.AddInt64(&t.retries, 1)
+
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		if err := t.timelineProvider.Sleep(request.Context(), backoff); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *retryTransport) retryCount() int {
+	return int(atomic.LoadInt64(&t.retries))
+}
+
+// loggerOf returns the logger of the client. Clients not created by NewDynatraceClient log without prefix.
+func loggerOf(client *http.Client) util.PrefixedLogger {
+	if transport, ok := client.Transport.(*retryTransport); ok {
+		return transport.log
+	}
+	return util.PrefixedLogger{}
+}
+
+// Retries returns how many requests of the given client have been retried because of transient errors
+func Retries(client DynatraceClient) int {
+	switch c := client.(type) {
+	case *dynatraceClientImpl:
+		if transport, ok := c.client.Transport.(*retryTransport); ok {
+			return transport.retryCount()
+		}
+	case *readOnlyClient:
+		return Retries(c.client)
+	}
+	return 0
+}
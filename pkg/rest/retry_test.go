@@ -0,0 +1,220 @@
+// +build unit
+
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rest
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"gotest.tools/assert"
+)
+
+func newRetryTestClient(policy RetryPolicy) (*http.Client, *retryTransport) {
+	transport := newRetryTransport(policy)
+	return &http.Client{Transport: transport}, transport
+}
+
+func newRetryTestPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 3
+	policy.InitialBackoff = 0
+	return policy
+}
+
+// newFailingTestServer responds with the given status and body to the first failures requests, and with 200
+// and the request body afterwards
+func newFailingTestServer(failures int, status int, body string, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		if *requests <= failures {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+			return
+		}
+		requestBody, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(requestBody)
+	}))
+}
+
+func TestRetryOnServerError(t *testing.T) {
+	requests := 0
+	server := newFailingTestServer(2, http.StatusServiceUnavailable, "", &requests)
+	defer server.Close()
+
+	client, transport := newRetryTestClient(newRetryTestPolicy())
+
+	resp, err := put(context.Background(), client, server.URL, []byte(`{"name": "Zone"}`), "token")
+	assert.NilError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, string(resp.Body), `{"name": "Zone"}`)
+	assert.Equal(t, requests, 3)
+	assert.Equal(t, transport.retryCount(), 2)
+}
+
+func TestNoRetryOfPostOnServerError(t *testing.T) {
+	requests := 0
+	server := newFailingTestServer(2, http.StatusServiceUnavailable, "", &requests)
+	defer server.Close()
+
+	client, transport := newRetryTestClient(newRetryTestPolicy())
+
+	resp, err := post(context.Background(), client, server.URL, []byte(`{"name": "Zone"}`), "token")
+	assert.NilError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+	assert.Equal(t, requests, 1)
+	assert.Equal(t, transport.retryCount(), 0)
+}
+
+func TestNoRetryOfPostOnTimeout(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// the object may have been created, but the response doesn't arrive in time
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client, transport := newRetryTestClient(newRetryTestPolicy())
+	transport.base = &http.Transport{ResponseHeaderTimeout: 50 * time.Millisecond}
+
+	_, err := post(context.Background(), client, server.URL, []byte(`{"name": "Zone"}`), "token")
+	assert.ErrorContains(t, err, "timeout")
+
+	// waits for the handler to complete, before its requests are counted
+	server.Close()
+	assert.Equal(t, requests, 1)
+	assert.Equal(t, transport.retryCount(), 0)
+}
+
+func TestRetryStopsAfterMaxAttempts(t *testing.T) {
+	requests := 0
+	server := newFailingTestServer(10, http.StatusInternalServerError, "internal error", &requests)
+	defer server.Close()
+
+	client, transport := newRetryTestClient(newRetryTestPolicy())
+
+	resp, err := get(context.Background(), client, server.URL, "token")
+	assert.NilError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusInternalServerError)
+	assert.Equal(t, string(resp.Body), "internal error")
+	assert.Equal(t, requests, 3)
+	assert.Equal(t, transport.retryCount(), 2)
+}
+
+func TestRetryOnMatchingResponseBody(t *testing.T) {
+	requests := 0
+	server := newFailingTestServer(1, http.StatusBadRequest, `{"message": "Zone must have a unique name"}`, &requests)
+	defer server.Close()
+
+	client, _ := newRetryTestClient(newRetryTestPolicy())
+
+	resp, err := post(context.Background(), client, server.URL, []byte(`{"name": "Zone"}`), "token")
+	assert.NilError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, requests, 2)
+}
+
+func TestNoRetryOnOtherClientErrors(t *testing.T) {
+	requests := 0
+	server := newFailingTestServer(1, http.StatusBadRequest, `{"message": "invalid payload"}`, &requests)
+	defer server.Close()
+
+	client, transport := newRetryTestClient(newRetryTestPolicy())
+
+	resp, err := post(context.Background(), client, server.URL, []byte(`{}`), "token")
+	assert.NilError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusBadRequest)
+	assert.Equal(t, string(resp.Body), `{"message": "invalid payload"}`)
+	assert.Equal(t, requests, 1)
+	assert.Equal(t, transport.retryCount(), 0)
+}
+
+func TestRetryOnConnectionReset(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			// close the connection without responding
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client, transport := newRetryTestClient(newRetryTestPolicy())
+
+	resp, err := get(context.Background(), client, server.URL, "token")
+	assert.NilError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, transport.retryCount(), 1)
+}
+
+func TestBackoffGrowsExponentiallyUpToMaxBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	for retry, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		backoff := policy.backoff(retry)
+		assert.Assert(t, backoff >= max/2, "backoff %s of retry %d is too short", backoff, retry)
+		assert.Assert(t, backoff <= max, "backoff %s of retry %d is too long", backoff, retry)
+	}
+}
+
+func TestRetryPolicyFromEnvironment(t *testing.T) {
+	util.SetEnv(t, "MONACO_RETRY_MAX_ATTEMPTS", "7")
+	util.SetEnv(t, "MONACO_RETRY_MAX_BACKOFF", "1m")
+	util.SetEnv(t, "MONACO_RETRY_BODY_PATTERN", "not (yet )?available")
+	defer util.UnsetEnv(t, "MONACO_RETRY_MAX_ATTEMPTS")
+	defer util.UnsetEnv(t, "MONACO_RETRY_MAX_BACKOFF")
+	defer util.UnsetEnv(t, "MONACO_RETRY_BODY_PATTERN")
+
+	policy, err := retryPolicyFromEnvironment()
+	assert.NilError(t, err)
+	assert.Equal(t, policy.MaxAttempts, 7)
+	assert.Equal(t, policy.InitialBackoff, time.Second)
+	assert.Equal(t, policy.MaxBackoff, time.Minute)
+	assert.Equal(t, len(policy.BodyPatterns), len(defaultRetryBodyPatterns)+1)
+	assert.Equal(t, policy.BodyPatterns[len(defaultRetryBodyPatterns)].String(), "not (yet )?available")
+}
+
+func TestRetryPolicyFromEnvironmentFailsOnInvalidValue(t *testing.T) {
+	util.SetEnv(t, "MONACO_RETRY_MAX_ATTEMPTS", "0")
+	defer util.UnsetEnv(t, "MONACO_RETRY_MAX_ATTEMPTS")
+
+	_, err := retryPolicyFromEnvironment()
+	assert.ErrorContains(t, err, "MONACO_RETRY_MAX_ATTEMPTS must be a positive number")
+}
+
+func TestClientUsesLoggerOption(t *testing.T) {
+
+	log := util.NewPrefixedLogger("dev")
+	client, err := NewDynatraceClient("https://logger.live.dynatrace.com", "token", WithLogger(log))
+	assert.NilError(t, err)
+	assert.Equal(t, loggerOf(client.(*dynatraceClientImpl).client), log)
+
+	// clients not created by NewDynatraceClient log without prefix
+	assert.Equal(t, loggerOf(&http.Client{}), util.PrefixedLogger{})
+}
@@ -0,0 +1,180 @@
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rest
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+)
+
+var (
+	tokenBucketsMutex sync.Mutex
+	tokenBuckets      = map[string]*tokenBucketRateLimitStrategy{}
+)
+
+// sharedTokenBucketRateLimitStrategy returns the tokenBucketRateLimitStrategy of the given environment, so that
+// all clients and goroutines sending requests to it share the same bucket. A positive maxLimit restricts the
+// requests per minute of the bucket.
+func sharedTokenBucketRateLimitStrategy(environment string, maxLimit float64) *tokenBucketRateLimitStrategy {
+	tokenBucketsMutex.Lock()
+	defer tokenBucketsMutex.Unlock()
+
+	bucket, found := tokenBuckets[environment]
+	if !found {
+		bucket = newTokenBucketRateLimitStrategy(maxLimit)
+		tokenBuckets[environment] = bucket
+	} else if maxLimit > 0 {
+		bucket.restrict(maxLimit)
+	}
+	return bucket
+}
+
+// tokenBucketRateLimitStrategy is a rate limiting strategy which throttles requests before the server rejects them.
+// Each request takes a token from the bucket, which holds up to limit tokens and is refilled with limit tokens per
+// minute. If the bucket is empty, the current goroutine is suspended until the next token is available.
+// The limit adapts to the rate limiting header 'X-RateLimit-Limit', but never exceeds maxLimit if it is set. Until
+// the limit is known, requests are not throttled. 'X-RateLimit-Remaining' empties the bucket, if the quota is used up
+// by someone else, e.g. other deployments to the same environment.
+// Requests still exceeding the rate limit are handled by the simpleSleepRateLimitStrategy.
+type tokenBucketRateLimitStrategy struct {
+	mutex sync.Mutex
+	// limit is the number of requests per minute, 0 if it is not known yet
+	limit float64
+	// maxLimit is the configured maximum of requests per minute, 0 if there is none
+	maxLimit   float64
+	tokens     float64
+	lastRefill time.Time
+}
+
+// newTokenBucketRateLimitStrategy creates a bucket sending at most maxLimit requests per minute. If maxLimit isn't
+// positive, requests are only throttled once the environment reported its limit.
+func newTokenBucketRateLimitStrategy(maxLimit float64) *tokenBucketRateLimitStrategy {
+	if maxLimit < 0 {
+		maxLimit = 0
+	}
+	return &tokenBucketRateLimitStrategy{
+		limit:    maxLimit,
+		maxLimit: maxLimit,
+		tokens:   maxLimit,
+	}
+}
+
+// restrict lowers the maximum of requests per minute of the bucket to maxLimit
+func (s *tokenBucketRateLimitStrategy) restrict(maxLimit float64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.maxLimit == 0 || maxLimit < s.maxLimit {
+		s.maxLimit = maxLimit
+	}
+	if s.limit == 0 || s.limit > s.maxLimit {
+		s.setLimit(s.maxLimit)
+	}
+}
+
+// setLimit changes the requests per minute. Once the limit becomes known, the bucket is filled.
+func (s *tokenBucketRateLimitStrategy) setLimit(limit float64) {
+	if s.limit == 0 {
+		s.tokens = limit
+	}
+	s.limit = limit
+	s.tokens = math.Min(s.tokens, s.limit)
+}
+
+func (s *tokenBucketRateLimitStrategy) executeRequest(ctx context.Context, timelineProvider util.TimelineProvider, log util.PrefixedLogger, callback func() (Response, error)) (Response, error) {
+
+	fallback := simpleSleepRateLimitStrategy{}
+
+	return fallback.executeRequest(ctx, timelineProvider, log, func() (Response, error) {
+		if err := s.take(ctx, timelineProvider, log); err != nil {
+			return Response{}, err
+		}
+
+		response, err := callback()
+		if err == nil {
+			s.adapt(log, response)
+		}
+		return response, err
+	})
+}
+
+// take removes a token from the bucket, waiting until one is available
+func (s *tokenBucketRateLimitStrategy) take(ctx context.Context, timelineProvider util.TimelineProvider, log util.PrefixedLogger) error {
+	for {
+		s.mutex.Lock()
+		if s.limit == 0 {
+			s.mutex.Unlock()
+			return nil
+		}
+		s.refill(timelineProvider.Now())
+		if s.tokens >= 1 {
+			s.tokens--
+			s.mutex.Unlock()
+			return nil
+		}
+		sleepDuration := time.Duration((1 - s.tokens) / s.limit * float64(time.Minute))
+		limit := s.limit
+		s.mutex.Unlock()
+
+		log.Debug("tokenBucketRateLimitStrategy: Rate limit of %.0f requests/min reached, sleeping for %f seconds...", limit, sleepDuration.Seconds())
+		if err := timelineProvider.Sleep(ctx, sleepDuration); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *tokenBucketRateLimitStrategy) refill(now time.Time) {
+	if s.lastRefill.IsZero() {
+		s.lastRefill = now
+		return
+	}
+	if now.After(s.lastRefill) {
+		s.tokens = math.Min(s.limit, s.tokens+now.Sub(s.lastRefill).Minutes()*s.limit)
+		s.lastRefill = now
+	}
+}
+
+// adapt updates the bucket from the rate limiting headers of the response
+func (s *tokenBucketRateLimitStrategy) adapt(log util.PrefixedLogger, response Response) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if limit, err := strconv.Atoi(rateLimitHeader(response, "X-RateLimit-Limit")); err == nil && limit > 0 {
+		newLimit := float64(limit)
+		if s.maxLimit > 0 {
+			newLimit = math.Min(newLimit, s.maxLimit)
+		}
+		if newLimit != s.limit {
+			log.Debug("tokenBucketRateLimitStrategy: Adapting rate limit from %.0f to %.0f requests/min", s.limit, newLimit)
+			s.setLimit(newLimit)
+		}
+	}
+
+	if remaining, err := strconv.Atoi(rateLimitHeader(response, "X-RateLimit-Remaining")); err == nil && remaining >= 0 {
+		s.tokens = math.Min(s.tokens, float64(remaining))
+	}
+
+	if response.StatusCode == http.StatusTooManyRequests {
+		s.tokens = 0
+	}
+}
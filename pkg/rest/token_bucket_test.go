@@ -0,0 +1,240 @@
+// +build unit
+
+/**
+ * @license
+ * Copyright 2021 Dynatrace LLC
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rest
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/util"
+	"gotest.tools/assert"
+)
+
+// fakeTimelineProvider doesn't sleep, but advances its time by the sleep duration
+type fakeTimelineProvider struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (f *fakeTimelineProvider) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeTimelineProvider) Sleep(_ context.Context, duration time.Duration) error {
+	f.sleeps = append(f.sleeps, duration)
+	f.now = f.now.Add(duration)
+	return nil
+}
+
+func okCallback() (Response, error) {
+	return Response{StatusCode: 200}, nil
+}
+
+func TestTokenBucketThrottlesRequestsExceedingTheLimit(t *testing.T) {
+
+	rateLimitStrategy := newTokenBucketRateLimitStrategy(2)
+	timelineProvider := &fakeTimelineProvider{now: time.Unix(0, 0)}
+
+	for i := 0; i < 3; i++ {
+		_, err := rateLimitStrategy.executeRequest(context.Background(), timelineProvider, util.PrefixedLogger{}, okCallback)
+		assert.NilError(t, err)
+	}
+
+	// 2 requests/min refill one token every 30 seconds
+	assert.DeepEqual(t, timelineProvider.sleeps, []time.Duration{30 * time.Second})
+}
+
+func TestTokenBucketIsRefilledOverTime(t *testing.T) {
+
+	rateLimitStrategy := newTokenBucketRateLimitStrategy(2)
+	timelineProvider := &fakeTimelineProvider{now: time.Unix(0, 0)}
+
+	for i := 0; i < 2; i++ {
+		_, err := rateLimitStrategy.executeRequest(context.Background(), timelineProvider, util.PrefixedLogger{}, okCallback)
+		assert.NilError(t, err)
+	}
+	timelineProvider.now = timelineProvider.now.Add(time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := rateLimitStrategy.executeRequest(context.Background(), timelineProvider, util.PrefixedLogger{}, okCallback)
+		assert.NilError(t, err)
+	}
+	assert.Equal(t, len(timelineProvider.sleeps), 0)
+}
+
+func TestTokenBucketAdaptsToRateLimitHeaders(t *testing.T) {
+
+	rateLimitStrategy := newTokenBucketRateLimitStrategy(0)
+
+	rateLimitStrategy.adapt(util.PrefixedLogger{}, Response{
+		StatusCode: 200,
+		Headers:    map[string][]string{"X-Ratelimit-Limit": {"20"}},
+	})
+	assert.Equal(t, rateLimitStrategy.limit, 20.0)
+	assert.Equal(t, rateLimitStrategy.tokens, 20.0)
+
+	rateLimitStrategy.adapt(util.PrefixedLogger{}, Response{
+		StatusCode: 200,
+		Headers:    map[string][]string{"X-Ratelimit-Limit": {"20"}, "X-Ratelimit-Remaining": {"3"}},
+	})
+	assert.Equal(t, rateLimitStrategy.tokens, 3.0)
+
+	rateLimitStrategy.adapt(util.PrefixedLogger{}, Response{StatusCode: 429})
+	assert.Equal(t, rateLimitStrategy.tokens, 0.0)
+}
+
+func TestTokenBucketDoesNotThrottleUntilLimitIsKnown(t *testing.T) {
+
+	rateLimitStrategy := newTokenBucketRateLimitStrategy(0)
+	timelineProvider := &fakeTimelineProvider{now: time.Unix(0, 0)}
+
+	for i := 0; i < 100; i++ {
+		_, err := rateLimitStrategy.executeRequest(context.Background(), timelineProvider, util.PrefixedLogger{}, okCallback)
+		assert.NilError(t, err)
+	}
+	assert.Equal(t, len(timelineProvider.sleeps), 0)
+}
+
+func TestTokenBucketDoesNotExceedMaxLimit(t *testing.T) {
+
+	rateLimitStrategy := newTokenBucketRateLimitStrategy(30)
+
+	rateLimitStrategy.adapt(util.PrefixedLogger{}, Response{
+		StatusCode: 200,
+		Headers:    map[string][]string{"X-Ratelimit-Limit": {"100"}},
+	})
+	assert.Equal(t, rateLimitStrategy.limit, 30.0)
+
+	rateLimitStrategy.adapt(util.PrefixedLogger{}, Response{
+		StatusCode: 200,
+		Headers:    map[string][]string{"X-Ratelimit-Limit": {"20"}},
+	})
+	assert.Equal(t, rateLimitStrategy.limit, 20.0)
+
+	rateLimitStrategy.restrict(10)
+	assert.Equal(t, rateLimitStrategy.limit, 10.0)
+	assert.Equal(t, rateLimitStrategy.tokens, 10.0)
+}
+
+func TestTokenBucketWaitsForResetIfRateLimitIsExceeded(t *testing.T) {
+
+	rateLimitStrategy := newTokenBucketRateLimitStrategy(60)
+	timelineProvider := &fakeTimelineProvider{now: time.Unix(0, 0)}
+	invocationCount := 0
+	callback := func() (Response, error) {
+		invocationCount++
+		if invocationCount == 1 {
+			return Response{
+				StatusCode: 429,
+				Headers:    createTestHeaders(42 * time.Second.Microseconds()), // in 42 seconds, limit 20
+			}, nil
+		}
+		return Response{StatusCode: 200}, nil
+	}
+
+	response, err := rateLimitStrategy.executeRequest(context.Background(), timelineProvider, util.PrefixedLogger{}, callback)
+
+	assert.NilError(t, err)
+	assert.Equal(t, response.StatusCode, 200)
+	assert.Equal(t, invocationCount, 2)
+	// the bucket has been refilled while sleeping until the reset, so the second request isn't throttled
+	assert.DeepEqual(t, timelineProvider.sleeps, []time.Duration{42 * time.Second})
+	assert.Equal(t, rateLimitStrategy.limit, 20.0)
+}
+
+func TestTokenBucketIsSharedPerEnvironment(t *testing.T) {
+
+	first := createRateLimitStrategy("token-bucket", "https://shared.live.dynatrace.com", 0)
+	second := createRateLimitStrategy("token-bucket", "https://shared.live.dynatrace.com", 0)
+	other := createRateLimitStrategy("token-bucket", "https://other.live.dynatrace.com", 0)
+
+	_, isTokenBucket := first.(*tokenBucketRateLimitStrategy)
+	assert.Assert(t, isTokenBucket)
+	assert.Assert(t, first == second)
+	assert.Assert(t, first != other)
+}
+
+func TestCreateRateLimitStrategyDefaultsToSimpleSleep(t *testing.T) {
+
+	_, isSimpleSleep := createRateLimitStrategy("", "https://test.live.dynatrace.com", 0).(*simpleSleepRateLimitStrategy)
+	assert.Assert(t, isSimpleSleep)
+
+	name, err := rateLimitStrategyFromEnvironment()
+	assert.NilError(t, err)
+	assert.Equal(t, name, "simple")
+}
+
+func TestRateLimitStrategyFromEnvironmentFailsOnUnknownStrategy(t *testing.T) {
+
+	util.SetEnv(t, "MONACO_RATE_LIMIT_STRATEGY", "leaky-bucket")
+	defer util.UnsetEnv(t, "MONACO_RATE_LIMIT_STRATEGY")
+
+	_, err := rateLimitStrategyFromEnvironment()
+	assert.ErrorContains(t, err, "MONACO_RATE_LIMIT_STRATEGY must be simple or token-bucket, but was leaky-bucket")
+}
+
+func TestClientUsesRateLimitStrategyOption(t *testing.T) {
+
+	util.SetEnv(t, "MONACO_RATE_LIMIT_STRATEGY", "simple")
+	defer util.UnsetEnv(t, "MONACO_RATE_LIMIT_STRATEGY")
+
+	client, err := NewDynatraceClient("https://option.live.dynatrace.com", "token", WithRateLimitStrategy("token-bucket"))
+	assert.NilError(t, err)
+
+	strategy := rateLimitStrategyOf(client.(*dynatraceClientImpl).client, "https://option.live.dynatrace.com")
+	_, isTokenBucket := strategy.(*tokenBucketRateLimitStrategy)
+	assert.Assert(t, isTokenBucket)
+
+	// without the option, the environment variable is the default
+	client, err = NewDynatraceClient("https://option.live.dynatrace.com", "token")
+	assert.NilError(t, err)
+
+	strategy = rateLimitStrategyOf(client.(*dynatraceClientImpl).client, "https://option.live.dynatrace.com")
+	_, isSimpleSleep := strategy.(*simpleSleepRateLimitStrategy)
+	assert.Assert(t, isSimpleSleep)
+}
+
+func TestClientUsesRateLimitOption(t *testing.T) {
+
+	client, err := NewDynatraceClient("https://limit.live.dynatrace.com", "token", WithRateLimitStrategy("token-bucket"), WithRateLimit(40))
+	assert.NilError(t, err)
+
+	strategy := rateLimitStrategyOf(client.(*dynatraceClientImpl).client, "https://limit.live.dynatrace.com")
+	assert.Equal(t, strategy.(*tokenBucketRateLimitStrategy).limit, 40.0)
+}
+
+func TestNewClientFailsOnUnknownRateLimitStrategy(t *testing.T) {
+
+	_, err := NewDynatraceClient("https://option.live.dynatrace.com", "token", WithRateLimitStrategy("leaky-bucket"))
+	assert.ErrorContains(t, err, "rate limit strategy must be simple or token-bucket, but was leaky-bucket")
+}
+
+func TestEnvironmentOfRequestUrl(t *testing.T) {
+
+	for requestUrl, environment := range map[string]string{
+		"https://abc12345.live.dynatrace.com/api/config/v1/dashboards":     "https://abc12345.live.dynatrace.com",
+		"https://managed.example.com/e/1234-5678/api/config/v1/dashboards": "https://managed.example.com/e/1234-5678",
+	} {
+		parsedUrl, err := url.Parse(requestUrl)
+		assert.NilError(t, err)
+		assert.Equal(t, environmentOf(parsedUrl), environment)
+	}
+}
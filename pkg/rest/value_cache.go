@@ -0,0 +1,138 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"os"
+	"sync"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+)
+
+// valueCache caches the values (id and name tuples) of each API, so that the existing objects of an API don't have
+// to be listed again for every single upsert or delete. It is filled lazily and kept up to date with the objects
+// created, updated and deleted through the client owning it.
+// All methods are safe for concurrent use and can be called on a nil cache, which disables caching.
+type valueCache struct {
+	mutex  sync.Mutex
+	values map[string][]api.Value
+}
+
+// newValueCache creates a new cache. If the environment variable MONACO_DISABLE_LIST_CACHE is set, caching
+// is disabled and nil is returned.
+func newValueCache() *valueCache {
+	if disabled, found := os.LookupEnv("MONACO_DISABLE_LIST_CACHE"); found && disabled != "0" {
+		return nil
+	}
+
+	return &valueCache{
+		values: make(map[string][]api.Value),
+	}
+}
+
+// getOrLoad returns a copy of the cached values of the API. If the API is not cached yet, the values are loaded
+// using load and put into the cache.
+func (c *valueCache) getOrLoad(theApi api.Api, load func() ([]api.Value, error)) ([]api.Value, error) {
+	if c == nil {
+		return load()
+	}
+
+	c.mutex.Lock()
+	values, found := c.values[theApi.GetId()]
+	c.mutex.Unlock()
+
+	if found {
+		return copyValues(values), nil
+	}
+
+	values, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// another config of the same API might have been created while loading, so don't overwrite the cache
+	if cached, found := c.values[theApi.GetId()]; found {
+		return copyValues(cached), nil
+	}
+	c.values[theApi.GetId()] = values
+	return copyValues(values), nil
+}
+
+// put adds the value to the cached values of the API or replaces the cached value with the same id.
+// Nothing happens if the API has not been cached yet.
+func (c *valueCache) put(theApi api.Api, value api.Value) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	values, found := c.values[theApi.GetId()]
+	if !found {
+		return
+	}
+
+	for i := range values {
+		if values[i].Id == value.Id {
+			values[i] = value
+			return
+		}
+	}
+	c.values[theApi.GetId()] = append(values, value)
+}
+
+// remove removes the value with the given id from the cached values of the API
+func (c *valueCache) remove(theApi api.Api, id string) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	values := c.values[theApi.GetId()]
+	for i := range values {
+		if values[i].Id == id {
+			c.values[theApi.GetId()] = append(values[:i:i], values[i+1:]...)
+			return
+		}
+	}
+}
+
+// invalidate drops the cached values of the API, so that they are loaded again on next access
+func (c *valueCache) invalidate(theApi api.Api) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.values, theApi.GetId())
+}
+
+func copyValues(values []api.Value) []api.Value {
+	if values == nil {
+		return nil
+	}
+
+	result := make([]api.Value, len(values))
+	copy(result, values)
+	return result
+}
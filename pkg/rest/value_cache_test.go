@@ -0,0 +1,124 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dynatrace-oss/dynatrace-monitoring-as-code/pkg/api"
+	"gotest.tools/assert"
+)
+
+var cacheTestApi = api.NewStandardApi("management-zone", "/api/config/v1/managementZones")
+
+func TestValueCacheLoadsValuesOnlyOnce(t *testing.T) {
+	cache := &valueCache{values: make(map[string][]api.Value)}
+
+	loads := 0
+	load := func() ([]api.Value, error) {
+		loads++
+		return []api.Value{{Id: "zone-id", Name: "Zone"}}, nil
+	}
+
+	values, err := cache.getOrLoad(cacheTestApi, load)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []api.Value{{Id: "zone-id", Name: "Zone"}})
+
+	_, err = cache.getOrLoad(cacheTestApi, load)
+	assert.NilError(t, err)
+	assert.Equal(t, loads, 1)
+}
+
+func TestValueCacheIsUpdatedByPutAndRemove(t *testing.T) {
+	cache := &valueCache{values: make(map[string][]api.Value)}
+	cache.put(cacheTestApi, api.Value{Id: "ignored-id", Name: "Ignored"})
+
+	_, err := cache.getOrLoad(cacheTestApi, func() ([]api.Value, error) {
+		return []api.Value{{Id: "zone-id", Name: "Zone"}}, nil
+	})
+	assert.NilError(t, err)
+
+	cache.put(cacheTestApi, api.Value{Id: "other-id", Name: "Other"})
+	cache.put(cacheTestApi, api.Value{Id: "zone-id", Name: "Renamed"})
+	cache.remove(cacheTestApi, "other-id")
+
+	values, err := cache.getOrLoad(cacheTestApi, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, values, []api.Value{{Id: "zone-id", Name: "Renamed"}})
+}
+
+func TestNilValueCacheAlwaysLoads(t *testing.T) {
+	var cache *valueCache
+
+	loads := 0
+	for i := 0; i < 2; i++ {
+		_, err := cache.getOrLoad(cacheTestApi, func() ([]api.Value, error) {
+			loads++
+			return nil, nil
+		})
+		assert.NilError(t, err)
+	}
+	cache.put(cacheTestApi, api.Value{Id: "zone-id"})
+	cache.remove(cacheTestApi, "zone-id")
+	cache.invalidate(cacheTestApi)
+
+	assert.Equal(t, loads, 2)
+}
+
+func TestNewValueCacheCanBeDisabled(t *testing.T) {
+	os.Setenv("MONACO_DISABLE_LIST_CACHE", "true")
+	defer os.Unsetenv("MONACO_DISABLE_LIST_CACHE")
+
+	assert.Assert(t, newValueCache() == nil)
+}
+
+func TestUpsertOfCreatedObjectUsesCachedValues(t *testing.T) {
+	listCount := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config/v1/managementZones":
+			listCount++
+			_, _ = w.Write([]byte(`{"values": []}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/config/v1/managementZones":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": "zone-id", "name": "Zone"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/config/v1/managementZones/zone-id":
+			_, _ = w.Write([]byte(`{"id": "zone-id", "name": "Zone"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cache := &valueCache{values: make(map[string][]api.Value)}
+	url := cacheTestApi.GetUrlFromEnvironmentUrl(server.URL)
+
+	entity, err := upsertDynatraceObject(context.Background(), server.Client(), cache, url, "Zone", cacheTestApi, []byte(`{"name": "Zone"}`), "token")
+	assert.NilError(t, err)
+	assert.Equal(t, entity.Id, "zone-id")
+
+	entity, err = upsertDynatraceObject(context.Background(), server.Client(), cache, url, "Zone", cacheTestApi, []byte(`{"name": "Zone"}`), "token")
+	assert.NilError(t, err)
+	assert.Assert(t, entity.Unchanged)
+
+	assert.Equal(t, listCount, 1)
+}
@@ -0,0 +1,144 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/spf13/afero"
+)
+
+// State records which Dynatrace object has been deployed for each config of an environment. Configs are identified
+// by their full qualified id relative to the projects root folder (e.g. project/dashboard/overview).
+// All methods are safe for concurrent use.
+type State struct {
+	mutex   sync.Mutex
+	Configs map[string]Entry `json:"configs"`
+}
+
+// Entry is the deployed Dynatrace object of a single config
+type Entry struct {
+	Project     string `json:"project"`
+	Api         string `json:"api"`
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	PayloadHash string `json:"payloadHash"`
+	// Dependencies are the ids of the configs this config references
+	Dependencies []string `json:"dependencies,omitempty"`
+}
+
+// FilePath returns the path of the state file of an environment: <workingDir>/.monaco/state/<environment>.json
+func FilePath(workingDir string, environmentId string) string {
+	return filepath.Join(workingDir, ".monaco", "state", environmentId+".json")
+}
+
+// New creates an empty state
+func New() *State {
+	return &State{
+		Configs: make(map[string]Entry),
+	}
+}
+
+// Load reads the state from the given file. If the file does not exist yet, an empty state is returned.
+func Load(fs afero.Fs, file string) (*State, error) {
+
+	data, err := afero.ReadFile(fs, file)
+	if os.IsNotExist(err) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	state := New()
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		return nil, fmt.Errorf("state file %s is not valid: %s", file, err)
+	}
+
+	if state.Configs == nil {
+		state.Configs = make(map[string]Entry)
+	}
+	return state, nil
+}
+
+// Save writes the state to the given file. Missing parent folders are created.
+func (s *State) Save(fs afero.Fs, file string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = fs.MkdirAll(filepath.Dir(file), 0777)
+	if err != nil {
+		return err
+	}
+
+	return afero.WriteFile(fs, file, data, 0664)
+}
+
+// Get returns the entry of the config with the given id
+func (s *State) Get(configId string) (entry Entry, found bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, found = s.Configs[configId]
+	return entry, found
+}
+
+// Put stores the entry of the config with the given id
+func (s *State) Put(configId string, entry Entry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.Configs[configId] = entry
+}
+
+// Remove removes the entry of the config with the given id
+func (s *State) Remove(configId string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.Configs, configId)
+}
+
+// ConfigIds returns the sorted ids of all configs contained in the state
+func (s *State) ConfigIds() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ids := make([]string, 0, len(s.Configs))
+	for id := range s.Configs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// HashPayload returns the hash of a rendered config, as it is stored in the state
+func HashPayload(payload []byte) string {
+	hash := sha256.Sum256(payload)
+	return hex.EncodeToString(hash[:])
+}
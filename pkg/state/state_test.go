@@ -0,0 +1,69 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"gotest.tools/assert"
+)
+
+func TestLoadReturnsEmptyStateForMissingFile(t *testing.T) {
+	state, err := Load(afero.NewMemMapFs(), FilePath("projects", "dev"))
+
+	assert.NilError(t, err)
+	assert.Equal(t, len(state.ConfigIds()), 0)
+}
+
+func TestSavedStateCanBeLoaded(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	file := FilePath("projects", "dev")
+
+	entry := Entry{
+		Project:     "project",
+		Api:         "dashboard",
+		Id:          "dashboard-id",
+		Name:        "Overview",
+		PayloadHash: HashPayload([]byte(`{}`)),
+	}
+
+	state := New()
+	state.Put("project/dashboard/overview", entry)
+	state.Put("project/dashboard/removed", entry)
+	state.Remove("project/dashboard/removed")
+
+	err := state.Save(fs, file)
+	assert.NilError(t, err)
+
+	loaded, err := Load(fs, file)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, loaded.ConfigIds(), []string{"project/dashboard/overview"})
+
+	loadedEntry, found := loaded.Get("project/dashboard/overview")
+	assert.Assert(t, found)
+	assert.DeepEqual(t, loadedEntry, entry)
+}
+
+func TestLoadFailsOnInvalidFile(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	file := FilePath("projects", "dev")
+	assert.NilError(t, afero.WriteFile(fs, file, []byte("{"), 0664))
+
+	_, err := Load(fs, file)
+	assert.ErrorContains(t, err, "is not valid")
+}
@@ -0,0 +1,166 @@
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ServerManagedJsonKeys are the top level keys of a Dynatrace config which are maintained by the
+// server and therefore must not be taken into account when comparing a config with its live object.
+var ServerManagedJsonKeys = []string{"id", "metadata"}
+
+const (
+	JsonChangeAdded   = "added"
+	JsonChangeRemoved = "removed"
+	JsonChangeChanged = "changed"
+)
+
+// JsonChange describes a single difference between two json documents
+type JsonChange struct {
+
+	// Path is the location of the difference, e.g. tiles[0].name
+	Path string `json:"path"`
+	// Kind is one of JsonChangeAdded, JsonChangeRemoved or JsonChangeChanged
+	Kind string `json:"kind"`
+	// Old contains the value in the current document, if available
+	Old interface{} `json:"old,omitempty"`
+	// New contains the value in the desired document, if available
+	New interface{} `json:"new,omitempty"`
+}
+
+// DiffJson compares the json documents current and desired semantically, i.e. independent of key order
+// and formatting. Top level keys contained in ignoredKeys are not compared.
+// The returned changes describe what needs to be done to get from current to desired and are sorted by path.
+func DiffJson(current []byte, desired []byte, ignoredKeys ...string) ([]JsonChange, error) {
+
+	currentValue, err := unmarshalForDiff(current, ignoredKeys)
+	if err != nil {
+		return nil, fmt.Errorf("current json could not be parsed: %s", err)
+	}
+
+	desiredValue, err := unmarshalForDiff(desired, ignoredKeys)
+	if err != nil {
+		return nil, fmt.Errorf("desired json could not be parsed: %s", err)
+	}
+
+	changes := make([]JsonChange, 0)
+	return diffValues("", currentValue, desiredValue, changes), nil
+}
+
+// JsonEquals reports whether the given json documents are semantically equal. Top level keys contained
+// in ignoredKeys are not compared.
+func JsonEquals(current []byte, desired []byte, ignoredKeys ...string) (bool, error) {
+
+	changes, err := DiffJson(current, desired, ignoredKeys...)
+	if err != nil {
+		return false, err
+	}
+	return len(changes) == 0, nil
+}
+
+func unmarshalForDiff(data []byte, ignoredKeys []string) (interface{}, error) {
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	if object, ok := value.(map[string]interface{}); ok {
+		for _, key := range ignoredKeys {
+			delete(object, key)
+		}
+	}
+	return value, nil
+}
+
+func diffValues(path string, current interface{}, desired interface{}, changes []JsonChange) []JsonChange {
+
+	currentObject, currentIsObject := current.(map[string]interface{})
+	desiredObject, desiredIsObject := desired.(map[string]interface{})
+	if currentIsObject && desiredIsObject {
+		return diffObjects(path, currentObject, desiredObject, changes)
+	}
+
+	currentArray, currentIsArray := current.([]interface{})
+	desiredArray, desiredIsArray := desired.([]interface{})
+	if currentIsArray && desiredIsArray {
+		return diffArrays(path, currentArray, desiredArray, changes)
+	}
+
+	if !reflect.DeepEqual(current, desired) {
+		changes = append(changes, JsonChange{
+			Path: path,
+			Kind: JsonChangeChanged,
+			Old:  current,
+			New:  desired,
+		})
+	}
+	return changes
+}
+
+func diffObjects(path string, current map[string]interface{}, desired map[string]interface{}, changes []JsonChange) []JsonChange {
+
+	keys := make([]string, 0, len(current)+len(desired))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	for key := range desired {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "." + key
+		}
+
+		currentValue, inCurrent := current[key]
+		desiredValue, inDesired := desired[key]
+
+		switch {
+		case !inCurrent:
+			changes = append(changes, JsonChange{Path: keyPath, Kind: JsonChangeAdded, New: desiredValue})
+		case !inDesired:
+			changes = append(changes, JsonChange{Path: keyPath, Kind: JsonChangeRemoved, Old: currentValue})
+		default:
+			changes = diffValues(keyPath, currentValue, desiredValue, changes)
+		}
+	}
+	return changes
+}
+
+func diffArrays(path string, current []interface{}, desired []interface{}, changes []JsonChange) []JsonChange {
+
+	for i := 0; i < len(current) || i < len(desired); i++ {
+		indexPath := fmt.Sprintf("%s[%d]", path, i)
+
+		switch {
+		case i >= len(current):
+			changes = append(changes, JsonChange{Path: indexPath, Kind: JsonChangeAdded, New: desired[i]})
+		case i >= len(desired):
+			changes = append(changes, JsonChange{Path: indexPath, Kind: JsonChangeRemoved, Old: current[i]})
+		default:
+			changes = diffValues(indexPath, current[i], desired[i], changes)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,72 @@
+// +build unit
+
+// @license
+// Copyright 2021 Dynatrace LLC
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestDiffJsonIgnoresKeyOrderAndFormatting(t *testing.T) {
+
+	current := []byte(`{"name": "foo", "rules": [{"a": 1, "b": true}]}`)
+	desired := []byte(`{
+		"rules": [{"b": true, "a": 1}],
+		"name": "foo"
+	}`)
+
+	changes, err := DiffJson(current, desired)
+	assert.NilError(t, err)
+	assert.Equal(t, len(changes), 0)
+}
+
+func TestDiffJsonIgnoresServerManagedKeys(t *testing.T) {
+
+	current := []byte(`{"id": "1234", "metadata": {"clusterVersion": "1.2"}, "name": "foo"}`)
+	desired := []byte(`{"name": "foo"}`)
+
+	equal, err := JsonEquals(current, desired, ServerManagedJsonKeys...)
+	assert.NilError(t, err)
+	assert.Assert(t, equal)
+
+	equal, err = JsonEquals(current, desired)
+	assert.NilError(t, err)
+	assert.Assert(t, !equal)
+}
+
+func TestDiffJsonReportsChanges(t *testing.T) {
+
+	current := []byte(`{"name": "foo", "enabled": true, "tiles": [{"name": "a"}, {"name": "b"}]}`)
+	desired := []byte(`{"name": "bar", "tiles": [{"name": "a"}], "owner": "me"}`)
+
+	changes, err := DiffJson(current, desired)
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, changes, []JsonChange{
+		{Path: "enabled", Kind: JsonChangeRemoved, Old: true},
+		{Path: "name", Kind: JsonChangeChanged, Old: "foo", New: "bar"},
+		{Path: "owner", Kind: JsonChangeAdded, New: "me"},
+		{Path: "tiles[1]", Kind: JsonChangeRemoved, Old: map[string]interface{}{"name": "b"}},
+	})
+}
+
+func TestDiffJsonFailsOnInvalidJson(t *testing.T) {
+
+	_, err := DiffJson([]byte(`{"name": "foo"}`), []byte(`{"name": `))
+	assert.ErrorContains(t, err, "desired json could not be parsed")
+}
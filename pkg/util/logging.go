@@ -34,6 +34,35 @@ var Log lumber.Logger = lumber.NewConsoleLogger(lumber.INFO)
 var requestLogFile *os.File
 var responseLogFile *os.File
 
+// PrefixedLogger logs to the shared Log, but prefixes every message. This keeps the output readable
+// if e.g. multiple environments are processed at the same time.
+type PrefixedLogger struct {
+	prefix string
+}
+
+// NewPrefixedLogger creates a PrefixedLogger, which prefixes all messages with [prefix]
+func NewPrefixedLogger(prefix string) PrefixedLogger {
+	return PrefixedLogger{
+		prefix: "[" + strings.ReplaceAll(prefix, "%", "%%") + "] ",
+	}
+}
+
+func (l PrefixedLogger) Error(format string, v ...interface{}) {
+	Log.Error(l.prefix+format, v...)
+}
+
+func (l PrefixedLogger) Warn(format string, v ...interface{}) {
+	Log.Warn(l.prefix+format, v...)
+}
+
+func (l PrefixedLogger) Info(format string, v ...interface{}) {
+	Log.Info(l.prefix+format, v...)
+}
+
+func (l PrefixedLogger) Debug(format string, v ...interface{}) {
+	Log.Debug(l.prefix+format, v...)
+}
+
 // SetupLogging is used to initialize the shared file Logger once the necessary setup config is available
 func SetupLogging(verbose bool) error {
 	multiLog := lumber.NewMultiLogger()
@@ -17,6 +17,7 @@
 package util
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"time"
@@ -32,8 +33,9 @@ type TimelineProvider interface {
 	// Now Returns the current (client-side) time in UTC
 	Now() time.Time
 
-	// Sleep suspends the current goroutine for the specified duration
-	Sleep(duration time.Duration)
+	// Sleep suspends the current goroutine for the specified duration, or until the context is done.
+	// In the latter case, the error of the context is returned.
+	Sleep(ctx context.Context, duration time.Duration) error
 }
 
 // NewTimelineProvider creates a new TimelineProvider
@@ -50,8 +52,16 @@ func (d *defaultTimelineProvider) Now() time.Time {
 	return nowInLocalTimeZone.In(location)
 }
 
-func (d *defaultTimelineProvider) Sleep(duration time.Duration) {
-	time.Sleep(duration)
+func (d *defaultTimelineProvider) Sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // StringTimestampToHumanReadableFormat parses and sanity-checks a unix timestamp as string and returns it
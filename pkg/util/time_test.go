@@ -19,6 +19,7 @@
 package util
 
 import (
+	"context"
 	"gotest.tools/assert"
 	"testing"
 	"time"
@@ -55,3 +56,14 @@ func TestTimelineProviderReturnsUTC(t *testing.T) {
 	location, _ := time.LoadLocation("UTC")
 	assert.Equal(t, now.UnixNano(), now.In(location).UnixNano())
 }
+
+func TestTimelineProviderSleepStopsWhenContextIsDone(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	timelineProvider := NewTimelineProvider()
+	err := timelineProvider.Sleep(ctx, time.Hour)
+
+	assert.Equal(t, context.Canceled, err)
+}